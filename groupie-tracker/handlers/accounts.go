@@ -0,0 +1,439 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuthPage is the data passed to the login and registration template.
+type AuthPage struct {
+	Title      string
+	Action     string
+	Register   bool
+	Error      string
+	Username   string
+	Next       string
+	CSRF       string
+	SwitchText string
+	SwitchURL  string
+}
+
+// AccountPage is the data passed to the account template.
+type AccountPage struct {
+	User      User
+	Favorites []Artist
+	CSRF      string
+}
+
+// accountJSON is the JSON variant of the account page.
+type accountJSON struct {
+	Username      string        `json:"username"`
+	Email         string        `json:"email"`
+	Digest        string        `json:"digest"`
+	Favorites     []Artist      `json:"favorites"`
+	SavedSearches []SavedSearch `json:"savedSearches"`
+}
+
+func loginPage() AuthPage {
+	return AuthPage{
+		Title:      "Log in",
+		Action:     "/login",
+		SwitchText: "No account yet? Register",
+		SwitchURL:  "/register",
+	}
+}
+
+func registerPage() AuthPage {
+	return AuthPage{
+		Title:      "Register",
+		Action:     "/register",
+		Register:   true,
+		SwitchText: "Already registered? Log in",
+		SwitchURL:  "/login",
+	}
+}
+
+/*
+renderAuth renders the login or registration form with status. API clients
+asking for JSON get the form error as a problem document instead.
+*/
+func renderAuth(w http.ResponseWriter, r *http.Request, page AuthPage, status int) {
+	if page.Error != "" && wantsJSON(r) {
+		renderError(w, r, status, page.Error)
+		return
+	}
+	page.CSRF = csrfToken(ensureSession(w, r))
+	if page.Next == "" {
+		page.Next = localPath(r.FormValue("next"), "/account")
+	}
+	temp, err := parseTemplate("template/auth.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := temp.Execute(w, page); err != nil {
+		log.Printf("Error executing the %s template: %v", page.Action, err)
+	}
+}
+
+/*
+RegisterPageHandler shows the registration form.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func RegisterPageHandler(w http.ResponseWriter, r *http.Request) {
+	renderAuth(w, r, registerPage(), http.StatusOK)
+}
+
+/*
+RegisterHandler creates an account from the registration form and signs
+the new user in. Invalid input re-renders the form with the problem.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+	page := registerPage()
+	page.Username = strings.TrimSpace(r.PostFormValue("username"))
+	password := r.PostFormValue("password")
+
+	if password != r.PostFormValue("confirm") {
+		page.Error = "The passwords do not match"
+		renderAuth(w, r, page, http.StatusBadRequest)
+		return
+	}
+	user, err := createUser(page.Username, password)
+	switch err {
+	case nil:
+	case errInvalidUsername, errWeakPassword:
+		page.Error = err.Error()
+		renderAuth(w, r, page, http.StatusBadRequest)
+		return
+	case errUserExists:
+		page.Error = err.Error()
+		renderAuth(w, r, page, http.StatusConflict)
+		return
+	default:
+		renderError(w, r, http.StatusInternalServerError, "Error creating the account")
+		return
+	}
+
+	if err := startLogin(w, r, user.Username); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error signing in")
+		return
+	}
+	http.Redirect(w, r, localPath(r.PostFormValue("next"), "/account"), http.StatusSeeOther)
+}
+
+/*
+LoginPageHandler shows the login form.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
+	renderAuth(w, r, loginPage(), http.StatusOK)
+}
+
+/*
+LoginHandler checks the login form and signs the user in. After
+maxLoginFailures failed attempts within loginWindow, for the same account
+or from the same address, further attempts get a 429 until the window
+has passed.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+	page := loginPage()
+	page.Username = strings.TrimSpace(r.PostFormValue("username"))
+
+	keys := loginKeys(r, page.Username)
+	if ok, wait := loginAttempts.allow(keys...); !ok {
+		minutes := int(math.Ceil(wait.Minutes()))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		page.Error = fmt.Sprintf("Too many failed logins. Try again in %d minute(s).", minutes)
+		renderAuth(w, r, page, http.StatusTooManyRequests)
+		return
+	}
+
+	user, ok := authenticate(page.Username, r.PostFormValue("password"))
+	if !ok {
+		loginAttempts.fail(keys...)
+		page.Error = "Wrong username or password"
+		renderAuth(w, r, page, http.StatusUnauthorized)
+		return
+	}
+	loginAttempts.reset(keys[0])
+
+	if err := startLogin(w, r, user.Username); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error signing in")
+		return
+	}
+	http.Redirect(w, r, localPath(r.PostFormValue("next"), "/account"), http.StatusSeeOther)
+}
+
+/*
+LogoutHandler signs the user out and goes back to the home page.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+	if err := endLogin(w, r); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error signing out")
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// requireUser returns the signed-in user, or sends anonymous visitors to
+// the login form and returns false.
+func requireUser(w http.ResponseWriter, r *http.Request) (User, bool) {
+	name := currentUser(r)
+	user, ok := getUser(name)
+	if name == "" || !ok {
+		if r.Method == http.MethodGet && !wantsJSON(r) {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		} else {
+			renderError(w, r, http.StatusUnauthorized, "Please log in")
+		}
+		return User{}, false
+	}
+	return user, true
+}
+
+/*
+AccountHandler shows the signed-in user's favorite artists, saved
+searches and digest settings. With ?format=json or a JSON Accept header
+the same data is returned as JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AccountHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	ids, err := readFavorites(userOwner(user.Username))
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error reading favorites")
+		return
+	}
+	favorites := []Artist{}
+	for _, id := range ids {
+		if a, ok := data.Artist(id); ok {
+			favorites = append(favorites, a)
+		}
+	}
+
+	searches := user.SavedSearches
+	if searches == nil {
+		searches = []SavedSearch{}
+	}
+	account := accountJSON{Username: user.Username, Email: user.Email, Digest: user.Digest, Favorites: favorites, SavedSearches: searches}
+	if pageNotModified(w, r, contentValidator(account), "account") {
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, account)
+		return
+	}
+
+	temp, err := parseTemplate("template/account.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+	err = temp.Execute(w, AccountPage{User: user, Favorites: favorites, CSRF: csrfToken(sessionID(r))})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
+
+/*
+DigestSettingsHandler saves the signed-in user's email address and how
+often they want a concert digest for the artists they follow: "daily",
+"weekly", or "" for none.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func DigestSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+
+	frequency := r.PostFormValue("digest")
+	if frequency != "" && frequency != DigestDaily && frequency != DigestWeekly {
+		renderError(w, r, http.StatusBadRequest, "Invalid digest frequency")
+		return
+	}
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Name != "" {
+			renderError(w, r, http.StatusBadRequest, "Invalid email address")
+			return
+		}
+		email = addr.Address
+	}
+	if frequency != "" && email == "" {
+		renderError(w, r, http.StatusBadRequest, "An email address is needed for digests")
+		return
+	}
+
+	err := updateUser(user.Username, func(u *User) {
+		if u.Digest != frequency {
+			u.LastDigest = time.Time{}
+		}
+		u.Email = email
+		u.Digest = frequency
+	})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error saving the settings")
+		return
+	}
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+/*
+SaveSearchHandler stores an artists filter, given as the encoded query
+string in the "query" field, under the signed-in user's saved searches.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func SaveSearchHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+
+	values, err := url.ParseQuery(strings.TrimPrefix(r.PostFormValue("query"), "?"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid search")
+		return
+	}
+	filter, err := ParseArtistFilter(values)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if runes := []rune(name); len(runes) > 80 {
+		// cut whole characters, so the name stays valid UTF-8
+		name = strings.TrimSpace(string(runes[:80]))
+	}
+
+	err = updateUser(user.Username, func(u *User) {
+		id := 1
+		for _, s := range u.SavedSearches {
+			if s.ID >= id {
+				id = s.ID + 1
+			}
+		}
+		if name == "" {
+			name = fmt.Sprintf("Search %d", id)
+		}
+		u.SavedSearches = append(u.SavedSearches, SavedSearch{
+			ID:      id,
+			Name:    name,
+			Query:   filter.Values().Encode(),
+			Created: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error saving the search")
+		return
+	}
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+/*
+DeleteSearchHandler removes one of the signed-in user's saved searches.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func DeleteSearchHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+
+	id := PathInt(r, "id")
+	found := false
+	err := updateUser(user.Username, func(u *User) {
+		kept := u.SavedSearches[:0]
+		for _, s := range u.SavedSearches {
+			if s.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, s)
+		}
+		u.SavedSearches = kept
+	})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error deleting the search")
+		return
+	}
+	if !found {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that search")
+		return
+	}
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
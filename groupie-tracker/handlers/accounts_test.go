@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// testBrowser sends requests through the routes and keeps the session
+// cookie between them, like a browser would.
+type testBrowser struct {
+	t       *testing.T
+	handler http.Handler
+	cookie  *http.Cookie
+	header  http.Header // sent with every request
+}
+
+func newTestBrowser(t *testing.T) *testBrowser {
+	return &testBrowser{t: t, handler: Routes()}
+}
+
+// do sends a request; a non-nil form is posted with the session's CSRF token.
+func (b *testBrowser) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		if b.cookie != nil {
+			id, _ := verifySigned(b.cookie.Value)
+			form.Set(csrfField, csrfToken(id))
+		}
+		r = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, path, nil)
+	}
+	for k, v := range b.header {
+		r.Header[k] = v
+	}
+	if b.cookie != nil {
+		r.AddCookie(b.cookie)
+	}
+	w := httptest.NewRecorder()
+	b.handler.ServeHTTP(w, r)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			b.cookie = c
+		}
+	}
+	return w
+}
+
+func (b *testBrowser) account() (accountJSON, int) {
+	w := b.do("GET", "/account?format=json", nil)
+	var got accountJSON
+	json.NewDecoder(w.Body).Decode(&got)
+	return got, w.Code
+}
+
+func useAccountTest(t *testing.T) {
+	useTestDataset(t)
+	useTempDataDir(t)
+	useTestSessionKey(t)
+	useFastScrypt(t)
+	previous := loginAttempts
+	loginAttempts = newLoginLimiter()
+	t.Cleanup(func() { loginAttempts = previous })
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	useAccountTest(t)
+	b := newTestBrowser(t)
+
+	// Anonymous favorites are kept when the visitor registers.
+	b.do("GET", "/favorites", nil)
+	anonymous := b.cookie
+	b.do("POST", "/favorites/2", url.Values{})
+
+	w := b.do("POST", "/register", url.Values{"username": {"roger"}, "password": {"radio-ga-ga"}, "confirm": {"radio-ga-ga"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/account" {
+		t.Fatalf("POST /register = %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if b.cookie.Value == anonymous.Value {
+		t.Error("expected a new session after registering")
+	}
+
+	got, code := b.account()
+	if code != http.StatusOK || got.Username != "roger" || len(got.Favorites) != 1 || got.Favorites[0].ID != 2 {
+		t.Fatalf("GET /account = %d %+v", code, got)
+	}
+
+	// The old anonymous cookie is not signed in.
+	old := &testBrowser{t: t, handler: b.handler, cookie: anonymous}
+	if _, code := old.account(); code != http.StatusUnauthorized {
+		t.Errorf("GET /account with the pre-login cookie = %d, want 401", code)
+	}
+
+	b.do("POST", "/logout", url.Values{})
+	if _, code := b.account(); code != http.StatusUnauthorized {
+		t.Errorf("GET /account after logout = %d, want 401", code)
+	}
+	if w := b.do("GET", "/account", nil); w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/login?next=") {
+		t.Errorf("GET /account page after logout = %d to %q, want a redirect to login", w.Code, w.Header().Get("Location"))
+	}
+
+	w = b.do("POST", "/login", url.Values{"username": {"Roger"}, "password": {"radio-ga-ga"}, "next": {"/artists"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/artists" {
+		t.Fatalf("POST /login = %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if got, _ := b.account(); got.Username != "roger" || len(got.Favorites) != 1 {
+		t.Errorf("GET /account after login = %+v", got)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	useAccountTest(t)
+	createUser("taken", "password1")
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		wantStatus int
+	}{
+		{"passwords differ", url.Values{"username": {"brian"}, "password": {"password1"}, "confirm": {"password2"}}, http.StatusBadRequest},
+		{"weak password", url.Values{"username": {"brian"}, "password": {"short"}, "confirm": {"short"}}, http.StatusBadRequest},
+		{"username taken", url.Values{"username": {"Taken"}, "password": {"password1"}, "confirm": {"password1"}}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBrowser(t)
+			b.do("GET", "/register", nil)
+			if w := b.do("POST", "/register?format=json", tt.form); w.Code != tt.wantStatus {
+				t.Errorf("POST /register = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	b := newTestBrowser(t)
+	b.do("GET", "/register", nil)
+	form := url.Values{"username": {"brian"}, "password": {"password1"}, "confirm": {"password1"}}
+	r := httptest.NewRequest("POST", "/register", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(b.cookie)
+	w := httptest.NewRecorder()
+	b.handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("POST /register without a CSRF token = %d, want 403", w.Code)
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	useAccountTest(t)
+	createUser("john", "another-one")
+	b := newTestBrowser(t)
+	b.do("GET", "/login", nil)
+
+	for i := 0; i < maxLoginFailures; i++ {
+		if w := b.do("POST", "/login?format=json", url.Values{"username": {"john"}, "password": {"wrong"}}); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failed login %d = %d, want 401", i+1, w.Code)
+		}
+	}
+	w := b.do("POST", "/login?format=json", url.Values{"username": {"john"}, "password": {"another-one"}})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("login after %d failures = %d (Retry-After %q), want 429", maxLoginFailures, w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	useAccountTest(t)
+	b := newTestBrowser(t)
+	b.do("GET", "/register", nil)
+	b.do("POST", "/register", url.Values{"username": {"deacon"}, "password": {"bites-the-dust"}, "confirm": {"bites-the-dust"}})
+
+	if w := b.do("POST", "/account/searches", url.Values{"name": {"Big bands"}, "query": {"members_min=3&sort=name"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("POST /account/searches = %d", w.Code)
+	}
+	if w := b.do("POST", "/account/searches", url.Values{"query": {"members_min=x"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("saving an invalid filter = %d, want 400", w.Code)
+	}
+	b.do("POST", "/account/searches", url.Values{"query": {"q=queen"}})
+
+	got, _ := b.account()
+	if len(got.SavedSearches) != 2 {
+		t.Fatalf("SavedSearches = %+v, want 2", got.SavedSearches)
+	}
+	first := got.SavedSearches[0]
+	if first.Name != "Big bands" || first.URL() != "/artists?members_min=3&sort=name" {
+		t.Errorf("first saved search = %+v (%s)", first, first.URL())
+	}
+	if got.SavedSearches[1].Name != "Search 2" {
+		t.Errorf("unnamed search got name %q, want \"Search 2\"", got.SavedSearches[1].Name)
+	}
+
+	long := strings.Repeat("é", 100)
+	b.do("POST", "/account/searches", url.Values{"name": {long}, "query": {"q=queen"}})
+	got, _ = b.account()
+	if name := got.SavedSearches[2].Name; name != strings.Repeat("é", 80) || !utf8.ValidString(name) {
+		t.Errorf("long name saved as %q, want 80 characters", name)
+	}
+
+	if w := b.do("POST", "/account/searches/1/delete", url.Values{}); w.Code != http.StatusSeeOther {
+		t.Errorf("deleting a search = %d", w.Code)
+	}
+	if w := b.do("POST", "/account/searches/1/delete", url.Values{}); w.Code != http.StatusNotFound {
+		t.Errorf("deleting a missing search = %d, want 404", w.Code)
+	}
+	if got, _ := b.account(); len(got.SavedSearches) != 2 || got.SavedSearches[0].ID != 2 {
+		t.Errorf("SavedSearches after delete = %+v", got.SavedSearches)
+	}
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+adminCredentials returns the admin username and password from
+GROUPIE_ADMIN_USER (default "admin") and GROUPIE_ADMIN_PASSWORD.
+The admin area is disabled while no password is set.
+*/
+func adminCredentials() (user, password string, enabled bool) {
+	user = os.Getenv("GROUPIE_ADMIN_USER")
+	if user == "" {
+		user = "admin"
+	}
+	password = os.Getenv("GROUPIE_ADMIN_PASSWORD")
+	return user, password, password != ""
+}
+
+// sameSecret compares two strings in constant time, whatever their lengths.
+func sameSecret(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+/*
+requireAdmin protects an admin handler with HTTP Basic authentication.
+Failed attempts are rate-limited like logins, and mutating requests must
+carry the CSRF token of the session the admin pages were loaded in.
+*/
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, password, enabled := adminCredentials()
+		if !enabled {
+			renderError(w, r, http.StatusNotFound, "Oops! We Can't find that page")
+			return
+		}
+
+		keys := loginKeys(r, "admin")[1:]
+		if ok, wait := loginAttempts.allow(keys...); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			renderError(w, r, http.StatusTooManyRequests, "Too many failed logins")
+			return
+		}
+		u, p, ok := r.BasicAuth()
+		if !ok || !sameSecret(u, user) || !sameSecret(p, password) {
+			if ok {
+				loginAttempts.fail(keys...)
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="groupie-tracker admin", charset="UTF-8"`)
+			renderError(w, r, http.StatusUnauthorized, "Admin login required")
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !checkCSRF(r, sessionID(r)) {
+			renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// AdminArtist is one row of the cached dataset on the admin dashboard.
+type AdminArtist struct {
+	Artist
+	Concerts    int  `json:"concerts"`
+	HasOverride bool `json:"hasOverride"`
+}
+
+// AdminPage is the data passed to the admin dashboard template.
+type AdminPage struct {
+	Provider  string        `json:"provider"`
+	FetchedAt time.Time     `json:"fetchedAt"`
+	Cached    bool          `json:"cached"`
+	Fresh     bool          `json:"fresh"`
+	Concerts  int           `json:"concerts"`
+	Artists   []AdminArtist `json:"artists"`
+	Status    RefreshStatus `json:"status"`
+	Message   string        `json:"-"`
+	CSRF      string        `json:"-"`
+}
+
+/*
+AdminHandler shows the admin dashboard: which provider is in use, the
+cached dataset with the artists that have overrides, and the outcome of
+recent refreshes. With ?format=json the same data is returned as JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AdminHandler(w http.ResponseWriter, r *http.Request) {
+	overrides, err := readOverrides()
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error reading overrides")
+		return
+	}
+
+	page := AdminPage{
+		Provider: fmt.Sprintf("%T", provider),
+		Status:   currentRefreshStatus(),
+		Artists:  []AdminArtist{},
+		CSRF:     csrfToken(ensureSession(w, r)),
+	}
+	if d := currentDataset(); d != nil {
+		page.Cached = true
+		page.FetchedAt = d.FetchedAt
+		page.Fresh = fresh(d)
+		for _, a := range d.Artists {
+			n := len(d.Concerts(a.ID))
+			page.Concerts += n
+			_, has := overrides[a.ID]
+			page.Artists = append(page.Artists, AdminArtist{Artist: a, Concerts: n, HasOverride: has})
+		}
+	}
+	switch r.URL.Query().Get("refresh") {
+	case "ok":
+		page.Message = "The dataset was refreshed."
+	case "failed":
+		page.Message = "The refresh failed; see the errors below."
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+	renderAdmin(w, r, "template/admin.html", page)
+}
+
+/*
+AdminRefreshHandler refreshes the dataset from the provider right away and
+returns to the dashboard, which reports the outcome.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AdminRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	outcome := "ok"
+	if _, _, err := RefreshDataset(); err != nil {
+		outcome = "failed"
+	}
+	http.Redirect(w, r, "/admin?refresh="+outcome, http.StatusSeeOther)
+}
+
+/*
+AdminRequestsHandler lists the most recent requests made to the upstream
+API, newest first. With ?format=json the log is returned as JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AdminRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	requests := upstreamRequests()
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, requests)
+		return
+	}
+	renderAdmin(w, r, "template/admin_requests.html", requests)
+}
+
+// OverrideForm is an Override as the text fields of the admin form.
+type OverrideForm struct {
+	Name         string
+	Image        string
+	Members      string // one per line
+	CreationDate string
+	FirstAlbum   string
+	Rename       string // "from = to" per line
+	Drop         string // one location per line
+	Add          string // "location = date, date" per line
+	Remove       string // "location = date, date" per line
+	Note         string
+}
+
+// AdminOverridePage is the data passed to the override editing template.
+type AdminOverridePage struct {
+	Artist  Artist
+	Form    OverrideForm
+	Exists  bool
+	Message string
+	Error   string
+	CSRF    string
+}
+
+// newOverrideForm fills the form fields from an override.
+func newOverrideForm(o Override) OverrideForm {
+	f := OverrideForm{Members: strings.Join(o.Artist.Members, "\n"), Note: o.Note}
+	if o.Artist.Name != nil {
+		f.Name = *o.Artist.Name
+	}
+	if o.Artist.Image != nil {
+		f.Image = *o.Artist.Image
+	}
+	if o.Artist.CreationDate != nil {
+		f.CreationDate = strconv.Itoa(*o.Artist.CreationDate)
+	}
+	if o.Artist.FirstAlbum != nil {
+		f.FirstAlbum = *o.Artist.FirstAlbum
+	}
+
+	var lines []string
+	for from, to := range o.RenameLocations {
+		lines = append(lines, from+" = "+to)
+	}
+	sort.Strings(lines)
+	f.Rename = strings.Join(lines, "\n")
+	f.Drop = strings.Join(o.DropLocations, "\n")
+	f.Add = formatDateLists(o.AddDates)
+	f.Remove = formatDateLists(o.RemoveDates)
+	return f
+}
+
+func formatDateLists(m map[string][]string) string {
+	var lines []string
+	for loc, dates := range m {
+		lines = append(lines, loc+" = "+strings.Join(dates, ", "))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// override parses the form fields; empty fields leave values unchanged.
+func (f OverrideForm) override() (Override, error) {
+	var o Override
+	if v := strings.TrimSpace(f.Name); v != "" {
+		o.Artist.Name = &v
+	}
+	if v := strings.TrimSpace(f.Image); v != "" {
+		o.Artist.Image = &v
+	}
+	o.Artist.Members = formLines(f.Members)
+	if v := strings.TrimSpace(f.CreationDate); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil || year < 1000 || year > 9999 {
+			return Override{}, fmt.Errorf("invalid creation date %q", v)
+		}
+		o.Artist.CreationDate = &year
+	}
+	if v := strings.TrimSpace(f.FirstAlbum); v != "" {
+		if _, err := ParseConcertDate(v); err != nil {
+			return Override{}, fmt.Errorf("invalid first album date %q", v)
+		}
+		o.Artist.FirstAlbum = &v
+	}
+
+	for _, line := range formLines(f.Rename) {
+		from, to, ok := strings.Cut(line, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return Override{}, fmt.Errorf("invalid rename %q, want \"from = to\"", line)
+		}
+		if o.RenameLocations == nil {
+			o.RenameLocations = map[string]string{}
+		}
+		o.RenameLocations[from] = to
+	}
+	o.DropLocations = formLines(f.Drop)
+
+	var err error
+	if o.AddDates, err = parseDateLists(f.Add); err != nil {
+		return Override{}, err
+	}
+	if o.RemoveDates, err = parseDateLists(f.Remove); err != nil {
+		return Override{}, err
+	}
+	o.Note = strings.TrimSpace(f.Note)
+	return o, nil
+}
+
+// formLines returns the non-blank lines of a textarea, trimmed.
+func formLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseDateLists parses "location = date, date" lines.
+func parseDateLists(s string) (map[string][]string, error) {
+	var m map[string][]string
+	for _, line := range formLines(s) {
+		loc, list, ok := strings.Cut(line, "=")
+		loc = strings.TrimSpace(loc)
+		if !ok || loc == "" {
+			return nil, fmt.Errorf("invalid dates %q, want \"location = DD-MM-YYYY, ...\"", line)
+		}
+		for _, d := range strings.Split(list, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			if _, err := ParseConcertDate(d); err != nil {
+				return nil, err
+			}
+			if m == nil {
+				m = map[string][]string{}
+			}
+			m[loc] = append(m[loc], d)
+		}
+	}
+	return m, nil
+}
+
+func (o Override) empty() bool {
+	p := o.Artist
+	return p.Name == nil && p.Image == nil && p.Members == nil && p.CreationDate == nil && p.FirstAlbum == nil &&
+		len(o.RenameLocations) == 0 && len(o.DropLocations) == 0 && len(o.AddDates) == 0 && len(o.RemoveDates) == 0
+}
+
+/*
+AdminOverrideHandler shows the form for editing one artist's overrides.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AdminOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	id := PathInt(r, "id")
+	artist, ok := adminArtist(id)
+	if !ok {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that artist")
+		return
+	}
+	overrides, err := readOverrides()
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error reading overrides")
+		return
+	}
+	o, exists := overrides[id]
+	page := AdminOverridePage{Artist: artist, Form: newOverrideForm(o), Exists: exists, CSRF: csrfToken(ensureSession(w, r))}
+	switch r.URL.Query().Get("saved") {
+	case "ok":
+		page.Message = "Saved. The dataset was refreshed with the new overrides."
+	case "stale":
+		page.Message = "Saved, but the refresh failed; the overrides apply from the next successful refresh."
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, o)
+		return
+	}
+	renderAdmin(w, r, "template/admin_override.html", page)
+}
+
+/*
+AdminSaveOverrideHandler saves or, with action=delete, removes one artist's
+overrides and refreshes the dataset so pages show the result.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AdminSaveOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	id := PathInt(r, "id")
+	artist, ok := adminArtist(id)
+	if !ok {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that artist")
+		return
+	}
+
+	form := OverrideForm{
+		Name:         r.PostFormValue("name"),
+		Image:        r.PostFormValue("image"),
+		Members:      r.PostFormValue("members"),
+		CreationDate: r.PostFormValue("creationDate"),
+		FirstAlbum:   r.PostFormValue("firstAlbum"),
+		Rename:       r.PostFormValue("rename"),
+		Drop:         r.PostFormValue("drop"),
+		Add:          r.PostFormValue("add"),
+		Remove:       r.PostFormValue("remove"),
+		Note:         r.PostFormValue("note"),
+	}
+	var o Override
+	if r.PostFormValue("action") != "delete" {
+		var err error
+		if o, err = form.override(); err != nil {
+			if wantsJSON(r) {
+				renderError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			renderAdmin(w, r, "template/admin_override.html", AdminOverridePage{
+				Artist: artist, Form: form, Exists: true, Error: err.Error(), CSRF: csrfToken(sessionID(r)),
+			})
+			return
+		}
+	}
+
+	overridesMu.Lock()
+	overrides, err := readOverrides()
+	if err == nil {
+		if o.empty() {
+			delete(overrides, id)
+		} else {
+			overrides[id] = o
+		}
+		err = writeOverrides(overrides)
+	}
+	overridesMu.Unlock()
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error saving overrides")
+		return
+	}
+
+	saved := "ok"
+	if _, _, err := RefreshDataset(); err != nil {
+		saved = "stale"
+	}
+	http.Redirect(w, r, fmt.Sprintf("/admin/overrides/%d?saved=%s", id, saved), http.StatusSeeOther)
+}
+
+// adminArtist finds an artist in the cached dataset or the provider.
+func adminArtist(id int) (Artist, bool) {
+	if d := currentDataset(); d != nil {
+		if a, ok := d.Artist(id); ok {
+			return a, true
+		}
+	}
+	a, err := provider.GetArtist(id)
+	return a, err == nil
+}
+
+// renderAdmin renders one of the admin templates.
+func renderAdmin(w http.ResponseWriter, r *http.Request, path string, data interface{}) {
+	temp, err := parseTemplate(path)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+	if err := temp.Execute(w, data); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
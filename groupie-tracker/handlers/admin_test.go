@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newAdminBrowser returns a browser that sends the given admin credentials.
+func newAdminBrowser(t *testing.T, user, password string) *testBrowser {
+	b := newTestBrowser(t)
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.SetBasicAuth(user, password)
+	b.header = http.Header{"Authorization": r.Header["Authorization"]}
+	return b
+}
+
+func useAdminTest(t *testing.T) {
+	useAccountTest(t)
+	t.Setenv("GROUPIE_ADMIN_USER", "")
+	t.Setenv("GROUPIE_ADMIN_PASSWORD", "s3cret")
+}
+
+func TestRequireAdmin(t *testing.T) {
+	useAdminTest(t)
+
+	tests := []struct {
+		name       string
+		user, pass string
+		wantStatus int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "admin", "guess", http.StatusUnauthorized},
+		{"wrong user", "root", "s3cret", http.StatusUnauthorized},
+		{"valid", "admin", "s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBrowser(t)
+			if tt.user != "" {
+				b = newAdminBrowser(t, tt.user, tt.pass)
+			}
+			w := b.do("GET", "/admin?format=json", nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d; got %d", tt.wantStatus, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate header")
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("GROUPIE_ADMIN_PASSWORD", "")
+		w := newAdminBrowser(t, "admin", "").do("GET", "/admin?format=json", nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without a password; got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("csrf", func(t *testing.T) {
+		b := newAdminBrowser(t, "admin", "s3cret")
+		w := b.do("POST", "/admin/refresh", url.Values{})
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status %d without a session; got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+func TestAdminDashboardAndRefresh(t *testing.T) {
+	useAdminTest(t)
+	api := useFakeAPI(t)
+	b := newAdminBrowser(t, "admin", "s3cret")
+	b.do("GET", "/admin?format=json", nil) // start a session
+
+	api.set(`{}`, true)
+	w := b.do("POST", "/admin/refresh", url.Values{})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin?refresh=failed" {
+		t.Fatalf("expected a redirect reporting the failure; got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	api.set(`{"london-uk":["20-11-2019"]}`, false)
+	w = b.do("POST", "/admin/refresh", url.Values{})
+	if w.Header().Get("Location") != "/admin?refresh=ok" {
+		t.Fatalf("expected a redirect reporting success; got %q", w.Header().Get("Location"))
+	}
+
+	var page AdminPage
+	json.NewDecoder(b.do("GET", "/admin?format=json", nil).Body).Decode(&page)
+	if !page.Cached || len(page.Artists) != 1 || page.Concerts != 1 {
+		t.Errorf("unexpected dataset summary %+v", page)
+	}
+	if len(page.Status.Errors) == 0 || page.Status.LastSuccess.IsZero() {
+		t.Errorf("expected the failed and the successful refresh in %+v", page.Status)
+	}
+
+	var requests []UpstreamRequest
+	json.NewDecoder(b.do("GET", "/admin/requests?format=json", nil).Body).Decode(&requests)
+	statuses := map[int]bool{}
+	for _, req := range requests {
+		if strings.HasSuffix(req.URL, "/relation") {
+			statuses[req.Status] = true
+		}
+	}
+	if !statuses[http.StatusOK] || !statuses[http.StatusInternalServerError] {
+		t.Errorf("expected both relation requests in the log; got %+v", requests)
+	}
+}
+
+func TestAdminOverrides(t *testing.T) {
+	useAdminTest(t)
+	useFakeAPI(t)
+	useProvider(t, WithOverrides(provider))
+	b := newAdminBrowser(t, "admin", "s3cret")
+	b.do("GET", "/admin?format=json", nil)
+	b.do("POST", "/admin/refresh", url.Values{})
+
+	w := b.do("POST", "/admin/overrides/1?format=json", url.Values{"creationDate": {"nineteen"}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an invalid year; got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = b.do("POST", "/admin/overrides/1", url.Values{
+		"name":   {"Queen + Adam Lambert"},
+		"rename": {"london-uk = london-england"},
+		"add":    {"paris-france = 01-01-2020, 02-01-2020"},
+		"note":   {"tour 2020"},
+	})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/overrides/1?saved=ok" {
+		t.Fatalf("expected a redirect after saving; got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	d := currentDataset()
+	if a, _ := d.Artist(1); a.Name != "Queen + Adam Lambert" {
+		t.Errorf("expected the refreshed dataset to use the override; got %q", a.Name)
+	}
+	if got := len(d.Concerts(1)); got != 3 {
+		t.Errorf("expected 3 concerts after the override; got %d", got)
+	}
+
+	var o Override
+	json.NewDecoder(b.do("GET", "/admin/overrides/1?format=json", nil).Body).Decode(&o)
+	f := newOverrideForm(o)
+	if f.Rename != "london-uk = london-england" || f.Add != "paris-france = 01-01-2020, 02-01-2020" || f.Note != "tour 2020" {
+		t.Errorf("unexpected form %+v", f)
+	}
+
+	b.do("POST", "/admin/overrides/1", url.Values{"action": {"delete"}})
+	if overrides, _ := readOverrides(); len(overrides) != 0 {
+		t.Errorf("expected the override to be removed; got %+v", overrides)
+	}
+	if a, _ := currentDataset().Artist(1); a.Name != "Queen" {
+		t.Errorf("expected the upstream name back; got %q", a.Name)
+	}
+
+	if w := b.do("GET", "/admin/overrides/99?format=json", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown artist; got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// staticDir holds the files served under /static/. Tests point it at a
+// temporary directory.
+var staticDir = "static"
+
+// immutableCacheControl is for fingerprinted files: their URL changes with
+// their content, so browsers never need to ask again.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// precompressed are the encodings static files may be stored in next to the
+// original, in order of preference: artists.css.br, artists.css.gz.
+var precompressed = []struct{ coding, ext string }{{"br", ".br"}, {"gzip", ".gz"}}
+
+/*
+assets maps static file names to their fingerprinted names and back, e.g.
+"artists.css" to "artists.3f2a1b9c0d.css". It is empty until
+FingerprintAssets runs; asset then returns plain URLs.
+*/
+var assets = struct {
+	sync.RWMutex
+	hashed   map[string]string
+	original map[string]string
+}{}
+
+/*
+FingerprintAssets hashes every file under dir so templates can link to
+content-addressed URLs with the asset function. Call it at startup, before
+serving requests; a changed file gets a new URL on the next start.
+*/
+func FingerprintAssets(dir string) error {
+	hashed, original := map[string]string{}, map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		name := filepath.ToSlash(strings.TrimPrefix(p, dir+string(filepath.Separator)))
+		for _, pc := range precompressed {
+			if strings.HasSuffix(name, pc.ext) {
+				return nil // a variant of another file
+			}
+		}
+		sum, err := fileHash(p)
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(name)
+		h := strings.TrimSuffix(name, ext) + "." + sum + ext
+		hashed[name], original[h] = h, name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	assets.Lock()
+	assets.hashed, assets.original = hashed, original
+	assets.Unlock()
+	return nil
+}
+
+// fileHash returns the first 10 hex digits of the SHA-256 of a file.
+func fileHash(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:10], nil
+}
+
+/*
+asset returns the URL of a static file, fingerprinted when possible.
+Templates use it as {{asset "artists.css"}}.
+*/
+func asset(name string) string {
+	assets.RLock()
+	defer assets.RUnlock()
+	if h, ok := assets.hashed[name]; ok {
+		return "/static/" + h
+	}
+	return "/static/" + name
+}
+
+// templateFuncs are the functions available to every page template.
+var templateFuncs = template.FuncMap{"asset": asset}
+
+// parseTemplate parses a page template with templateFuncs. Pages are
+// html/template templates: values are escaped for where they appear.
+func parseTemplate(file string) (*template.Template, error) {
+	return template.New(filepath.Base(file)).Funcs(templateFuncs).ParseFiles(file)
+}
+
+/*
+StaticHandler serves the files under static/. Fingerprinted names are served
+with an immutable cache lifetime, other names with the default one. Clients
+accepting brotli or gzip get a pre-compressed variant when one exists
+(withCompression adds the Vary header).
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func StaticHandler(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + PathParam(r, "path"))[1:]
+	assets.RLock()
+	original, fingerprinted := assets.original[name]
+	assets.RUnlock()
+	if fingerprinted {
+		name = original
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	}
+
+	dir := http.Dir(staticDir)
+	f, info, ok := openStatic(dir, name)
+	if !ok {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that page")
+		return
+	}
+	defer f.Close()
+
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		for _, pc := range precompressed {
+			if !acceptsEncoding(r, pc.coding) {
+				continue
+			}
+			if cf, _, ok := openStatic(dir, name+pc.ext); ok {
+				defer cf.Close()
+				w.Header().Set("Content-Type", ct)
+				w.Header().Set("Content-Encoding", pc.coding)
+				http.ServeContent(w, r, name, info.ModTime(), cf)
+				return
+			}
+		}
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// openStatic opens a regular file in dir.
+func openStatic(dir http.Dir, name string) (http.File, os.FileInfo, bool) {
+	f, err := dir.Open(name)
+	if err != nil {
+		return nil, nil, false
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, false
+	}
+	return f, info, true
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useStaticDir serves static files from a temporary directory holding
+// site.css, a pre-compressed site.css.br and images/logo.png.
+func useStaticDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "images"), 0o755)
+	os.WriteFile(filepath.Join(dir, "site.css"), []byte("body { color: green; }"), 0o644)
+	os.WriteFile(filepath.Join(dir, "site.css.br"), []byte("brotli bytes"), 0o644)
+	os.WriteFile(filepath.Join(dir, "images", "logo.png"), []byte("png bytes"), 0o644)
+
+	previous := staticDir
+	staticDir = dir
+	assets.Lock()
+	hashed, original := assets.hashed, assets.original
+	assets.Unlock()
+	t.Cleanup(func() {
+		staticDir = previous
+		assets.Lock()
+		assets.hashed, assets.original = hashed, original
+		assets.Unlock()
+	})
+	return dir
+}
+
+func TestFingerprintAssets(t *testing.T) {
+	dir := useStaticDir(t)
+	if got := asset("site.css"); got != "/static/site.css" {
+		t.Errorf("expected a plain URL before fingerprinting; got %s", got)
+	}
+	if err := FingerprintAssets(dir); err != nil {
+		t.Fatalf("FingerprintAssets() returned an error: %v", err)
+	}
+
+	css := asset("site.css")
+	if !strings.HasPrefix(css, "/static/site.") || !strings.HasSuffix(css, ".css") || len(css) != len("/static/site..css")+10 {
+		t.Errorf("unexpected fingerprinted URL %s", css)
+	}
+	if logo := asset("images/logo.png"); !strings.HasPrefix(logo, "/static/images/logo.") {
+		t.Errorf("unexpected fingerprinted URL %s", logo)
+	}
+	if got := asset("site.css.br"); got != "/static/site.css.br" {
+		t.Errorf("expected compressed variants to be skipped; got %s", got)
+	}
+	if got := asset("missing.css"); got != "/static/missing.css" {
+		t.Errorf("expected unknown files to keep their name; got %s", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "site.css"), []byte("body { color: red; }"), 0o644)
+	FingerprintAssets(dir)
+	if asset("site.css") == css {
+		t.Error("expected a changed file to get a new URL")
+	}
+}
+
+func TestStaticHandler(t *testing.T) {
+	dir := useStaticDir(t)
+	FingerprintAssets(dir)
+	handler := Routes()
+
+	tests := []struct {
+		name         string
+		path         string
+		encoding     string
+		wantStatus   int
+		wantCache    string
+		wantEncoding string
+		wantBody     string
+	}{
+		{"plain", "/static/site.css", "", http.StatusOK, staticCacheControl, "", "body { color: green; }"},
+		{"fingerprinted", asset("site.css"), "", http.StatusOK, immutableCacheControl, "", "body { color: green; }"},
+		{"brotli", asset("site.css"), "gzip, br", http.StatusOK, immutableCacheControl, "br", "brotli bytes"},
+		{"gzip", "/static/site.css", "gzip", http.StatusOK, staticCacheControl, "gzip", ""},
+		{"nested", asset("images/logo.png"), "gzip", http.StatusOK, immutableCacheControl, "", "png bytes"},
+		{"stale fingerprint", "/static/site.0123456789.css", "", http.StatusNotFound, "", "", ""},
+		{"directory", "/static/images/", "", http.StatusNotFound, "", "", ""},
+		{"outside", "/static/../handlers.go", "", http.StatusNotFound, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			r.Header.Set("Accept-Encoding", tt.encoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d; got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("expected Cache-Control %q; got %q", tt.wantCache, got)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("expected Content-Encoding %q; got %q", tt.wantEncoding, got)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") && strings.HasSuffix(tt.path, ".css") {
+				t.Errorf("expected a CSS content type; got %q", w.Header().Get("Content-Type"))
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q; got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseTemplateAssets(t *testing.T) {
+	dir := useStaticDir(t)
+	FingerprintAssets(dir)
+	page := filepath.Join(t.TempDir(), "page.html")
+	os.WriteFile(page, []byte(`<link href="{{asset "site.css"}}">`), 0o644)
+
+	temp, err := parseTemplate(page)
+	if err != nil {
+		t.Fatalf("parseTemplate() returned an error: %v", err)
+	}
+	var b strings.Builder
+	temp.Execute(&b, nil)
+	if want := `<link href="` + asset("site.css") + `">`; b.String() != want {
+		t.Errorf("expected %s; got %s", want, b.String())
+	}
+}
+
+func TestParseTemplateEscapes(t *testing.T) {
+	temp, err := parseTemplate("../template/artists.html")
+	if err != nil {
+		t.Fatalf("parseTemplate() returned an error: %v", err)
+	}
+	filter := ArtistFilter{Query: `"><script>alert(1)</script>`, Country: "UK"}
+	var b strings.Builder
+	err = temp.Execute(&b, ArtistsPage{
+		Filter: filter,
+		Query:  template.URL(filter.Values().Encode()),
+		User:   "<b>roger</b>",
+		Return: "/artists?q=<x>",
+	})
+	if err != nil {
+		t.Fatalf("Execute() returned an error: %v", err)
+	}
+
+	page := b.String()
+	for _, raw := range []string{"<script>", "<b>roger", "<x>"} {
+		if strings.Contains(page, raw) {
+			t.Errorf("expected %q to be escaped", raw)
+		}
+	}
+	if !strings.Contains(page, `&rows=artist&country=UK&amp;q=%22%3E%3Cscript%3E`) {
+		t.Errorf("expected the export links to keep the filter query; got %s", page)
+	}
+}
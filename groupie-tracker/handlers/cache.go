@@ -0,0 +1,210 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache lifetimes. Pages with the visitor's favorites or CSRF token may
+// only be kept by the browser; other pages may be shared. Both must be
+// revalidated on every use, which is cheap with their entity tags. JSON may
+// be reused for a minute, feeds, which readers poll, for five. Static files
+// change only with a deployment.
+const (
+	privateHTMLCacheControl = "private, no-cache"
+	sharedHTMLCacheControl  = "public, no-cache"
+	jsonCacheControl        = "public, max-age=60"
+	feedCacheControl        = "public, max-age=300"
+	staticCacheControl      = "public, max-age=86400"
+)
+
+/*
+validator is what the entity tag and Last-Modified date of a response are
+derived from. It must describe the data the body is built from: a page
+rendered from the dataset uses the dataset's, one rendered from values read
+from the provider uses those values'.
+*/
+type validator struct {
+	version  string
+	modified time.Time // zero when unknown
+}
+
+// datasetValidator is the validator of a representation built from d. It
+// is dated when d's content was stored, see Dataset.Modified.
+func datasetValidator(d *Dataset) validator {
+	return validator{version: d.Version(), modified: d.Modified()}
+}
+
+/*
+contentValidator is the validator of a representation built from v alone,
+such as the visitor's account. There is no modification date.
+*/
+func contentValidator(v interface{}) validator {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return validator{version: hex.EncodeToString(sum[:8])}
+}
+
+/*
+entityTag returns the strong entity tag of a representation of v, e.g.
+"3f2a1b9c0d4e5f60-artist12-json". parts tell apart the representations of
+one version, such as the artist and the media type. withCompression weakens
+the tag of gzipped responses, whose bytes differ.
+*/
+func entityTag(v validator, parts ...string) string {
+	return `"` + strings.Join(append([]string{v.version}, parts...), "-") + `"`
+}
+
+/*
+viewerTag summarises what a page shows about the visitor: their session
+(which the CSRF token comes from), account and favorites. It changes when
+any of them does, so a cached page never shows a stale favorite star.
+*/
+func viewerTag(r *http.Request) string {
+	var ids []int
+	for id := range favoriteSet(favoritesOwner(r)) {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	sum := sha256.Sum256([]byte(fmt.Sprint(sessionID(r), "|", currentUser(r), "|", ids)))
+	return hex.EncodeToString(sum[:6])
+}
+
+/*
+pageNotModified sets the caching headers of a response built from the data
+v describes that shows the visitor's favorites, account or CSRF token, as
+HTML or as JSON negotiated by wantsJSON, and reports whether the browser's
+copy is still current, in which case a 304 response has been sent. key
+names the page, e.g. "artist12".
+*/
+func pageNotModified(w http.ResponseWriter, r *http.Request, v validator, key string) bool {
+	media := "html"
+	if wantsJSON(r) {
+		media = "json"
+	}
+	w.Header().Set("Cache-Control", privateHTMLCacheControl)
+	w.Header().Add("Vary", "Accept, Cookie")
+	// No Last-Modified: favorites can change after the data did.
+	return notModified(w, r, entityTag(v, key, media, viewerTag(r)), time.Time{})
+}
+
+/*
+dataNotModified is pageNotModified for responses that are the same for
+every visitor: JSON, or HTML as negotiated by wantsJSON.
+*/
+func dataNotModified(w http.ResponseWriter, r *http.Request, v validator, key string) bool {
+	media, cacheControl := "html", sharedHTMLCacheControl
+	if wantsJSON(r) {
+		media, cacheControl = "json", jsonCacheControl
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Add("Vary", "Accept")
+	return notModified(w, r, entityTag(v, key, media), v.modified)
+}
+
+/*
+feedNotModified is dataNotModified for the feeds of upcoming concerts built
+from d, where media is "rss" or "atom". The feed also depends on its query
+and on the day, which ends past concerts, so those are part of the tag and
+the feed is modified at midnight at the latest.
+*/
+func feedNotModified(w http.ResponseWriter, r *http.Request, d *Dataset, media string, now time.Time) bool {
+	v := datasetValidator(d)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.After(v.modified) {
+		v.modified = today
+	}
+	query := sha256.Sum256([]byte(r.URL.Query().Encode()))
+	key := "upcoming" + today.Format("20060102") + hex.EncodeToString(query[:4])
+	w.Header().Set("Cache-Control", feedCacheControl)
+	return notModified(w, r, entityTag(v, key, media), v.modified)
+}
+
+/*
+notModified sets the ETag and, unless modified is zero, Last-Modified
+headers, then evaluates If-None-Match and If-Modified-Since as RFC 9110
+describes: If-Modified-Since only counts without If-None-Match, and only
+GET and HEAD requests get a 304 Not Modified.
+*/
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	match := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		match = etagMatches(inm, etag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			match = !modified.Truncate(time.Second).After(t)
+		}
+	}
+	if !match {
+		return false
+	}
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. The
+// comparison is weak, as If-None-Match requires: W/"x" matches "x".
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// artistKey names the representations of one artist in entity tags.
+func artistKey(id int) string {
+	return "artist" + strconv.Itoa(id)
+}
+
+// withStaticCaching adds the static files' cache lifetime to the
+// successful responses of h that do not set their own.
+func withStaticCaching(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&staticCacheWriter{ResponseWriter: w}, r)
+	})
+}
+
+type staticCacheWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (s *staticCacheWriter) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.wroteHeader = true
+		ok := status == http.StatusOK || status == http.StatusPartialContent || status == http.StatusNotModified
+		if ok && s.Header().Get("Cache-Control") == "" {
+			s.Header().Set("Cache-Control", staticCacheControl)
+		}
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *staticCacheWriter) Write(p []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK)
+	}
+	return s.ResponseWriter.Write(p)
+}
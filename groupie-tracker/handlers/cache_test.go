@@ -0,0 +1,296 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	useProvider(t, &MemoryProvider{data: useTestDataset(t)})
+	handler := Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/stats?format=json", nil))
+	etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || etag == "" || modified == "" {
+		t.Fatalf("expected a 200 with validators; got %d %q %q", w.Code, etag, modified)
+	}
+	if got := w.Header().Get("Cache-Control"); got != jsonCacheControl {
+		t.Errorf("expected Cache-Control %q; got %q", jsonCacheControl, got)
+	}
+	lastModified, _ := http.ParseTime(modified)
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		wantStatus int
+	}{
+		{"no conditions", "GET", nil, http.StatusOK},
+		{"matching etag", "GET", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"etag in a list", "GET", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"strong form", "GET", map[string]string{"If-None-Match": strings.TrimPrefix(etag, "W/")}, http.StatusNotModified},
+		{"any etag", "GET", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"other etag", "GET", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"head", "HEAD", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": modified}, http.StatusNotModified},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": before}, http.StatusOK},
+		{"invalid date", "GET", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+		{"etag wins over date", "GET", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/stats?format=json", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d; got %d", tt.wantStatus, w.Code)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("expected ETag %s; got %s", etag, w.Header().Get("ETag"))
+			}
+			if tt.wantStatus == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("Content-Type") != "") {
+				t.Errorf("expected an empty 304; got %q %q", w.Header().Get("Content-Type"), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestETags(t *testing.T) {
+	d := useTestDataset(t)
+	useProvider(t, &MemoryProvider{data: d})
+	handler := Routes()
+	etag := func(path string) string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Header().Get("ETag")
+	}
+
+	artist := etag("/artist/1?format=json")
+	if other := etag("/artist/2?format=json"); other == artist {
+		t.Error("expected artists to have different ETags")
+	}
+	stats := etag("/stats?format=json")
+	if stats == "" || stats == artist {
+		t.Errorf("expected a separate ETag for the statistics; got %q", stats)
+	}
+	if again := etag("/artist/1?format=json"); again != artist {
+		t.Errorf("expected a stable ETag; got %s then %s", artist, again)
+	}
+
+	// The same content fetched again keeps its ETag; changed content does not.
+	setDataset(NewDataset(d.Artists, datasetRelations(d), time.Now()))
+	if got := etag("/stats?format=json"); got != stats {
+		t.Errorf("expected a refetched dataset to keep the ETag; got %s then %s", stats, got)
+	}
+	useProvider(t, NewMemoryProvider(d.Artists, datasetRelations(d)))
+	if got := etag("/artist/1?format=json"); got != artist {
+		t.Errorf("expected a refetched artist to keep the ETag; got %s then %s", artist, got)
+	}
+
+	if want := `"` + d.Version() + `-artist1-json"`; artist != want {
+		t.Errorf("expected the strong ETag %s; got %s", want, artist)
+	}
+
+	// The body comes from the dataset the tag describes, not from a
+	// provider that has changed since.
+	artists := append([]Artist(nil), d.Artists...)
+	artists[0].Name = "Queen + Adam Lambert"
+	useProvider(t, NewMemoryProvider(artists, datasetRelations(d)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/artist/1?format=json", nil))
+	if w.Header().Get("ETag") != artist || strings.Contains(w.Body.String(), "Adam Lambert") {
+		t.Errorf("expected the body of the tagged dataset; got %s %s", w.Header().Get("ETag"), w.Body.String())
+	}
+
+	setDataset(NewDataset(artists, datasetRelations(d), time.Now()))
+	if got := etag("/stats?format=json"); got == stats {
+		t.Error("expected a changed dataset to change the ETag")
+	}
+	if got := etag("/artist/1?format=json"); got == artist {
+		t.Error("expected a changed dataset to change the artist's ETag")
+	}
+}
+
+func TestArtistIfModifiedSince(t *testing.T) {
+	useTempDataDir(t)
+	d := useTestDataset(t)
+	useProvider(t, &MemoryProvider{data: d})
+	s, err := datasetStore()
+	if err != nil {
+		t.Fatalf("datasetStore() returned an error: %v", err)
+	}
+	storedAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s.Put(NewDataset(d.Artists, datasetRelations(d), storedAt))
+	handler := Routes()
+
+	get := func(since time.Time) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/artist/1?format=json", nil)
+		if !since.IsZero() {
+			r.Header.Set("If-Modified-Since", since.Format(http.TimeFormat))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get(time.Time{})
+	if got, want := w.Header().Get("Last-Modified"), storedAt.Format(http.TimeFormat); w.Code != http.StatusOK || got != want {
+		t.Fatalf("expected a 200 modified when the dataset was stored, %s; got %d %s", want, w.Code, got)
+	}
+	if w := get(storedAt); w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since the version time = %d, want 304", w.Code)
+	}
+	if w := get(storedAt.Add(-time.Hour)); w.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since before the version time = %d, want 200", w.Code)
+	}
+}
+
+func TestFeedValidators(t *testing.T) {
+	d := useFeedDataset(t)
+	s, err := datasetStore()
+	if err != nil {
+		t.Fatalf("datasetStore() returned an error: %v", err)
+	}
+	s.Put(d)
+	handler := Routes()
+
+	get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	rss := get("/feeds/upcoming.rss", nil)
+	etag, modified := rss.Header().Get("ETag"), rss.Header().Get("Last-Modified")
+	if rss.Code != http.StatusOK || !strings.HasPrefix(etag, `"`+d.Version()+"-") || modified == "" {
+		t.Fatalf("expected a 200 with a strong ETag and Last-Modified; got %d %q %q", rss.Code, etag, modified)
+	}
+	if got := rss.Header().Get("Cache-Control"); got != feedCacheControl {
+		t.Errorf("expected Cache-Control %q; got %q", feedCacheControl, got)
+	}
+	if w := get("/feeds/upcoming.rss", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match the feed's ETag = %d, want an empty 304", w.Code)
+	}
+	if w := get("/feeds/upcoming.rss", map[string]string{"If-Modified-Since": modified}); w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since the feed's Last-Modified = %d, want 304", w.Code)
+	}
+
+	for _, path := range []string{"/feeds/upcoming.atom", "/feeds/upcoming.rss?country=uk"} {
+		w := get(path, map[string]string{"If-None-Match": etag})
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+			t.Errorf("%s: expected its own ETag; got %d %s", path, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestPersonalPageValidators(t *testing.T) {
+	useAccountTest(t)
+	b := newTestBrowser(t)
+	b.do("GET", "/register", nil)
+	b.do("POST", "/register", url.Values{"username": {"freddie"}, "password": {"bohemian-rhapsody"}, "confirm": {"bohemian-rhapsody"}})
+
+	for _, path := range []string{"/favorites?format=json", "/account?format=json", "/changes?format=json"} {
+		w := b.do("GET", path, nil)
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") == "" {
+			t.Errorf("%s: expected a 200 with caching headers; got %d %v", path, w.Code, w.Header())
+			continue
+		}
+		b.header = http.Header{"If-None-Match": {etag}}
+		if w := b.do("GET", path, nil); w.Code != http.StatusNotModified {
+			t.Errorf("%s: If-None-Match its ETag = %d, want 304", path, w.Code)
+		}
+		b.header = nil
+	}
+
+	w := b.do("GET", "/account?format=json", nil)
+	etag := w.Header().Get("ETag")
+	b.do("POST", "/account/searches", url.Values{"query": {"q=queen"}})
+	b.header = http.Header{"If-None-Match": {etag}}
+	if w := b.do("GET", "/account?format=json", nil); w.Code != http.StatusOK {
+		t.Errorf("expected a saved search to change the account's ETag; got %d", w.Code)
+	}
+}
+
+func TestPageETagFollowsFavorites(t *testing.T) {
+	useTempDataDir(t)
+	useTestSessionKey(t)
+	d := useTestDataset(t)
+
+	w := httptest.NewRecorder()
+	ensureSession(w, httptest.NewRequest("GET", "/", nil))
+	cookie := w.Result().Cookies()[0]
+	page := func(inm string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/artist/1", nil)
+		r.AddCookie(cookie)
+		if inm != "" {
+			r.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		pageNotModified(w, r, datasetValidator(d), artistKey(1))
+		return w
+	}
+
+	first := page("")
+	etag := first.Header().Get("ETag")
+	if got := first.Header().Get("Cache-Control"); got != privateHTMLCacheControl {
+		t.Errorf("expected Cache-Control %q; got %q", privateHTMLCacheControl, got)
+	}
+	if first.Header().Get("Last-Modified") != "" {
+		t.Error("expected no Last-Modified on a personal page")
+	}
+	if w := page(etag); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged page; got %d", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	setFavorite(favoritesOwner(r), 1, true)
+	if w := page(etag); w.Code == http.StatusNotModified || w.Header().Get("ETag") == etag {
+		t.Error("expected a new ETag after starring the artist")
+	}
+}
+
+func TestStaticCaching(t *testing.T) {
+	handler := withStaticCaching(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.css" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("body {}"))
+	}))
+
+	for path, want := range map[string]string{"/artists.css": staticCacheControl, "/missing.css": ""} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if got := w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("%s: expected Cache-Control %q; got %q", path, want, got)
+		}
+	}
+}
+
+func TestErrorsDropValidators(t *testing.T) {
+	useTestDataset(t)
+	w := httptest.NewRecorder()
+	// The template cannot be loaded from the test directory.
+	Routes().ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d; got %d", http.StatusInternalServerError, w.Code)
+	}
+	if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("expected no caching headers on an error; got %v", w.Header())
+	}
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxChangeLog is how many change sets the change log keeps.
+const maxChangeLog = 500
+
+// ArtistRef names an artist in a change set.
+type ArtistRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// MemberChange lists the members who joined or left an artist.
+type MemberChange struct {
+	Artist  ArtistRef `json:"artist"`
+	Added   []string  `json:"added"`
+	Removed []string  `json:"removed"`
+}
+
+/*
+ChangeSet is the structured difference between two datasets.
+Locations and dates are compared through the relations, which pair every
+location of an artist with its dates.
+*/
+type ChangeSet struct {
+	At                time.Time       `json:"at"`
+	ArtistsAdded      []ArtistRef     `json:"artistsAdded"`
+	ArtistsRemoved    []ArtistRef     `json:"artistsRemoved"`
+	MembersChanged    []MemberChange  `json:"membersChanged"`
+	ConcertsAdded     []ArtistConcert `json:"concertsAdded"`
+	ConcertsCancelled []ArtistConcert `json:"concertsCancelled"`
+}
+
+// Empty reports whether nothing changed.
+func (c ChangeSet) Empty() bool {
+	return len(c.ArtistsAdded) == 0 && len(c.ArtistsRemoved) == 0 && len(c.MembersChanged) == 0 &&
+		len(c.ConcertsAdded) == 0 && len(c.ConcertsCancelled) == 0
+}
+
+/*
+DiffDatasets compares the previous dataset with the next one.
+A nil previous dataset yields an empty change set, as there is nothing to
+compare the first fetch with. Concerts of added or removed artists are not
+listed separately.
+*/
+func DiffDatasets(previous, next *Dataset) ChangeSet {
+	changes := ChangeSet{
+		At:                next.FetchedAt,
+		ArtistsAdded:      []ArtistRef{},
+		ArtistsRemoved:    []ArtistRef{},
+		MembersChanged:    []MemberChange{},
+		ConcertsAdded:     []ArtistConcert{},
+		ConcertsCancelled: []ArtistConcert{},
+	}
+	if previous == nil {
+		return changes
+	}
+
+	for _, a := range previous.Artists {
+		if _, ok := next.Artist(a.ID); !ok {
+			changes.ArtistsRemoved = append(changes.ArtistsRemoved, ArtistRef{ID: a.ID, Name: a.Name})
+		}
+	}
+
+	for _, a := range next.Artists {
+		old, ok := previous.Artist(a.ID)
+		if !ok {
+			changes.ArtistsAdded = append(changes.ArtistsAdded, ArtistRef{ID: a.ID, Name: a.Name})
+			continue
+		}
+		ref := ArtistRef{ID: a.ID, Name: a.Name}
+
+		added, removed := diffStrings(old.Members, a.Members)
+		if len(added) > 0 || len(removed) > 0 {
+			changes.MembersChanged = append(changes.MembersChanged, MemberChange{Artist: ref, Added: added, Removed: removed})
+		}
+
+		before := concertKeys(previous.Concerts(a.ID))
+		after := concertKeys(next.Concerts(a.ID))
+		for key, c := range after {
+			if _, ok := before[key]; !ok {
+				changes.ConcertsAdded = append(changes.ConcertsAdded, ArtistConcert{ArtistID: a.ID, ArtistName: a.Name, Concert: c})
+			}
+		}
+		for key, c := range before {
+			if _, ok := after[key]; !ok {
+				changes.ConcertsCancelled = append(changes.ConcertsCancelled, ArtistConcert{ArtistID: a.ID, ArtistName: a.Name, Concert: c})
+			}
+		}
+	}
+
+	sortArtistConcerts(changes.ConcertsAdded)
+	sortArtistConcerts(changes.ConcertsCancelled)
+	return changes
+}
+
+func concertKeys(concerts []Concert) map[string]Concert {
+	keys := make(map[string]Concert, len(concerts))
+	for _, c := range concerts {
+		keys[c.Location+"|"+c.Date.Format("2006-01-02")] = c
+	}
+	return keys
+}
+
+func sortArtistConcerts(list []ArtistConcert) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ArtistID != list[j].ArtistID {
+			return list[i].ArtistID < list[j].ArtistID
+		}
+		if !list[i].Concert.Date.Equal(list[j].Concert.Date) {
+			return list[i].Concert.Date.Before(list[j].Concert.Date)
+		}
+		return list[i].Concert.Location < list[j].Concert.Location
+	})
+}
+
+// diffStrings returns the values only in next (added) and only in previous (removed).
+func diffStrings(previous, next []string) (added, removed []string) {
+	inPrevious := map[string]bool{}
+	for _, s := range previous {
+		inPrevious[s] = true
+	}
+	inNext := map[string]bool{}
+	for _, s := range next {
+		inNext[s] = true
+		if !inPrevious[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range previous {
+		if !inNext[s] {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+// changeLogMu guards the change log file.
+var changeLogMu sync.Mutex
+
+func changeLogPath() string {
+	return filepath.Join(dataDir, "changes.json")
+}
+
+// readChangeLog returns the persisted change sets, oldest first.
+func readChangeLog() ([]ChangeSet, error) {
+	changeLogMu.Lock()
+	defer changeLogMu.Unlock()
+	return readChangeLogLocked()
+}
+
+func readChangeLogLocked() ([]ChangeSet, error) {
+	var entries []ChangeSet
+	err := readJSONFile(changeLogPath(), &entries)
+	if os.IsNotExist(err) {
+		return []ChangeSet{}, nil
+	}
+	return entries, err
+}
+
+// appendChangeLog persists a change set, keeping the last maxChangeLog.
+func appendChangeLog(c ChangeSet) error {
+	changeLogMu.Lock()
+	defer changeLogMu.Unlock()
+
+	entries, err := readChangeLogLocked()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, c)
+	if len(entries) > maxChangeLog {
+		entries = entries[len(entries)-maxChangeLog:]
+	}
+	return writeJSONFile(changeLogPath(), entries)
+}
+
+/*
+ChangesHandler shows the change log, newest change first, or returns it as
+JSON for ?format=json or a JSON Accept header.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func ChangesHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := readChangeLog()
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error reading the change log")
+		return
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	v := contentValidator(entries)
+	if len(entries) > 0 {
+		v.modified = entries[0].At
+	}
+	if dataNotModified(w, r, v, "changes") {
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	temp, err := parseTemplate("template/changes.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+
+	err = temp.Execute(w, entries)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
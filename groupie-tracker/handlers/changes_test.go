@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffDatasets(t *testing.T) {
+	previous := newTestDataset()
+
+	artists := append([]Artist(nil), previous.Artists...)
+	artists[0].Members = []string{"Freddie Mercury", "Brian May", "Adam Lambert"} // Queen
+	artists = append(artists[:2], Artist{ID: 4, Name: "Gorillaz"})                // Scorpions removed
+	relations := []Relation{
+		{ID: 1, Locations: map[string][]string{"london-uk": {"20-11-2019"}, "tokyo-japan": {"01-02-2020"}}},
+		{ID: 2, Locations: map[string][]string{"london-uk": {"22-11-2019"}, "paris-france": {"01-01-2020"}}},
+		{ID: 4, Locations: map[string][]string{"berlin-germany": {"01-03-2020"}}},
+	}
+	next := NewDataset(artists, relations, previous.FetchedAt.Add(time.Hour))
+
+	got := DiffDatasets(previous, next)
+
+	if len(got.ArtistsAdded) != 1 || got.ArtistsAdded[0].Name != "Gorillaz" {
+		t.Errorf("ArtistsAdded = %+v, want Gorillaz", got.ArtistsAdded)
+	}
+	if len(got.ArtistsRemoved) != 1 || got.ArtistsRemoved[0].Name != "Scorpions" {
+		t.Errorf("ArtistsRemoved = %+v, want Scorpions", got.ArtistsRemoved)
+	}
+	if len(got.MembersChanged) != 1 {
+		t.Fatalf("MembersChanged = %+v, want one change", got.MembersChanged)
+	}
+	mc := got.MembersChanged[0]
+	if mc.Artist.ID != 1 || len(mc.Added) != 1 || mc.Added[0] != "Adam Lambert" || len(mc.Removed) != 1 || mc.Removed[0] != "Roger Taylor" {
+		t.Errorf("unexpected member change %+v", mc)
+	}
+	if len(got.ConcertsAdded) != 1 || got.ConcertsAdded[0].Concert.Location != "tokyo-japan" {
+		t.Errorf("ConcertsAdded = %+v, want Tokyo", got.ConcertsAdded)
+	}
+	if len(got.ConcertsCancelled) != 1 || got.ConcertsCancelled[0].Concert.Location != "osaka-japan" {
+		t.Errorf("ConcertsCancelled = %+v, want Osaka", got.ConcertsCancelled)
+	}
+	if got.Empty() {
+		t.Error("Empty() = true for a change set with changes")
+	}
+	if !DiffDatasets(nil, next).Empty() || !DiffDatasets(next, next).Empty() {
+		t.Error("expected no changes without a previous dataset or between equal datasets")
+	}
+}
+
+func TestChangeLog(t *testing.T) {
+	useTempDataDir(t)
+
+	entries, err := readChangeLog()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("readChangeLog() on an empty directory = %v, %v", entries, err)
+	}
+
+	for i := 0; i < maxChangeLog+2; i++ {
+		c := ChangeSet{At: time.Unix(int64(i), 0).UTC(), ArtistsAdded: []ArtistRef{{ID: i}}}
+		if err := appendChangeLog(c); err != nil {
+			t.Fatalf("appendChangeLog() returned an error: %v", err)
+		}
+	}
+
+	entries, err = readChangeLog()
+	if err != nil {
+		t.Fatalf("readChangeLog() returned an error: %v", err)
+	}
+	if len(entries) != maxChangeLog || entries[0].ArtistsAdded[0].ID != 2 {
+		t.Errorf("expected the oldest entries to be dropped, got %d entries starting at %d", len(entries), entries[0].ArtistsAdded[0].ID)
+	}
+}
+
+func TestChangesHandler(t *testing.T) {
+	useTempDataDir(t)
+	appendChangeLog(ChangeSet{At: time.Unix(1, 0).UTC(), ArtistsAdded: []ArtistRef{{ID: 1, Name: "Queen"}}})
+	appendChangeLog(ChangeSet{At: time.Unix(2, 0).UTC(), ArtistsRemoved: []ArtistRef{{ID: 2, Name: "Pink Floyd"}}})
+
+	w := httptest.NewRecorder()
+	ChangesHandler(w, httptest.NewRequest("GET", "/changes?format=json", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d; got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	if strings.Index(body, "Pink Floyd") > strings.Index(body, "Queen") {
+		t.Errorf("expected the newest change first; got %s", body)
+	}
+}
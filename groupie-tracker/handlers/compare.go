@@ -0,0 +1,222 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCompared is the number of artists the compare page shows side by side.
+const maxCompared = 4
+
+// ComparedArtist is one column of the comparison.
+type ComparedArtist struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Image        string   `json:"image"`
+	Members      []string `json:"members"`
+	CreationDate int      `json:"creationDate"`
+	FirstAlbum   string   `json:"firstAlbum"`
+	ConcertCount int      `json:"concertCount"`
+	Countries    []string `json:"countries"`
+}
+
+// SharedLocation is a location played by more than one compared artist.
+type SharedLocation struct {
+	Location string   `json:"location"`
+	City     string   `json:"city"`
+	Country  string   `json:"country"`
+	Artists  []string `json:"artists"`
+}
+
+// OverlappingDate is a day on which more than one compared artist played.
+type OverlappingDate struct {
+	Date     string    `json:"date"`
+	Concerts []Concert `json:"concerts"`
+	Artists  []string  `json:"artists"`
+}
+
+// Comparison is the data behind the compare page and its JSON variant.
+type Comparison struct {
+	Artists          []ComparedArtist  `json:"artists"`
+	SharedLocations  []SharedLocation  `json:"sharedLocations"`
+	OverlappingDates []OverlappingDate `json:"overlappingDates"`
+}
+
+/*
+parseCompareIDs reads the comma separated ids parameter.
+It rejects non-numeric IDs and more than maxCompared distinct artists,
+and drops duplicates while keeping the original order.
+*/
+func parseCompareIDs(raw string) ([]int, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !isPositiveInt(part) {
+			return nil, fmt.Errorf("invalid ids: expected artist IDs separated by commas")
+		}
+		id, _ := strconv.Atoi(part)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no artist IDs given")
+	}
+	if len(ids) > maxCompared {
+		return nil, fmt.Errorf("at most %d artists can be compared", maxCompared)
+	}
+	return ids, nil
+}
+
+/*
+BuildComparison lines up the given artists and finds the locations and
+concert days they have in common. The result keeps the order of details.
+*/
+func BuildComparison(details []ArtistDetail) Comparison {
+	comparison := Comparison{
+		Artists:          []ComparedArtist{},
+		SharedLocations:  []SharedLocation{},
+		OverlappingDates: []OverlappingDate{},
+	}
+
+	locationArtists := map[string][]string{}
+	dateConcerts := map[string][]Concert{}
+	dateArtists := map[string][]string{}
+
+	for _, d := range details {
+		countries := []string{}
+		seenCountry := map[string]bool{}
+		seenLocation := map[string]bool{}
+		seenDate := map[string]bool{}
+		for _, c := range d.Concerts {
+			if !seenCountry[c.Country] {
+				seenCountry[c.Country] = true
+				countries = append(countries, c.Country)
+			}
+			if !seenLocation[c.Location] {
+				seenLocation[c.Location] = true
+				locationArtists[c.Location] = append(locationArtists[c.Location], d.Artist.Name)
+			}
+			day := c.Date.Format("2006-01-02")
+			dateConcerts[day] = append(dateConcerts[day], c)
+			if !seenDate[day] {
+				seenDate[day] = true
+				dateArtists[day] = append(dateArtists[day], d.Artist.Name)
+			}
+		}
+		sort.Strings(countries)
+
+		comparison.Artists = append(comparison.Artists, ComparedArtist{
+			ID:           d.Artist.ID,
+			Name:         d.Artist.Name,
+			Image:        d.Artist.Image,
+			Members:      d.Artist.Members,
+			CreationDate: d.Artist.CreationDate,
+			FirstAlbum:   d.Artist.FirstAlbum,
+			ConcertCount: len(d.Concerts),
+			Countries:    countries,
+		})
+	}
+
+	for location, artists := range locationArtists {
+		if len(artists) < 2 {
+			continue
+		}
+		city, country := ParseLocation(location)
+		comparison.SharedLocations = append(comparison.SharedLocations, SharedLocation{
+			Location: location, City: city, Country: country, Artists: artists,
+		})
+	}
+	sort.Slice(comparison.SharedLocations, func(i, j int) bool {
+		return comparison.SharedLocations[i].Location < comparison.SharedLocations[j].Location
+	})
+
+	for day, artists := range dateArtists {
+		if len(artists) < 2 {
+			continue
+		}
+		comparison.OverlappingDates = append(comparison.OverlappingDates, OverlappingDate{
+			Date: day, Concerts: dateConcerts[day], Artists: artists,
+		})
+	}
+	sort.Slice(comparison.OverlappingDates, func(i, j int) bool {
+		return comparison.OverlappingDates[i].Date < comparison.OverlappingDates[j].Date
+	})
+
+	return comparison
+}
+
+// readArtistDetails builds the details of several artists from d, in the
+// order of ids.
+func readArtistDetails(d *Dataset, ids []int) ([]ArtistDetail, error) {
+	details := make([]ArtistDetail, len(ids))
+	for i, id := range ids {
+		detail, err := readArtistDetail(d, id)
+		if err != nil {
+			return nil, err
+		}
+		details[i] = detail
+	}
+	return details, nil
+}
+
+/*
+CompareHandler renders up to four artists side by side for
+/compare?ids=1,5,12. With ?format=json or an Accept header asking for
+JSON it returns the same comparison as JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	ids, err := parseCompareIDs(r.URL.Query().Get("ids"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	details, err := readArtistDetails(data, ids)
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that page")
+		return
+	}
+	key := "compare"
+	for _, id := range ids {
+		key += "." + strconv.Itoa(id)
+	}
+	if dataNotModified(w, r, datasetValidator(data), key) {
+		return
+	}
+
+	comparison := BuildComparison(details)
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, comparison)
+		return
+	}
+
+	temp, err := parseTemplate("template/compare.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+
+	err = temp.Execute(w, comparison)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
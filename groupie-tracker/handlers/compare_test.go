@@ -0,0 +1,77 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCompareIDs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []int
+		wantErr bool
+	}{
+		{"1,5,12", []int{1, 5, 12}, false},
+		{" 3 , 3, 4 ", []int{3, 4}, false},
+		{"1,2,3,4", []int{1, 2, 3, 4}, false},
+		{"1,2,3,4,5", nil, true},
+		{"1,abc", nil, true},
+		{"1,<b>2</b>", nil, true},
+		{"0", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseCompareIDs(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCompareIDs(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), "<") {
+				t.Errorf("parseCompareIDs(%q) error %q echoes the input", tt.raw, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseCompareIDs(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseCompareIDs(%q) = %v, want %v", tt.raw, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildComparison(t *testing.T) {
+	details := []ArtistDetail{
+		{
+			Artist: Artist{ID: 1, Name: "Queen"},
+			Concerts: BuildConcerts(Relation{ID: 1, Locations: map[string][]string{
+				"london-uk":    {"20-11-2019"},
+				"new_york-usa": {"05-10-2019"},
+			}}),
+		},
+		{
+			Artist: Artist{ID: 2, Name: "Pink Floyd"},
+			Concerts: BuildConcerts(Relation{ID: 2, Locations: map[string][]string{
+				"london-uk":   {"01-01-2020"},
+				"osaka-japan": {"05-10-2019"},
+			}}),
+		},
+	}
+
+	got := BuildComparison(details)
+
+	if len(got.Artists) != 2 || got.Artists[0].ConcertCount != 2 {
+		t.Fatalf("unexpected artists: %+v", got.Artists)
+	}
+	if countries := got.Artists[1].Countries; len(countries) != 2 || countries[0] != "Japan" || countries[1] != "UK" {
+		t.Errorf("Countries = %v, want [Japan UK]", countries)
+	}
+	if len(got.SharedLocations) != 1 || got.SharedLocations[0].Location != "london-uk" {
+		t.Errorf("SharedLocations = %+v, want london-uk only", got.SharedLocations)
+	}
+	if len(got.OverlappingDates) != 1 || got.OverlappingDates[0].Date != "2019-10-05" || len(got.OverlappingDates[0].Artists) != 2 {
+		t.Errorf("OverlappingDates = %+v, want 2019-10-05 for both artists", got.OverlappingDates)
+	}
+}
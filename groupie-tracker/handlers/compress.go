@@ -0,0 +1,168 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the media types worth compressing; images and
+// fonts are compressed already.
+var compressibleTypes = []string{
+	"text/html", "text/css", "text/plain", "text/javascript", "text/xml",
+	"application/json", "application/problem+json", "application/javascript",
+	"application/xml", "application/rss+xml", "application/atom+xml",
+	"application/x-ndjson", "image/svg+xml",
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range compressibleTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+acceptsEncoding reports whether the Accept-Encoding header allows coding.
+An explicit entry wins over "*"; a quality of 0 refuses the coding.
+*/
+func acceptsEncoding(r *http.Request, coding string) bool {
+	explicit, wildcard := -1.0, -1.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = v
+				}
+			}
+		}
+		switch name {
+		case coding:
+			explicit = quality
+		case "*":
+			wildcard = quality
+		}
+	}
+	if explicit >= 0 {
+		return explicit > 0
+	}
+	return wildcard > 0
+}
+
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+/*
+withCompression gzips the responses of h for clients that accept it, when
+their content type is worth compressing and they are not encoded already
+(the static files may be served pre-compressed). Entity tags of compressed
+responses are made weak, as the bytes differ from the identity encoding;
+If-None-Match still matches them.
+*/
+func withCompression(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsEncoding(r, "gzip") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.finish()
+		h.ServeHTTP(gw, r)
+	})
+}
+
+/*
+gzipWriter decides on the first write whether to compress, once the
+handler has set the status and content type.
+*/
+type gzipWriter struct {
+	http.ResponseWriter
+	status  int
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	if !g.decided {
+		g.decide(p)
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+func (g *gzipWriter) decide(p []byte) {
+	g.decided = true
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	h := g.Header()
+	ct := h.Get("Content-Type")
+	if ct == "" && len(p) > 0 {
+		ct = http.DetectContentType(p)
+		h.Set("Content-Type", ct)
+	}
+	// 206 bodies are byte ranges of the identity encoding.
+	if g.status != http.StatusPartialContent && h.Get("Content-Encoding") == "" && compressible(ct) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag)
+		}
+		g.gz = gzipWriters.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+}
+
+// Flush sends what has been compressed so far, for streamed responses.
+func (g *gzipWriter) Flush() {
+	if !g.decided {
+		g.decide(nil)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipWriter) finish() {
+	if !g.decided {
+		// nothing written: a 304, a redirect without body or an empty page.
+		// The 200 a 304 stands for would have been gzipped: entity tags
+		// are only set on compressible responses.
+		if g.status == http.StatusNotModified {
+			if etag := g.Header().Get("ETag"); strings.HasPrefix(etag, `"`) {
+				g.Header().Set("ETag", "W/"+etag)
+			}
+		}
+		if g.status != 0 {
+			g.ResponseWriter.WriteHeader(g.status)
+		}
+		return
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		g.gz.Reset(nil)
+		gzipWriters.Put(g.gz)
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		coding string
+		want   bool
+	}{
+		{"", "gzip", false},
+		{"gzip, deflate, br", "gzip", true},
+		{"gzip, deflate, br", "br", true},
+		{"deflate", "gzip", false},
+		{"GZIP;q=0.5", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"*", "br", true},
+		{"*, gzip;q=0", "gzip", false},
+		{"identity;q=1, *;q=0", "gzip", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsEncoding(r, tt.coding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.coding, got, tt.want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	body := strings.Repeat("Bohemian Rhapsody ", 100)
+	handler := withCompression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("ETag", `"v1"`)
+			writeJSON(w, http.StatusOK, body)
+		case "/sniffed":
+			w.Write([]byte("<!DOCTYPE html><p>" + body))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(body))
+		case "/encoded":
+			w.Header().Set("Content-Type", "text/css")
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(body))
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+
+	tests := []struct {
+		name         string
+		path         string
+		accept       string
+		wantEncoding string
+	}{
+		{"json", "/json", "gzip, br", "gzip"},
+		{"sniffed html", "/sniffed", "gzip", "gzip"},
+		{"not accepted", "/json", "br", ""},
+		{"image", "/image", "gzip", ""},
+		{"already encoded", "/encoded", "gzip", "br"},
+		{"no body", "/not-modified", "gzip", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("expected Content-Encoding %q; got %q", tt.wantEncoding, got)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("expected Vary: Accept-Encoding; got %q", got)
+			}
+			if tt.wantEncoding != "gzip" {
+				return
+			}
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("invalid gzip body: %v", err)
+			}
+			got, _ := io.ReadAll(zr)
+			if !strings.Contains(string(got), body) {
+				t.Errorf("unexpected body %q", got)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/json", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Header().Get("ETag"); got != `W/"v1"` {
+		t.Errorf("expected a weak ETag on the compressed response; got %q", got)
+	}
+
+	r.URL.Path = "/not-modified"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected the status to pass through; got %d", w.Code)
+	}
+}
+
+func TestCompressedConditionalRequest(t *testing.T) {
+	useProvider(t, &MemoryProvider{data: useTestDataset(t)})
+	handler := Routes()
+
+	r := httptest.NewRequest("GET", "/artist/1?format=json", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(etag, "W/") {
+		t.Fatalf("expected a gzipped response with a weak ETag; got %v", w.Header())
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected an empty 304 for the weak ETag; got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("expected the 304 to carry the ETag of the 200, %s; got %s", etag, got)
+	}
+}
+
+func TestCompressionFlushes(t *testing.T) {
+	handler := withCompression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("expected the writer to support flushing")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+	}))
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "" || !w.Flushed || w.Body.String() != "data: 1\n\n" {
+		t.Errorf("expected the event stream to pass through uncompressed; got %v %q", w.Header(), w.Body.String())
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Concert is a single show: one location on one date.
+type Concert struct {
+	Location string    `json:"location"` // raw upstream key, e.g. "north_carolina-usa"
+	City     string    `json:"city"`
+	Country  string    `json:"country"`
+	Date     time.Time `json:"date"`
+	// Corrected is set when a local override renamed or added the concert.
+	Corrected bool `json:"corrected,omitempty"`
+}
+
+// DateString formats the concert date the way the pages display it.
+func (c Concert) DateString() string {
+	return c.Date.Format("02 Jan 2006")
+}
+
+// CountryConcerts groups the concerts played in one country.
+type CountryConcerts struct {
+	Country  string    `json:"country"`
+	Concerts []Concert `json:"concerts"`
+}
+
+// ConcertHistory is an artist's full concert list split into upcoming and
+// past shows, each grouped by country.
+type ConcertHistory struct {
+	Upcoming []CountryConcerts `json:"upcoming"`
+	Past     []CountryConcerts `json:"past"`
+	Total    int               `json:"total"`
+}
+
+// upper-cased country codes that a plain title-case would get wrong.
+var countryAcronyms = map[string]string{
+	"usa": "USA",
+	"uk":  "UK",
+}
+
+/*
+ParseLocation splits an upstream location key such as "north_carolina-usa"
+into a readable city and country ("North Carolina", "USA").
+Keys without a country part return an empty country.
+*/
+func ParseLocation(raw string) (city, country string) {
+	raw = strings.TrimSpace(raw)
+	idx := strings.LastIndex(raw, "-")
+	if idx < 0 {
+		return titleWords(raw), ""
+	}
+	city = titleWords(raw[:idx])
+	c := raw[idx+1:]
+	if acronym, ok := countryAcronyms[strings.ToLower(c)]; ok {
+		return city, acronym
+	}
+	return city, titleWords(c)
+}
+
+func titleWords(s string) string {
+	words := strings.Fields(strings.ReplaceAll(s, "_", " "))
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = strings.ToUpper(string(r)) + strings.ToLower(w[size:])
+	}
+	return strings.Join(words, " ")
+}
+
+/*
+ParseConcertDate parses a concert date as returned by the API.
+The API uses "DD-MM-YYYY" and marks some dates with a leading "*";
+ISO "YYYY-MM-DD" dates are accepted as well.
+*/
+func ParseConcertDate(raw string) (time.Time, error) {
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "*")
+	for _, layout := range []string{"02-01-2006", "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid concert date: %q", raw)
+}
+
+/*
+BuildConcerts flattens a Relation into a list of concerts sorted by date.
+Dates that cannot be parsed are skipped. Dates listed in rel.Corrected give
+concerts with Corrected set.
+*/
+func BuildConcerts(rel Relation) []Concert {
+	var concerts []Concert
+	for location, dates := range rel.Locations {
+		city, country := ParseLocation(location)
+		for _, d := range dates {
+			date, err := ParseConcertDate(d)
+			if err != nil {
+				continue
+			}
+			concerts = append(concerts, Concert{
+				Location:  location,
+				City:      city,
+				Country:   country,
+				Date:      date,
+				Corrected: containsDate(rel.Corrected[location], d),
+			})
+		}
+	}
+	sort.Slice(concerts, func(i, j int) bool {
+		if !concerts[i].Date.Equal(concerts[j].Date) {
+			return concerts[i].Date.Before(concerts[j].Date)
+		}
+		return concerts[i].Location < concerts[j].Location
+	})
+	return concerts
+}
+
+/*
+historyOf splits concerts sorted by date into a ConcertHistory.
+Concerts on or after the day of now are upcoming, the rest are past.
+Upcoming shows are listed soonest first, past shows most recent first.
+*/
+func historyOf(concerts []Concert, now time.Time) ConcertHistory {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var upcoming, past []Concert
+	for _, c := range concerts {
+		if c.Date.Before(today) {
+			past = append(past, c)
+		} else {
+			upcoming = append(upcoming, c)
+		}
+	}
+	for i, j := 0, len(past)-1; i < j; i, j = i+1, j-1 {
+		past[i], past[j] = past[j], past[i]
+	}
+
+	return ConcertHistory{
+		Upcoming: groupByCountry(upcoming),
+		Past:     groupByCountry(past),
+		Total:    len(concerts),
+	}
+}
+
+// groupByCountry keeps the order of concerts and orders countries by their
+// first appearance.
+func groupByCountry(concerts []Concert) []CountryConcerts {
+	var groups []CountryConcerts
+	index := map[string]int{}
+	for _, c := range concerts {
+		i, ok := index[c.Country]
+		if !ok {
+			i = len(groups)
+			index[c.Country] = i
+			groups = append(groups, CountryConcerts{Country: c.Country})
+		}
+		groups[i].Concerts = append(groups[i].Concerts, c)
+	}
+	return groups
+}
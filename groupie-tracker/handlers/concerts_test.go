@@ -0,0 +1,69 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		raw         string
+		wantCity    string
+		wantCountry string
+	}{
+		{"north_carolina-usa", "North Carolina", "USA"},
+		{"london-uk", "London", "UK"},
+		{"penrose-new_zealand", "Penrose", "New Zealand"},
+		{"paris", "Paris", ""},
+		{"élysée-france", "Élysée", "France"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			city, country := ParseLocation(tt.raw)
+			if city != tt.wantCity || country != tt.wantCountry {
+				t.Errorf("ParseLocation(%q) = %q, %q; want %q, %q", tt.raw, city, country, tt.wantCity, tt.wantCountry)
+			}
+		})
+	}
+}
+
+func TestParseConcertDate(t *testing.T) {
+	want := time.Date(2019, time.August, 23, 0, 0, 0, 0, time.UTC)
+	for _, raw := range []string{"23-08-2019", "*23-08-2019", "2019-08-23"} {
+		got, err := ParseConcertDate(raw)
+		if err != nil {
+			t.Errorf("ParseConcertDate(%q) returned an error: %v", raw, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseConcertDate(%q) = %v, want %v", raw, got, want)
+		}
+	}
+
+	if _, err := ParseConcertDate("not a date"); err == nil {
+		t.Error("ParseConcertDate() expected an error for an invalid date")
+	}
+}
+
+func TestHistoryOf(t *testing.T) {
+	rel := Relation{ID: 1, Locations: map[string][]string{
+		"london-uk":      {"20-11-2019", "05-01-2030"},
+		"new_york-usa":   {"05-10-2019"},
+		"osaka-japan":    {"01-02-2031"},
+		"broken-country": {"garbage"},
+	}}
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	got := historyOf(BuildConcerts(rel), now)
+
+	if got.Total != 4 {
+		t.Errorf("Total = %d, want 4", got.Total)
+	}
+	if len(got.Upcoming) != 2 || got.Upcoming[0].Country != "UK" || got.Upcoming[1].Country != "Japan" {
+		t.Errorf("Upcoming = %+v, want UK then Japan", got.Upcoming)
+	}
+	if len(got.Past) != 2 || got.Past[0].Country != "UK" || got.Past[1].Country != "USA" {
+		t.Errorf("Past = %+v, want UK (most recent) then USA", got.Past)
+	}
+}
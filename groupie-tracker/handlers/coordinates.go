@@ -0,0 +1,311 @@
+package api
+
+// Coordinates is a point on Earth in decimal degrees.
+type Coordinates struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+/*
+locationCoordinates maps upstream location keys to approximate coordinates
+so tour distances can be computed without a geocoding service.
+US states and Australian states point at their largest concert city.
+Locations missing from this table are reported as unknown by the analytics.
+*/
+var locationCoordinates = map[string]Coordinates{
+	// United States
+	"alabama-usa":          {33.52, -86.80},
+	"alaska-usa":           {61.22, -149.90},
+	"arizona-usa":          {33.45, -112.07},
+	"arkansas-usa":         {34.75, -92.29},
+	"california-usa":       {34.05, -118.24},
+	"colorado-usa":         {39.74, -104.99},
+	"connecticut-usa":      {41.76, -72.69},
+	"del_mar-usa":          {32.96, -117.27},
+	"delaware-usa":         {39.74, -75.55},
+	"florida-usa":          {25.76, -80.19},
+	"georgia-usa":          {33.75, -84.39},
+	"hawaii-usa":           {21.31, -157.86},
+	"idaho-usa":            {43.62, -116.20},
+	"illinois-usa":         {41.88, -87.63},
+	"indiana-usa":          {39.77, -86.16},
+	"iowa-usa":             {41.59, -93.62},
+	"kansas-usa":           {37.69, -97.34},
+	"kentucky-usa":         {38.25, -85.76},
+	"las_vegas-usa":        {36.17, -115.14},
+	"los_angeles-usa":      {34.05, -118.24},
+	"louisiana-usa":        {29.95, -90.07},
+	"maine-usa":            {43.66, -70.26},
+	"maryland-usa":         {39.29, -76.61},
+	"massachusetts-usa":    {42.36, -71.06},
+	"michigan-usa":         {42.33, -83.05},
+	"minnesota-usa":        {44.98, -93.27},
+	"mississippi-usa":      {32.30, -90.18},
+	"missouri-usa":         {38.63, -90.20},
+	"montana-usa":          {45.78, -108.50},
+	"nebraska-usa":         {41.26, -95.93},
+	"nevada-usa":           {36.17, -115.14},
+	"new_hampshire-usa":    {42.99, -71.46},
+	"new_jersey-usa":       {40.74, -74.17},
+	"new_mexico-usa":       {35.08, -106.65},
+	"new_york-usa":         {40.71, -74.01},
+	"north_carolina-usa":   {35.23, -80.84},
+	"north_dakota-usa":     {46.88, -96.79},
+	"ohio-usa":             {39.96, -82.99},
+	"oklahoma-usa":         {35.47, -97.52},
+	"oregon-usa":           {45.52, -122.68},
+	"pennsylvania-usa":     {39.95, -75.17},
+	"rhode_island-usa":     {41.82, -71.41},
+	"south_carolina-usa":   {32.78, -79.93},
+	"south_dakota-usa":     {43.55, -96.73},
+	"tennessee-usa":        {36.16, -86.78},
+	"texas-usa":            {29.76, -95.37},
+	"utah-usa":             {40.76, -111.89},
+	"vermont-usa":          {44.48, -73.21},
+	"virginia-usa":         {37.54, -77.44},
+	"washington-usa":       {47.61, -122.33},
+	"west_virginia-usa":    {38.35, -81.63},
+	"wisconsin-usa":        {43.04, -87.91},
+	"wyoming-usa":          {41.14, -104.82},
+	"san_francisco-usa":    {37.77, -122.42},
+	"chicago-usa":          {41.88, -87.63},
+	"seattle-usa":          {47.61, -122.33},
+	"boston-usa":           {42.36, -71.06},
+	"atlanta-usa":          {33.75, -84.39},
+	"houston-usa":          {29.76, -95.37},
+	"dallas-usa":           {32.78, -96.80},
+	"miami-usa":            {25.76, -80.19},
+	"denver-usa":           {39.74, -104.99},
+	"philadelphia-usa":     {39.95, -75.17},
+	"washington_dc-usa":    {38.91, -77.04},
+	"san_juan-puerto_rico": {18.47, -66.11},
+
+	// Canada and Latin America
+	"toronto-canada":                   {43.65, -79.38},
+	"montreal-canada":                  {45.50, -73.57},
+	"quebec-canada":                    {46.81, -71.21},
+	"vancouver-canada":                 {49.28, -123.12},
+	"calgary-canada":                   {51.05, -114.07},
+	"edmonton-canada":                  {53.55, -113.49},
+	"ottawa-canada":                    {45.42, -75.70},
+	"winnipeg-canada":                  {49.90, -97.14},
+	"mexico_city-mexico":               {19.43, -99.13},
+	"monterrey-mexico":                 {25.69, -100.32},
+	"guadalajara-mexico":               {20.66, -103.35},
+	"playa_del_carmen-mexico":          {20.63, -87.08},
+	"havana-cuba":                      {23.11, -82.37},
+	"santo_domingo-dominican_republic": {18.49, -69.93},
+	"san_jose-costa_rica":              {9.93, -84.09},
+	"panama_city-panama":               {8.98, -79.52},
+	"bogota-colombia":                  {4.71, -74.07},
+	"medellin-colombia":                {6.24, -75.58},
+	"caracas-venezuela":                {10.48, -66.90},
+	"quito-ecuador":                    {-0.18, -78.47},
+	"lima-peru":                        {-12.05, -77.04},
+	"santiago-chile":                   {-33.45, -70.67},
+	"buenos_aires-argentina":           {-34.60, -58.38},
+	"san_isidro-argentina":             {-34.47, -58.53},
+	"la_plata-argentina":               {-34.92, -57.95},
+	"cordoba-argentina":                {-31.42, -64.18},
+	"rosario-argentina":                {-32.94, -60.64},
+	"montevideo-uruguay":               {-34.90, -56.16},
+	"asuncion-paraguay":                {-25.26, -57.58},
+	"sao_paulo-brazil":                 {-23.55, -46.63},
+	"rio_de_janeiro-brazil":            {-22.91, -43.17},
+	"belo_horizonte-brazil":            {-19.92, -43.94},
+	"porto_alegre-brazil":              {-30.03, -51.23},
+	"curitiba-brazil":                  {-25.43, -49.27},
+	"brasilia-brazil":                  {-15.79, -47.88},
+	"recife-brazil":                    {-8.05, -34.88},
+	"salvador-brazil":                  {-12.97, -38.50},
+	"florianopolis-brazil":             {-27.60, -48.55},
+
+	// United Kingdom and Ireland
+	"london-uk":      {51.51, -0.13},
+	"manchester-uk":  {53.48, -2.24},
+	"birmingham-uk":  {52.49, -1.89},
+	"glasgow-uk":     {55.86, -4.25},
+	"edinburgh-uk":   {55.95, -3.19},
+	"aberdeen-uk":    {57.15, -2.09},
+	"leeds-uk":       {53.80, -1.55},
+	"liverpool-uk":   {53.41, -2.98},
+	"newcastle-uk":   {54.98, -1.62},
+	"sheffield-uk":   {53.38, -1.47},
+	"nottingham-uk":  {52.95, -1.15},
+	"bristol-uk":     {51.45, -2.59},
+	"brighton-uk":    {50.82, -0.14},
+	"bournemouth-uk": {50.72, -1.88},
+	"cardiff-uk":     {51.48, -3.18},
+	"belfast-uk":     {54.60, -5.93},
+	"dublin-ireland": {53.35, -6.26},
+
+	// Western Europe
+	"paris-france":                  {48.86, 2.35},
+	"lyon-france":                   {45.76, 4.84},
+	"marseille-france":              {43.30, 5.37},
+	"nice-france":                   {43.70, 7.27},
+	"toulouse-france":               {43.60, 1.44},
+	"bordeaux-france":               {44.84, -0.58},
+	"nantes-france":                 {47.22, -1.55},
+	"lille-france":                  {50.63, 3.06},
+	"strasbourg-france":             {48.57, 7.75},
+	"rennes-france":                 {48.11, -1.68},
+	"montpellier-france":            {43.61, 3.88},
+	"grenoble-france":               {45.19, 5.72},
+	"clermont_ferrand-france":       {45.78, 3.08},
+	"pagney_derriere_barine-france": {48.69, 5.86},
+	"amsterdam-netherlands":         {52.37, 4.90},
+	"rotterdam-netherlands":         {51.92, 4.48},
+	"utrecht-netherlands":           {52.09, 5.12},
+	"landgraaf-netherlands":         {50.89, 6.02},
+	"brussels-belgium":              {50.85, 4.35},
+	"antwerp-belgium":               {51.22, 4.40},
+	"werchter-belgium":              {50.97, 4.70},
+	"luxembourg-luxembourg":         {49.61, 6.13},
+	"lausanne-switzerland":          {46.52, 6.63},
+	"zurich-switzerland":            {47.38, 8.54},
+	"geneva-switzerland":            {46.20, 6.14},
+	"basel-switzerland":             {47.56, 7.59},
+	"bern-switzerland":              {46.95, 7.45},
+	"frauenfeld-switzerland":        {47.56, 8.90},
+	"st_gallen-switzerland":         {47.42, 9.37},
+	"madrid-spain":                  {40.42, -3.70},
+	"barcelona-spain":               {41.39, 2.17},
+	"bilbao-spain":                  {43.26, -2.93},
+	"valencia-spain":                {39.47, -0.38},
+	"sevilla-spain":                 {37.39, -5.98},
+	"zaragoza-spain":                {41.65, -0.89},
+	"a_coruna-spain":                {43.36, -8.41},
+	"lisbon-portugal":               {38.72, -9.14},
+	"porto-portugal":                {41.15, -8.61},
+	"milan-italy":                   {45.46, 9.19},
+	"rome-italy":                    {41.90, 12.50},
+	"bologna-italy":                 {44.49, 11.34},
+	"turin-italy":                   {45.07, 7.69},
+	"florence-italy":                {43.77, 11.26},
+	"naples-italy":                  {40.85, 14.27},
+
+	// Central and Northern Europe
+	"berlin-germany":         {52.52, 13.40},
+	"hamburg-germany":        {53.55, 9.99},
+	"munich-germany":         {48.14, 11.58},
+	"frankfurt-germany":      {50.11, 8.68},
+	"cologne-germany":        {50.94, 6.96},
+	"dusseldorf-germany":     {51.23, 6.77},
+	"stuttgart-germany":      {48.78, 9.18},
+	"leipzig-germany":        {51.34, 12.37},
+	"dresden-germany":        {51.05, 13.74},
+	"hanover-germany":        {52.38, 9.73},
+	"nuremberg-germany":      {49.45, 11.08},
+	"mannheim-germany":       {49.49, 8.47},
+	"bremen-germany":         {53.08, 8.80},
+	"vienna-austria":         {48.21, 16.37},
+	"prague-czech_republic":  {50.08, 14.44},
+	"ostrava-czech_republic": {49.82, 18.26},
+	"bratislava-slovakia":    {48.15, 17.11},
+	"budapest-hungary":       {47.50, 19.04},
+	"warsaw-poland":          {52.23, 21.01},
+	"krakow-poland":          {50.06, 19.94},
+	"gdansk-poland":          {54.35, 18.65},
+	"lodz-poland":            {51.76, 19.46},
+	"copenhagen-denmark":     {55.68, 12.57},
+	"aarhus-denmark":         {56.16, 10.20},
+	"oslo-norway":            {59.91, 10.75},
+	"bergen-norway":          {60.39, 5.32},
+	"stockholm-sweden":       {59.33, 18.07},
+	"gothenburg-sweden":      {57.71, 11.97},
+	"helsinki-finland":       {60.17, 24.94},
+	"turku-finland":          {60.45, 22.27},
+	"tallinn-estonia":        {59.44, 24.75},
+	"riga-latvia":            {56.95, 24.11},
+	"vilnius-lithuania":      {54.69, 25.28},
+
+	// Eastern and Southern Europe, Middle East and Africa
+	"minsk-belarus":                  {53.90, 27.56},
+	"kiev-ukraine":                   {50.45, 30.52},
+	"moscow-russia":                  {55.76, 37.62},
+	"saint_petersburg-russia":        {59.93, 30.36},
+	"bucharest-romania":              {44.43, 26.10},
+	"sofia-bulgaria":                 {42.70, 23.32},
+	"belgrade-serbia":                {44.79, 20.45},
+	"zagreb-croatia":                 {45.81, 15.98},
+	"ljubljana-slovenia":             {46.06, 14.51},
+	"athens-greece":                  {37.98, 23.73},
+	"thessaloniki-greece":            {40.64, 22.94},
+	"istanbul-turkey":                {41.01, 28.98},
+	"tel_aviv-israel":                {32.09, 34.78},
+	"doha-qatar":                     {25.29, 51.53},
+	"dubai-united_arab_emirates":     {25.20, 55.27},
+	"abu_dhabi-united_arab_emirates": {24.45, 54.38},
+	"riyadh-saudi_arabia":            {24.71, 46.68},
+	"jeddah-saudi_arabia":            {21.49, 39.19},
+	"cairo-egypt":                    {30.04, 31.24},
+	"casablanca-morocco":             {33.57, -7.59},
+	"lagos-nigeria":                  {6.52, 3.38},
+	"nairobi-kenya":                  {-1.29, 36.82},
+	"johannesburg-south_africa":      {-26.20, 28.05},
+	"cape_town-south_africa":         {-33.92, 18.42},
+	"durban-south_africa":            {-29.86, 31.02},
+
+	// Asia
+	"tokyo-japan":              {35.68, 139.69},
+	"osaka-japan":              {34.69, 135.50},
+	"nagoya-japan":             {35.18, 136.91},
+	"saitama-japan":            {35.86, 139.65},
+	"yokohama-japan":           {35.44, 139.64},
+	"chiba-japan":              {35.61, 140.12},
+	"kobe-japan":               {34.69, 135.20},
+	"kyoto-japan":              {35.01, 135.77},
+	"hiroshima-japan":          {34.39, 132.46},
+	"fukuoka-japan":            {33.59, 130.40},
+	"sapporo-japan":            {43.06, 141.35},
+	"sendai-japan":             {38.27, 140.87},
+	"seoul-south_korea":        {37.57, 126.98},
+	"busan-south_korea":        {35.18, 129.08},
+	"beijing-china":            {39.90, 116.41},
+	"shanghai-china":           {31.23, 121.47},
+	"guangzhou-china":          {23.13, 113.26},
+	"shenzhen-china":           {22.54, 114.06},
+	"hong_kong-china":          {22.32, 114.17},
+	"taipei-taiwan":            {25.03, 121.57},
+	"manila-philippines":       {14.60, 120.98},
+	"bangkok-thailand":         {13.76, 100.50},
+	"kuala_lumpur-malaysia":    {3.14, 101.69},
+	"singapore-singapore":      {1.35, 103.82},
+	"jakarta-indonesia":        {-6.21, 106.85},
+	"yogyakarta-indonesia":     {-7.80, 110.36},
+	"bali-indonesia":           {-8.34, 115.09},
+	"mumbai-india":             {19.08, 72.88},
+	"new_delhi-india":          {28.61, 77.21},
+	"bangalore-india":          {12.97, 77.59},
+	"hyderabad-india":          {17.39, 78.49},
+	"chennai-india":            {13.08, 80.27},
+	"kolkata-india":            {22.57, 88.36},
+	"pune-india":               {18.52, 73.86},
+	"goa-india":                {15.30, 74.12},
+	"kathmandu-nepal":          {27.72, 85.32},
+	"colombo-sri_lanka":        {6.93, 79.86},
+	"dhaka-bangladesh":         {23.81, 90.41},
+	"ho_chi_minh_city-vietnam": {10.82, 106.63},
+	"hanoi-vietnam":            {21.03, 105.85},
+
+	// Oceania
+	"new_south_wales-australia": {-33.87, 151.21},
+	"victoria-australia":        {-37.81, 144.96},
+	"queensland-australia":      {-27.47, 153.03},
+	"west_melbourne-australia":  {-37.81, 144.94},
+	"sydney-australia":          {-33.87, 151.21},
+	"melbourne-australia":       {-37.81, 144.96},
+	"brisbane-australia":        {-27.47, 153.03},
+	"gold_coast-australia":      {-28.02, 153.40},
+	"perth-australia":           {-31.95, 115.86},
+	"adelaide-australia":        {-34.93, 138.60},
+	"canberra-australia":        {-35.28, 149.13},
+	"auckland-new_zealand":      {-36.85, 174.76},
+	"wellington-new_zealand":    {-41.29, 174.78},
+	"christchurch-new_zealand":  {-43.53, 172.64},
+	"dunedin-new_zealand":       {-45.87, 170.50},
+	"penrose-new_zealand":       {-36.91, 174.82},
+	"papeete-french_polynesia":  {-17.54, -149.57},
+	"noumea-new_caledonia":      {-22.28, 166.46},
+}
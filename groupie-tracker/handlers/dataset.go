@@ -0,0 +1,156 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// datasetTTL is how long a fetched dataset is reused before it is fetched again.
+const datasetTTL = 10 * time.Minute
+
+// datasetVersionLength is how many hex digits of the content checksum make
+// up a dataset version.
+const datasetVersionLength = 16
+
+/*
+Dataset is the whole catalogue: every artist and every relation, as fetched
+at FetchedAt. Pages that look across artists (insights, statistics) work on
+a Dataset instead of calling the API per artist.
+*/
+type Dataset struct {
+	Artists   []Artist
+	Relations map[int]Relation
+	FetchedAt time.Time
+
+	byID    map[int]int
+	version string
+	index   *concertIndex
+}
+
+// concertIndex holds the concerts of a dataset by date and by location. It
+// is built on first use and shared by copies of the Dataset.
+type concertIndex struct {
+	once       sync.Once
+	byDate     []ArtistConcert            // every concert, in date order
+	byLocation map[string][]ArtistConcert // in date order
+	locations  []string                   // location keys, sorted
+}
+
+// NewDataset indexes artists and relations. Artists are kept sorted by ID.
+func NewDataset(artists []Artist, relations []Relation, fetchedAt time.Time) *Dataset {
+	d := &Dataset{
+		Artists:   append([]Artist(nil), artists...),
+		Relations: make(map[int]Relation, len(relations)),
+		FetchedAt: fetchedAt,
+		byID:      make(map[int]int, len(artists)),
+		index:     &concertIndex{},
+	}
+	sort.Slice(d.Artists, func(i, j int) bool { return d.Artists[i].ID < d.Artists[j].ID })
+	for i, a := range d.Artists {
+		d.byID[a.ID] = i
+	}
+	for _, rel := range relations {
+		d.Relations[int(rel.ID)] = rel
+	}
+	if sum, err := datasetChecksum(d.Artists, datasetRelations(d)); err == nil {
+		d.version = sum[:datasetVersionLength]
+	}
+	return d
+}
+
+// Version identifies the content of the dataset: datasets with the same
+// artists and relations have the same version.
+func (d *Dataset) Version() string {
+	return d.version
+}
+
+/*
+Modified is when the content of d was stored, from the dataset store, so
+refetching the same content keeps the date. Content the store does not have
+is dated by its fetch time.
+*/
+func (d *Dataset) Modified() time.Time {
+	if s, err := datasetStore(); err == nil {
+		if t, ok := s.StoredAt(d.version); ok {
+			return t
+		}
+	}
+	return d.FetchedAt
+}
+
+// Artist returns the artist with the given ID.
+func (d *Dataset) Artist(id int) (Artist, bool) {
+	i, ok := d.byID[id]
+	if !ok {
+		return Artist{}, false
+	}
+	return d.Artists[i], true
+}
+
+// Concerts returns the concerts of an artist in date order.
+func (d *Dataset) Concerts(id int) []Concert {
+	return BuildConcerts(d.Relations[id])
+}
+
+// concertIndex returns the index of d, building it on the first call.
+func (d *Dataset) concertIndex() *concertIndex {
+	idx := d.index
+	idx.once.Do(func() {
+		idx.byDate = []ArtistConcert{}
+		idx.byLocation = map[string][]ArtistConcert{}
+		for _, a := range d.Artists {
+			for _, c := range d.Concerts(a.ID) {
+				idx.byDate = append(idx.byDate, ArtistConcert{ArtistID: a.ID, ArtistName: a.Name, Concert: c})
+			}
+		}
+		sortByDate(idx.byDate)
+		for _, c := range idx.byDate {
+			if _, ok := idx.byLocation[c.Concert.Location]; !ok {
+				idx.locations = append(idx.locations, c.Concert.Location)
+			}
+			idx.byLocation[c.Concert.Location] = append(idx.byLocation[c.Concert.Location], c)
+		}
+		sort.Strings(idx.locations)
+	})
+	return idx
+}
+
+// AllConcerts returns the concerts of every artist in date order. The
+// slice is shared and must not be modified.
+func (d *Dataset) AllConcerts() []ArtistConcert {
+	return d.concertIndex().byDate
+}
+
+/*
+ConcertsBetween returns the concerts from the day of from to the day of to,
+both included, in date order. A zero bound leaves that side open. The slice
+is shared and must not be modified.
+*/
+func (d *Dataset) ConcertsBetween(from, to time.Time) []ArtistConcert {
+	list := d.concertIndex().byDate
+	start, end := 0, len(list)
+	if !from.IsZero() {
+		day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		start = sort.Search(len(list), func(i int) bool { return !list[i].Concert.Date.Before(day) })
+	}
+	if !to.IsZero() {
+		next := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, time.UTC)
+		end = sort.Search(len(list), func(i int) bool { return !list[i].Concert.Date.Before(next) })
+	}
+	if start > end {
+		return nil
+	}
+	return list[start:end]
+}
+
+// ConcertsAt returns the concerts at a raw location key in date order. The
+// slice is shared and must not be modified.
+func (d *Dataset) ConcertsAt(location string) []ArtistConcert {
+	return d.concertIndex().byLocation[location]
+}
+
+// Locations lists every location key with at least one concert, sorted.
+func (d *Dataset) Locations() []string {
+	return d.concertIndex().locations
+}
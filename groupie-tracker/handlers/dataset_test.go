@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestDataset builds a small catalogue shared by the insight tests.
+func newTestDataset() *Dataset {
+	artists := []Artist{
+		{ID: 2, Name: "Pink Floyd", Members: []string{"Roger Waters", "David Gilmour"}, CreationDate: 1965, FirstAlbum: "05-08-1967"},
+		{ID: 1, Name: "Queen", Members: []string{"Freddie Mercury", "Brian May", "Roger Taylor"}, CreationDate: 1970, FirstAlbum: "14-12-1973"},
+		{ID: 3, Name: "Scorpions", Members: []string{"Klaus Meine"}, CreationDate: 1965, FirstAlbum: "01-01-1972"},
+	}
+	relations := []Relation{
+		{ID: 1, Locations: map[string][]string{"london-uk": {"20-11-2019"}, "osaka-japan": {"28-01-2020"}}},
+		{ID: 2, Locations: map[string][]string{"london-uk": {"22-11-2019"}, "paris-france": {"01-01-2020"}}},
+		{ID: 3, Locations: map[string][]string{"london-uk": {"20-11-2018"}, "osaka-japan": {"28-01-2020"}}},
+	}
+	return NewDataset(artists, relations, time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestNewDataset(t *testing.T) {
+	d := newTestDataset()
+
+	if d.Artists[0].ID != 1 || d.Artists[2].ID != 3 {
+		t.Errorf("artists are not sorted by ID: %+v", d.Artists)
+	}
+	if a, ok := d.Artist(2); !ok || a.Name != "Pink Floyd" {
+		t.Errorf("Artist(2) = %+v, %v; want Pink Floyd", a, ok)
+	}
+	if _, ok := d.Artist(42); ok {
+		t.Error("Artist(42) found an artist that does not exist")
+	}
+	if got := len(d.Concerts(1)); got != 2 {
+		t.Errorf("Concerts(1) returned %d concerts, want 2", got)
+	}
+}
+
+func TestFetchDataset(t *testing.T) {
+	// Create a mock server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artists":
+			w.Write([]byte(`[{"id":1,"name":"Queen"}]`))
+		case "/relation":
+			w.Write([]byte(`{"index":[{"id":1,"datesLocations":{"london-uk":["20-11-2019"]}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d, err := fetchDataset(HTTPProvider{BaseURL: server.URL + "/"})
+	if err != nil {
+		t.Fatalf("fetchDataset() returned an error: %v", err)
+	}
+	if len(d.Artists) != 1 || len(d.Relations) != 1 {
+		t.Errorf("fetchDataset() got %d artists and %d relations, want 1 and 1", len(d.Artists), len(d.Relations))
+	}
+
+	if _, err := fetchDataset(HTTPProvider{BaseURL: server.URL + "/missing/"}); err == nil {
+		t.Error("fetchDataset() expected an error when the API is missing")
+	}
+}
+
+// useTestDataset makes loadDataset return the test catalogue for the
+// duration of the test.
+func useTestDataset(t *testing.T) *Dataset {
+	t.Helper()
+	d := newTestDataset()
+	d.FetchedAt = time.Now()
+
+	datasetCache.Lock()
+	previous := datasetCache.data
+	datasetCache.data = d
+	datasetCache.Unlock()
+
+	t.Cleanup(func() {
+		datasetCache.Lock()
+		datasetCache.data = previous
+		datasetCache.Unlock()
+	})
+	return d
+}
+
+func TestDatasetConcertIndex(t *testing.T) {
+	d := newTestDataset()
+
+	london := d.ConcertsAt("london-uk")
+	if len(london) != 3 || london[0].ArtistName != "Scorpions" || london[2].ArtistName != "Pink Floyd" {
+		t.Errorf("ConcertsAt(london-uk) = %+v, want Scorpions, Queen, Pink Floyd", london)
+	}
+	if got := d.ConcertsAt("nowhere-xx"); len(got) != 0 {
+		t.Errorf("ConcertsAt(nowhere-xx) = %+v, want none", got)
+	}
+
+	day := time.Date(2020, 1, 1, 15, 0, 0, 0, time.UTC)
+	if on := d.ConcertsBetween(day, day); len(on) != 1 || on[0].Concert.Location != "paris-france" {
+		t.Errorf("ConcertsBetween(2020-01-01, 2020-01-01) = %+v, want Paris", on)
+	}
+	all := d.AllConcerts()
+	if from := d.ConcertsBetween(day, time.Time{}); len(from) == 0 || len(from) >= len(all) || from[0].Concert.Date.Before(day.Truncate(24*time.Hour)) {
+		t.Errorf("ConcertsBetween(2020-01-01, open) = %+v", from)
+	}
+	if got := d.ConcertsBetween(time.Time{}, time.Time{}); len(got) != len(all) {
+		t.Errorf("ConcertsBetween(open, open) returned %d concerts, want %d", len(got), len(all))
+	}
+	if got := d.ConcertsBetween(day.AddDate(0, 0, 1), day); len(got) != 0 {
+		t.Errorf("ConcertsBetween() with from after to = %+v, want none", got)
+	}
+	if locations := d.Locations(); len(locations) == 0 || locations[0] > locations[len(locations)-1] {
+		t.Errorf("Locations() = %v, want sorted keys", locations)
+	}
+}
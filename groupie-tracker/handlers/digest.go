@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Digest frequencies a user can choose.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// digestPeriod is how often a digest of the given frequency is sent.
+func digestPeriod(frequency string) time.Duration {
+	if frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// digestHorizon is how far ahead a digest lists upcoming concerts.
+func digestHorizon(frequency string) time.Duration {
+	if frequency == DigestWeekly {
+		return 30 * 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// Digest is one user's summary of the concerts of the artists they follow.
+type Digest struct {
+	Username  string
+	Frequency string
+	Since     time.Time
+	Until     time.Time
+	Upcoming  []ArtistConcert // concerts within the horizon, soonest first
+	Announced []ArtistConcert // dates added upstream since the last digest
+	SiteURL   string
+}
+
+// Empty reports whether the digest has nothing worth sending.
+func (d Digest) Empty() bool {
+	return len(d.Upcoming) == 0 && len(d.Announced) == 0
+}
+
+/*
+BuildDigest collects, for the followed artist IDs, the concerts coming up
+within the frequency's horizon and the concerts announced in the change log
+since the last digest. Announced dates already in the past are left out.
+*/
+func BuildDigest(frequency string, followed []int, d *Dataset, changes []ChangeSet, since, now time.Time) Digest {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.Add(digestHorizon(frequency))
+	follows := map[int]bool{}
+	for _, id := range followed {
+		follows[id] = true
+	}
+
+	digest := Digest{Frequency: frequency, Since: since, Until: now}
+	for _, a := range d.Artists {
+		if !follows[a.ID] {
+			continue
+		}
+		for _, c := range d.Concerts(a.ID) {
+			if !c.Date.Before(today) && c.Date.Before(horizon) {
+				digest.Upcoming = append(digest.Upcoming, ArtistConcert{ArtistID: a.ID, ArtistName: a.Name, Concert: c})
+			}
+		}
+	}
+	for _, set := range changes {
+		if !set.At.After(since) || set.At.After(now) {
+			continue
+		}
+		for _, ac := range set.ConcertsAdded {
+			if follows[ac.ArtistID] && !ac.Concert.Date.Before(today) {
+				digest.Announced = append(digest.Announced, ac)
+			}
+		}
+	}
+	sortByDate(digest.Upcoming)
+	sortByDate(digest.Announced)
+	return digest
+}
+
+func sortByDate(list []ArtistConcert) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].Concert.Date.Equal(list[j].Concert.Date) {
+			return list[i].Concert.Date.Before(list[j].Concert.Date)
+		}
+		return list[i].ArtistName < list[j].ArtistName
+	})
+}
+
+// digestTemplate is the email template; tests point it at the repo copy.
+var digestTemplate = "template/digest_email.txt"
+
+// renderDigest fills the email template with the digest.
+func renderDigest(d Digest) (Message, error) {
+	temp, err := template.ParseFiles(digestTemplate)
+	if err != nil {
+		return Message{}, err
+	}
+	var body bytes.Buffer
+	if err := temp.Execute(&body, d); err != nil {
+		return Message{}, err
+	}
+	subject := fmt.Sprintf("Your %s concert digest: %d upcoming, %d newly announced", d.Frequency, len(d.Upcoming), len(d.Announced))
+	return Message{Subject: subject, Body: body.String()}, nil
+}
+
+// digestSiteURL is the site root used for links in emails.
+func digestSiteURL() string {
+	if u := os.Getenv("GROUPIE_SITE_URL"); u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// digestDue reports whether a user's next digest should go out at now.
+func digestDue(u User, now time.Time) bool {
+	if u.Email == "" || (u.Digest != DigestDaily && u.Digest != DigestWeekly) {
+		return false
+	}
+	return u.LastDigest.IsZero() || !now.Before(u.LastDigest.Add(digestPeriod(u.Digest)))
+}
+
+/*
+SendDigests sends a digest to every user whose daily or weekly digest is
+due, skipping digests with nothing in them, and records when each user was
+last sent one. It returns how many digests were sent; delivery failures
+are logged and retried on the next run.
+*/
+func SendDigests(n Notifier, now time.Time) (int, error) {
+	usersMu.Lock()
+	users, err := readUsersLocked()
+	usersMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var due []User
+	for _, u := range users {
+		if digestDue(u, now) {
+			due = append(due, u)
+		}
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Username < due[j].Username })
+
+	data, err := loadDataset()
+	if err != nil {
+		return 0, err
+	}
+	changes, err := readChangeLog()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, u := range due {
+		since := u.LastDigest
+		if since.IsZero() {
+			since = now.Add(-digestPeriod(u.Digest))
+		}
+		followed, err := readFavorites(userOwner(u.Username))
+		if err != nil {
+			return sent, err
+		}
+
+		digest := BuildDigest(u.Digest, followed, data, changes, since, now)
+		if !digest.Empty() {
+			digest.Username = u.Username
+			digest.SiteURL = digestSiteURL()
+			msg, err := renderDigest(digest)
+			if err != nil {
+				return sent, err
+			}
+			msg.To = u.Email
+			if err := n.Notify(msg); err != nil {
+				log.Printf("Error sending the digest to %s: %v", u.Username, err)
+				continue
+			}
+			sent++
+		}
+
+		err = updateUser(u.Username, func(stored *User) { stored.LastDigest = now })
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+/*
+StartDigests checks for due digests every interval in the background
+until the returned stop function is called.
+*/
+func StartDigests(n Notifier, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if _, err := SendDigests(n, now); err != nil {
+					log.Printf("Error sending digests: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
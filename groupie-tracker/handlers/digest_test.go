@@ -0,0 +1,167 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingNotifier keeps the messages it is asked to send.
+type recordingNotifier struct {
+	messages []Message
+}
+
+func (n *recordingNotifier) Notify(msg Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func useDigestTemplate(t *testing.T) {
+	t.Helper()
+	previous := digestTemplate
+	digestTemplate = "../template/digest_email.txt"
+	t.Cleanup(func() { digestTemplate = previous })
+}
+
+func TestBuildDigest(t *testing.T) {
+	d := newTestDataset()
+	now := time.Date(2019, 11, 15, 8, 0, 0, 0, time.UTC)
+	changes := []ChangeSet{
+		{At: now.Add(-48 * time.Hour), ConcertsAdded: []ArtistConcert{{ArtistID: 1, ArtistName: "Queen", Concert: Concert{Location: "old-news", Date: now.AddDate(0, 1, 0)}}}},
+		{At: now.Add(-time.Hour), ConcertsAdded: []ArtistConcert{
+			{ArtistID: 1, ArtistName: "Queen", Concert: Concert{Location: "osaka-japan", Date: time.Date(2020, 1, 28, 0, 0, 0, 0, time.UTC)}},
+			{ArtistID: 2, ArtistName: "Pink Floyd", Concert: Concert{Location: "paris-france", Date: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			{ArtistID: 1, ArtistName: "Queen", Concert: Concert{Location: "already-played", Date: now.AddDate(0, 0, -3)}},
+		}},
+	}
+
+	tests := []struct {
+		name          string
+		frequency     string
+		followed      []int
+		wantUpcoming  []string
+		wantAnnounced []string
+	}{
+		{"daily for Queen", DigestDaily, []int{1}, []string{"london-uk"}, []string{"osaka-japan"}},
+		{"weekly for Queen and Pink Floyd", DigestWeekly, []int{1, 2}, []string{"london-uk", "london-uk"}, []string{"paris-france", "osaka-japan"}},
+		{"nothing followed", DigestWeekly, nil, nil, nil},
+		{"Scorpions only played in the past", DigestWeekly, []int{3}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildDigest(tt.frequency, tt.followed, d, changes, now.Add(-24*time.Hour), now)
+			if locs := digestLocations(got.Upcoming); strings.Join(locs, ",") != strings.Join(tt.wantUpcoming, ",") {
+				t.Errorf("Upcoming = %v, want %v", locs, tt.wantUpcoming)
+			}
+			if locs := digestLocations(got.Announced); strings.Join(locs, ",") != strings.Join(tt.wantAnnounced, ",") {
+				t.Errorf("Announced = %v, want %v", locs, tt.wantAnnounced)
+			}
+			if got.Empty() != (len(tt.wantUpcoming)+len(tt.wantAnnounced) == 0) {
+				t.Errorf("Empty() = %v", got.Empty())
+			}
+		})
+	}
+}
+
+func digestLocations(list []ArtistConcert) []string {
+	var locs []string
+	for _, ac := range list {
+		locs = append(locs, ac.Concert.Location)
+	}
+	return locs
+}
+
+func TestDigestDue(t *testing.T) {
+	now := time.Date(2020, 1, 8, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"never sent", User{Email: "a@example.com", Digest: DigestDaily}, true},
+		{"daily, sent yesterday", User{Email: "a@example.com", Digest: DigestDaily, LastDigest: now.Add(-24 * time.Hour)}, true},
+		{"daily, sent this morning", User{Email: "a@example.com", Digest: DigestDaily, LastDigest: now.Add(-2 * time.Hour)}, false},
+		{"weekly, sent three days ago", User{Email: "a@example.com", Digest: DigestWeekly, LastDigest: now.AddDate(0, 0, -3)}, false},
+		{"weekly, sent a week ago", User{Email: "a@example.com", Digest: DigestWeekly, LastDigest: now.AddDate(0, 0, -7)}, true},
+		{"no email", User{Digest: DigestDaily}, false},
+		{"digest off", User{Email: "a@example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := digestDue(tt.user, now); got != tt.want {
+				t.Errorf("digestDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	useAccountTest(t)
+	useDigestTemplate(t)
+	server := newFakeSMTP(t)
+
+	b := newTestBrowser(t)
+	b.do("GET", "/register", nil)
+	b.do("POST", "/register", url.Values{"username": {"brian"}, "password": {"red-special"}, "confirm": {"red-special"}})
+	b.do("POST", "/favorites/1", url.Values{})
+	if w := b.do("POST", "/account/digest", url.Values{"email": {"brian@example.com"}, "digest": {"daily"}}); w.Code != 303 {
+		t.Fatalf("POST /account/digest = %d", w.Code)
+	}
+	createUser("nodigest", "password1")
+
+	now := time.Date(2019, 11, 15, 8, 0, 0, 0, time.UTC)
+	n := SMTPNotifier{Addr: server.addr(), From: "digest@example.com"}
+	sent, err := SendDigests(n, now)
+	if err != nil || sent != 1 {
+		t.Fatalf("SendDigests() = %d, %v; want 1 digest", sent, err)
+	}
+	got := server.received()
+	if len(got) != 1 || got[0].To[0] != "brian@example.com" {
+		t.Fatalf("server received %+v", got)
+	}
+	for _, want := range []string{"Subject: Your daily concert digest: 1 upcoming, 0 newly announced", "Hi brian,", "Queen: London, UK on 20 Nov 2019", "/artist/1#concerts", "/account"} {
+		if !strings.Contains(got[0].Data, want) {
+			t.Errorf("digest is missing %q:\n%s", want, got[0].Data)
+		}
+	}
+
+	// The next digest is only due a day later.
+	if sent, _ := SendDigests(n, now.Add(time.Hour)); sent != 0 {
+		t.Errorf("SendDigests() an hour later sent %d digests, want 0", sent)
+	}
+	rec := &recordingNotifier{}
+	if sent, _ := SendDigests(rec, now.Add(24*time.Hour)); sent != 1 || len(rec.messages) != 1 {
+		t.Errorf("SendDigests() a day later sent %d digests, want 1", sent)
+	}
+}
+
+func TestDigestSettings(t *testing.T) {
+	useAccountTest(t)
+	b := newTestBrowser(t)
+	b.do("GET", "/register", nil)
+	b.do("POST", "/register", url.Values{"username": {"roger"}, "password": {"radio-ga-ga"}, "confirm": {"radio-ga-ga"}})
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		wantStatus int
+		wantDigest string
+	}{
+		{"weekly", url.Values{"email": {"roger@example.com"}, "digest": {"weekly"}}, 303, DigestWeekly},
+		{"unknown frequency", url.Values{"email": {"roger@example.com"}, "digest": {"hourly"}}, 400, DigestWeekly},
+		{"invalid email", url.Values{"email": {"not an email"}, "digest": {"daily"}}, 400, DigestWeekly},
+		{"digest without email", url.Values{"digest": {"daily"}}, 400, DigestWeekly},
+		{"turned off", url.Values{"email": {"roger@example.com"}, "digest": {""}}, 303, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := b.do("POST", "/account/digest?format=json", tt.form); w.Code != tt.wantStatus {
+				t.Errorf("POST /account/digest = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got, _ := b.account(); got.Digest != tt.wantDigest {
+				t.Errorf("Digest = %q, want %q", got.Digest, tt.wantDigest)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server-Sent Event types sent on /events.
+const (
+	StreamArtistUpdated = "artist-updated"
+	StreamConcertAdded  = "concert-added"
+	StreamRefreshFailed = "refresh-failed"
+)
+
+const (
+	// maxEventHistory is how many past events are kept for Last-Event-ID.
+	maxEventHistory = 500
+	// eventClientBuffer is how many events may wait for a slow client
+	// before it is disconnected; it catches up when it reconnects.
+	eventClientBuffer = 64
+	// maxEventClients limits the number of open streams.
+	maxEventClients = 1000
+	// eventRetry tells browsers how long to wait before reconnecting.
+	eventRetry = 5 * time.Second
+)
+
+// heartbeatInterval is how often an idle stream gets a comment line so
+// proxies keep it open. Tests shorten it.
+var heartbeatInterval = 15 * time.Second
+
+// Event is one message on the /events stream.
+type Event struct {
+	ID   int64
+	Type string
+	Data interface{}
+}
+
+// ArtistUpdate is the data of an artist-updated event.
+type ArtistUpdate struct {
+	Artist ArtistRef `json:"artist"`
+	// Change is "added", "removed" or "members".
+	Change  string   `json:"change"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+type eventClient struct {
+	ch    chan Event
+	types map[string]bool // nil for every type
+}
+
+func (c *eventClient) wants(e Event) bool {
+	return c.types == nil || c.types[e.Type]
+}
+
+/*
+EventBroker fans events out to the connected /events clients and keeps
+the most recent ones so reconnecting clients can resume.
+*/
+type EventBroker struct {
+	mu      sync.Mutex
+	nextID  int64
+	history []Event
+	clients map[*eventClient]bool
+}
+
+// NewEventBroker returns a broker without clients or history.
+func NewEventBroker() *EventBroker {
+	return &EventBroker{nextID: 1, clients: map[*eventClient]bool{}}
+}
+
+// events is the broker behind /events.
+var events = NewEventBroker()
+
+/*
+Publish sends an event to every client. A client whose buffer is full is
+disconnected instead of holding up the others.
+*/
+func (b *EventBroker) Publish(typ string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := Event{ID: b.nextID, Type: typ, Data: data}
+	b.nextID++
+	b.history = append(b.history, e)
+	if len(b.history) > maxEventHistory {
+		b.history = b.history[len(b.history)-maxEventHistory:]
+	}
+	for c := range b.clients {
+		if !c.wants(e) {
+			continue
+		}
+		select {
+		case c.ch <- e:
+		default:
+			delete(b.clients, c)
+			close(c.ch)
+		}
+	}
+	return e
+}
+
+/*
+subscribe registers a client and returns the missed events after lastID.
+A lastID the broker does not know (older than its history, or from before
+a restart) replays the whole history. ok is false when there are too many
+clients.
+*/
+func (b *EventBroker) subscribe(lastID int64, types map[string]bool) (c *eventClient, missed []Event, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.clients) >= maxEventClients {
+		return nil, nil, false
+	}
+	c = &eventClient{ch: make(chan Event, eventClientBuffer), types: types}
+	b.clients[c] = true
+
+	if lastID > 0 {
+		known := lastID < b.nextID && (len(b.history) == 0 || lastID >= b.history[0].ID-1)
+		for _, e := range b.history {
+			if (!known || e.ID > lastID) && c.wants(e) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	return c, missed, true
+}
+
+func (b *EventBroker) unsubscribe(c *eventClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.clients[c] {
+		delete(b.clients, c)
+		close(c.ch)
+	}
+}
+
+// publishChanges turns a change set into artist-updated and concert-added events.
+func publishChanges(changes ChangeSet) {
+	for _, a := range changes.ArtistsAdded {
+		events.Publish(StreamArtistUpdated, ArtistUpdate{Artist: a, Change: "added"})
+	}
+	for _, a := range changes.ArtistsRemoved {
+		events.Publish(StreamArtistUpdated, ArtistUpdate{Artist: a, Change: "removed"})
+	}
+	for _, m := range changes.MembersChanged {
+		events.Publish(StreamArtistUpdated, ArtistUpdate{Artist: m.Artist, Change: "members", Added: m.Added, Removed: m.Removed})
+	}
+	for _, c := range changes.ConcertsAdded {
+		events.Publish(StreamConcertAdded, c)
+	}
+}
+
+// writeEvent writes e in the text/event-stream format.
+func writeEvent(w http.ResponseWriter, e Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+/*
+EventsHandler streams dataset updates as Server-Sent Events: artist-updated,
+concert-added and refresh-failed. ?types=a,b limits the stream to some event
+types. Clients reconnecting with a Last-Event-ID header (or ?lastEventId)
+first receive the events they missed. A comment line is sent as a heartbeat
+while the stream is idle.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		renderError(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	var types map[string]bool
+	if raw := r.URL.Query().Get("types"); raw != "" {
+		types = map[string]bool{}
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if t != StreamArtistUpdated && t != StreamConcertAdded && t != StreamRefreshFailed {
+				renderError(w, r, http.StatusBadRequest, "Unknown event type: expected "+StreamArtistUpdated+", "+StreamConcertAdded+" or "+StreamRefreshFailed)
+				return
+			}
+			types[t] = true
+		}
+	}
+	lastRaw := r.Header.Get("Last-Event-ID")
+	if lastRaw == "" {
+		lastRaw = r.URL.Query().Get("lastEventId")
+	}
+	lastID, _ := strconv.ParseInt(lastRaw, 10, 64)
+
+	client, missed, ok := events.subscribe(lastID, types)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(eventRetry.Seconds())))
+		renderError(w, r, http.StatusServiceUnavailable, "Too many open event streams")
+		return
+	}
+	defer events.unsubscribe(client)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	for _, e := range missed {
+		if writeEvent(w, e) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, open := <-client.ch:
+			if !open {
+				// too slow: the browser reconnects with Last-Event-ID
+				return
+			}
+			if writeEvent(w, e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useEventBroker gives the test its own event broker.
+func useEventBroker(t *testing.T) *EventBroker {
+	t.Helper()
+	previous := events
+	events = NewEventBroker()
+	t.Cleanup(func() { events = previous })
+	return events
+}
+
+func eventIDs(list []Event) string {
+	var ids []int64
+	for _, e := range list {
+		ids = append(ids, e.ID)
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestEventBrokerReplay(t *testing.T) {
+	b := useEventBroker(t)
+	b.Publish(StreamArtistUpdated, nil)
+	b.Publish(StreamConcertAdded, nil)
+	b.Publish(StreamRefreshFailed, nil)
+
+	tests := []struct {
+		name   string
+		lastID int64
+		types  map[string]bool
+		want   string
+	}{
+		{"new client", 0, nil, "[]"},
+		{"resume", 1, nil, "[2 3]"},
+		{"up to date", 3, nil, "[]"},
+		{"unknown id", 42, nil, "[1 2 3]"},
+		{"filtered", 1, map[string]bool{StreamRefreshFailed: true}, "[3]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, missed, ok := b.subscribe(tt.lastID, tt.types)
+			if !ok {
+				t.Fatal("subscribe() refused the client")
+			}
+			defer b.unsubscribe(c)
+			if got := eventIDs(missed); got != tt.want {
+				t.Errorf("expected missed events %s; got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEventBrokerHistoryLimit(t *testing.T) {
+	b := useEventBroker(t)
+	for i := 0; i < maxEventHistory+10; i++ {
+		b.Publish(StreamConcertAdded, i)
+	}
+	if len(b.history) != maxEventHistory || b.history[0].ID != 11 {
+		t.Fatalf("expected the last %d events to be kept; got %d from %d", maxEventHistory, len(b.history), b.history[0].ID)
+	}
+
+	// A client that fell behind the history gets everything that is left.
+	c, missed, _ := b.subscribe(5, nil)
+	defer b.unsubscribe(c)
+	if len(missed) != maxEventHistory {
+		t.Errorf("expected %d missed events; got %d", maxEventHistory, len(missed))
+	}
+}
+
+func TestEventBrokerDropsSlowClients(t *testing.T) {
+	b := useEventBroker(t)
+	slow, _, _ := b.subscribe(0, nil)
+	filtered, _, _ := b.subscribe(0, map[string]bool{StreamRefreshFailed: true})
+	defer b.unsubscribe(filtered)
+
+	for i := 0; i <= eventClientBuffer; i++ {
+		b.Publish(StreamConcertAdded, i)
+	}
+	received := 0
+	for range slow.ch {
+		received++
+	}
+	if received != eventClientBuffer {
+		t.Errorf("expected %d buffered events before the client was dropped; got %d", eventClientBuffer, received)
+	}
+	if len(b.clients) != 1 || !b.clients[filtered] {
+		t.Errorf("expected only the filtered client to stay connected; got %d clients", len(b.clients))
+	}
+	b.unsubscribe(slow) // already gone: must not panic
+}
+
+// readEvent reads lines up to the end of the next message on the stream.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	b := useEventBroker(t)
+	previous := heartbeatInterval
+	heartbeatInterval = 20 * time.Millisecond
+	t.Cleanup(func() { heartbeatInterval = previous })
+
+	b.Publish(StreamArtistUpdated, ArtistUpdate{Artist: ArtistRef{ID: 1, Name: "Queen"}, Change: "members", Added: []string{"Adam Lambert"}})
+	b.Publish(StreamRefreshFailed, RefreshError{At: time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC), Error: "timeout"})
+
+	server := httptest.NewServer(Routes())
+	defer server.Close()
+	req, _ := http.NewRequest("GET", server.URL+"/events?types=artist-updated,concert-added", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream; got %d %s", resp.StatusCode, ct)
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	if got := readEvent(t, stream); got != "retry: 5000" {
+		t.Errorf("expected the retry delay first; got %q", got)
+	}
+	if got := readEvent(t, stream); got != ": heartbeat" {
+		t.Errorf("expected a heartbeat on an idle stream; got %q", got)
+	}
+
+	b.Publish(StreamRefreshFailed, RefreshError{Error: "filtered out"})
+	b.Publish(StreamConcertAdded, ArtistConcert{ArtistID: 1, ArtistName: "Queen", Concert: Concert{City: "Paris"}})
+	got := readEvent(t, stream)
+	for got == ": heartbeat" {
+		got = readEvent(t, stream)
+	}
+	if !strings.HasPrefix(got, "id: 4\nevent: concert-added\ndata: {\"artistId\":1,\"artistName\":\"Queen\"") {
+		t.Errorf("unexpected event %q", got)
+	}
+
+	// Reconnecting after event 1 replays the matching events since then.
+	req.Header.Set("Last-Event-ID", "1")
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp2.Body.Close()
+	stream = bufio.NewReader(resp2.Body)
+	readEvent(t, stream) // retry
+	if got := readEvent(t, stream); !strings.HasPrefix(got, "id: 4\n") {
+		t.Errorf("expected event 4 to be replayed; got %q", got)
+	}
+}
+
+func TestEventsHandlerUnknownType(t *testing.T) {
+	w := httptest.NewRecorder()
+	Routes().ServeHTTP(w, httptest.NewRequest("GET", "/events?types=artist-deleted", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d; got %d", http.StatusBadRequest, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "artist-deleted") {
+		t.Errorf("expected the error not to echo the type; got %q", w.Body.String())
+	}
+}
+
+func TestRefreshPublishesEvents(t *testing.T) {
+	b := useEventBroker(t)
+	api := useFakeAPI(t)
+	if _, _, err := RefreshDataset(); err != nil {
+		t.Fatalf("RefreshDataset() returned an error: %v", err)
+	}
+	if len(b.history) != 0 {
+		t.Errorf("expected no events without changes; got %+v", b.history)
+	}
+
+	api.set(`{"london-uk":["20-11-2019"],"paris-france":["01-01-2020"]}`, false)
+	RefreshDataset()
+	api.set("", true)
+	RefreshDataset()
+
+	if len(b.history) != 2 {
+		t.Fatalf("expected 2 events; got %+v", b.history)
+	}
+	if e := b.history[0]; e.Type != StreamConcertAdded || e.Data.(ArtistConcert).Concert.Location != "paris-france" {
+		t.Errorf("expected the Paris concert to be announced; got %+v", e)
+	}
+	if e := b.history[1]; e.Type != StreamRefreshFailed || e.Data.(RefreshError).Error == "" {
+		t.Errorf("expected a refresh-failed event; got %+v", e)
+	}
+}
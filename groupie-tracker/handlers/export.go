@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportArtist is one artist row of an export.
+type ExportArtist struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Members      []string `json:"members"`
+	CreationDate int      `json:"creationDate"`
+	FirstAlbum   string   `json:"firstAlbum"`
+	ConcertCount int      `json:"concertCount"`
+	Countries    []string `json:"countries"`
+}
+
+// ExportConcert is one concert row of an export.
+type ExportConcert struct {
+	ArtistID   int    `json:"artistId"`
+	ArtistName string `json:"artistName"`
+	Location   string `json:"location"`
+	City       string `json:"city"`
+	Country    string `json:"country"`
+	Date       string `json:"date"`
+}
+
+var (
+	exportArtistHeader  = []string{"id", "name", "members", "member_count", "creation_date", "first_album", "concert_count", "countries"}
+	exportConcertHeader = []string{"artist_id", "artist_name", "location", "city", "country", "date"}
+)
+
+// rowWriter writes export rows in one output format.
+type rowWriter interface {
+	artist(ExportArtist) error
+	concert(ExportConcert) error
+	flush() error
+}
+
+/*
+ExportHandler streams the artists matching the listing filters as CSV or
+newline-delimited JSON. Query parameters:
+
+	format  csv (default) or ndjson
+	rows    artist (default) for one row per artist, concert for one per concert
+
+plus every ArtistFilter parameter. Rows are flushed to the client as they
+are written instead of being buffered.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := ParseArtistFilter(q)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	rows := q.Get("rows")
+	if rows == "" {
+		rows = "artist"
+	}
+	if format != "csv" && format != "ndjson" {
+		renderError(w, r, http.StatusBadRequest, "format must be csv or ndjson")
+		return
+	}
+	if rows != "artist" && rows != "concert" {
+		renderError(w, r, http.StatusBadRequest, "rows must be artist or concert")
+		return
+	}
+
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	artists := filter.Apply(data)
+
+	filename := fmt.Sprintf("%ss-%s.%s", rows, time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	fw := &flushWriter{w: w}
+	fw.f, _ = w.(http.Flusher)
+
+	var out rowWriter
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		out = newCSVRows(fw, rows)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		out = &ndjsonRows{enc: json.NewEncoder(fw), fw: fw}
+	}
+
+	if err := writeExport(out, data, artists, rows); err != nil {
+		log.Printf("Error streaming export: %v", err)
+	}
+}
+
+// writeExport writes every artist, or every concert of every artist, to out.
+func writeExport(out rowWriter, d *Dataset, artists []Artist, rows string) error {
+	for _, a := range artists {
+		concerts := d.Concerts(a.ID)
+		if rows == "concert" {
+			for _, c := range concerts {
+				err := out.concert(ExportConcert{
+					ArtistID:   a.ID,
+					ArtistName: a.Name,
+					Location:   c.Location,
+					City:       c.City,
+					Country:    c.Country,
+					Date:       c.Date.Format("2006-01-02"),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			err := out.artist(ExportArtist{
+				ID:           a.ID,
+				Name:         a.Name,
+				Members:      a.Members,
+				CreationDate: a.CreationDate,
+				FirstAlbum:   a.FirstAlbum,
+				ConcertCount: len(concerts),
+				Countries:    concertCountries(concerts),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+	}
+	return out.flush()
+}
+
+// concertCountries lists the countries of the concerts in first-visit order.
+func concertCountries(concerts []Concert) []string {
+	countries := []string{}
+	seen := map[string]bool{}
+	for _, c := range concerts {
+		if !seen[c.Country] {
+			seen[c.Country] = true
+			countries = append(countries, c.Country)
+		}
+	}
+	return countries
+}
+
+// flushWriter pushes each flushed chunk to the client when it can.
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	return fw.w.Write(p)
+}
+
+func (fw *flushWriter) Flush() {
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+}
+
+type csvRows struct {
+	w  *csv.Writer
+	fw *flushWriter
+}
+
+func newCSVRows(fw *flushWriter, rows string) *csvRows {
+	c := &csvRows{w: csv.NewWriter(fw), fw: fw}
+	if rows == "concert" {
+		c.w.Write(exportConcertHeader)
+	} else {
+		c.w.Write(exportArtistHeader)
+	}
+	return c
+}
+
+func (c *csvRows) artist(a ExportArtist) error {
+	return c.w.Write([]string{
+		strconv.Itoa(a.ID),
+		a.Name,
+		strings.Join(a.Members, "; "),
+		strconv.Itoa(len(a.Members)),
+		strconv.Itoa(a.CreationDate),
+		a.FirstAlbum,
+		strconv.Itoa(a.ConcertCount),
+		strings.Join(a.Countries, "; "),
+	})
+}
+
+func (c *csvRows) concert(e ExportConcert) error {
+	return c.w.Write([]string{strconv.Itoa(e.ArtistID), e.ArtistName, e.Location, e.City, e.Country, e.Date})
+}
+
+func (c *csvRows) flush() error {
+	c.w.Flush()
+	c.fw.Flush()
+	return c.w.Error()
+}
+
+type ndjsonRows struct {
+	enc *json.Encoder
+	fw  *flushWriter
+}
+
+func (n *ndjsonRows) artist(a ExportArtist) error   { return n.enc.Encode(a) }
+func (n *ndjsonRows) concert(e ExportConcert) error { return n.enc.Encode(e) }
+
+func (n *ndjsonRows) flush() error {
+	n.fw.Flush()
+	return nil
+}
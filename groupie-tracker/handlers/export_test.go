@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportHandler(t *testing.T) {
+	useTestDataset(t)
+
+	tests := []struct {
+		name            string
+		url             string
+		wantContentType string
+		wantFilename    string
+		wantRows        int
+	}{
+		{"CSV artists", "/artists/export", "text/csv; charset=utf-8", "artists-", 3},
+		{"CSV concerts filtered", "/artists/export?rows=concert&country=Japan", "text/csv; charset=utf-8", "concerts-", 4},
+		{"NDJSON artists filtered", "/artists/export?format=ndjson&q=queen", "application/x-ndjson", ".ndjson", 1},
+		{"NDJSON concerts", "/artists/export?format=ndjson&rows=concert", "application/x-ndjson", "concerts-", 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ExportHandler(w, httptest.NewRequest("GET", tt.url, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d; got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("expected Content-Type %q; got %q", tt.wantContentType, got)
+			}
+			if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment; filename=") || !strings.Contains(got, tt.wantFilename) {
+				t.Errorf("unexpected Content-Disposition %q", got)
+			}
+			if !w.Flushed {
+				t.Error("expected the export to be flushed while streaming")
+			}
+
+			var rows int
+			if strings.HasPrefix(tt.wantContentType, "text/csv") {
+				records, err := csv.NewReader(w.Body).ReadAll()
+				if err != nil {
+					t.Fatalf("invalid CSV: %v", err)
+				}
+				rows = len(records) - 1 // header
+			} else {
+				dec := json.NewDecoder(w.Body)
+				for dec.More() {
+					var v map[string]interface{}
+					if err := dec.Decode(&v); err != nil {
+						t.Fatalf("invalid NDJSON: %v", err)
+					}
+					rows++
+				}
+			}
+			if rows != tt.wantRows {
+				t.Errorf("expected %d rows; got %d", tt.wantRows, rows)
+			}
+		})
+	}
+}
+
+func TestExportHandlerCSVArtistRow(t *testing.T) {
+	useTestDataset(t)
+
+	w := httptest.NewRecorder()
+	ExportHandler(w, httptest.NewRequest("GET", "/artists/export?q=queen", nil))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expected a header and one row, got %v (%v)", records, err)
+	}
+	want := []string{"1", "Queen", "Freddie Mercury; Brian May; Roger Taylor", "3", "1970", "14-12-1973", "2", "UK; Japan"}
+	for i := range want {
+		if records[1][i] != want[i] {
+			t.Errorf("column %s = %q, want %q", records[0][i], records[1][i], want[i])
+		}
+	}
+}
+
+func TestExportHandlerBadRequest(t *testing.T) {
+	useTestDataset(t)
+
+	for _, url := range []string{"/artists/export?format=xml", "/artists/export?rows=member", "/artists/export?created_from=x"} {
+		w := httptest.NewRecorder()
+		ExportHandler(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d; got %d", url, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var favoritesMu sync.Mutex
+
+func favoritesPath() string {
+	return filepath.Join(dataDir, "favorites.json")
+}
+
+// readAllFavorites returns the favorites file: artist IDs keyed by owner.
+func readAllFavorites() (map[string][]int, error) {
+	all := map[string][]int{}
+	err := readJSONFile(favoritesPath(), &all)
+	if os.IsNotExist(err) {
+		return map[string][]int{}, nil
+	}
+	return all, err
+}
+
+// readFavorites returns the artist IDs starred by owner, in ID order.
+func readFavorites(owner string) ([]int, error) {
+	if owner == "" {
+		return nil, nil
+	}
+	favoritesMu.Lock()
+	defer favoritesMu.Unlock()
+	all, err := readAllFavorites()
+	if err != nil {
+		return nil, err
+	}
+	return all[owner], nil
+}
+
+/*
+setFavorite stars (on) or unstars an artist for owner and returns the
+owner's updated favorites. Owners without favorites are dropped from the file.
+*/
+func setFavorite(owner string, artistID int, on bool) ([]int, error) {
+	favoritesMu.Lock()
+	defer favoritesMu.Unlock()
+
+	all, err := readAllFavorites()
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, id := range all[owner] {
+		if id != artistID {
+			ids = append(ids, id)
+		}
+	}
+	if on {
+		ids = append(ids, artistID)
+		sort.Ints(ids)
+	}
+	if len(ids) == 0 {
+		delete(all, owner)
+	} else {
+		all[owner] = ids
+	}
+	return ids, writeJSONFile(favoritesPath(), all)
+}
+
+// favoriteSet returns the favorites of owner as a set for templates.
+func favoriteSet(owner string) map[int]bool {
+	ids, _ := readFavorites(owner)
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// favoritesOwner identifies whose favorites a request reads and changes:
+// the signed-in account, or else the anonymous session.
+func favoritesOwner(r *http.Request) string {
+	if user := currentUser(r); user != "" {
+		return userOwner(user)
+	}
+	return sessionID(r)
+}
+
+// mergeFavorites moves the favorites of owner from into owner to.
+func mergeFavorites(from, to string) error {
+	favoritesMu.Lock()
+	defer favoritesMu.Unlock()
+
+	all, err := readAllFavorites()
+	if err != nil {
+		return err
+	}
+	if len(all[from]) == 0 {
+		return nil
+	}
+	seen := map[int]bool{}
+	var ids []int
+	for _, id := range append(all[to], all[from]...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	all[to] = ids
+	delete(all, from)
+	return writeJSONFile(favoritesPath(), all)
+}
+
+// safeReturn returns the local path a form asked to go back to, or fallback.
+func safeReturn(r *http.Request, fallback string) string {
+	return localPath(r.PostFormValue("return"), fallback)
+}
+
+/*
+localPath returns p if it is a path on this site, or fallback, so forms
+cannot be used to redirect visitors elsewhere. Browsers drop tabs and
+newlines from URLs and read backslashes as slashes, so "/\t/host" or "/\host"
+would leave the site: paths with control characters or backslashes are
+refused, as are those that parse with a scheme or a host.
+*/
+func localPath(p, fallback string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.ContainsRune(p, '\\') {
+		return fallback
+	}
+	if strings.IndexFunc(p, unicode.IsControl) >= 0 {
+		return fallback
+	}
+	u, err := url.Parse(p)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return fallback
+	}
+	return p
+}
+
+// FavoritesPage is the data passed to the favorites template.
+type FavoritesPage struct {
+	Artists []Artist
+	CSRF    string
+	Return  string
+}
+
+/*
+FavoritesHandler shows the "My artists" page: the artists starred by the
+signed-in user or in the visitor's anonymous session. With ?format=json or
+a JSON Accept header the artists are returned as JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func FavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	id := ensureSession(w, r)
+
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	ids, err := readFavorites(favoritesOwner(r))
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error reading favorites")
+		return
+	}
+	if pageNotModified(w, r, datasetValidator(data), "favorites") {
+		return
+	}
+	artists := []Artist{}
+	for _, artistID := range ids {
+		if a, ok := data.Artist(artistID); ok {
+			artists = append(artists, a)
+		}
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, artists)
+		return
+	}
+
+	temp, err := parseTemplate("template/favorites.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+	err = temp.Execute(w, FavoritesPage{Artists: artists, CSRF: csrfToken(id), Return: r.URL.RequestURI()})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
+
+/*
+AddFavoriteHandler stars the artist in the path for the visitor's session.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AddFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	changeFavorite(w, r, true)
+}
+
+/*
+RemoveFavoriteHandler unstars the artist in the path. It answers both
+DELETE /favorites/{id} and POST /favorites/{id}/delete, since HTML forms
+cannot send DELETE.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func RemoveFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	changeFavorite(w, r, false)
+}
+
+/*
+changeFavorite checks the session and its CSRF token, updates the favorites
+and then redirects forms back to the page they came from; API clients get
+the updated list of favorite IDs as JSON.
+*/
+func changeFavorite(w http.ResponseWriter, r *http.Request, on bool) {
+	owner := favoritesOwner(r)
+	if owner == "" || !checkCSRF(r, sessionID(r)) {
+		renderError(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+		return
+	}
+
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	artistID := PathInt(r, "id")
+	if _, ok := data.Artist(artistID); !ok {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that artist")
+		return
+	}
+
+	ids, err := setFavorite(owner, artistID, on)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error saving favorites")
+		return
+	}
+
+	if wantsJSON(r) || r.Header.Get(csrfHeader) != "" {
+		if ids == nil {
+			ids = []int{}
+		}
+		writeJSON(w, http.StatusOK, map[string][]int{"favorites": ids})
+		return
+	}
+	http.Redirect(w, r, safeReturn(r, "/favorites"), http.StatusSeeOther)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFavorites(t *testing.T) {
+	useTestDataset(t)
+	useTempDataDir(t)
+	useTestSessionKey(t)
+	handler := Routes()
+
+	// Visiting a page starts the anonymous session.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/favorites?format=json", nil))
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("GET /favorites = %d with cookies %v", w.Code, cookies)
+	}
+	session := cookies[0]
+	id, _ := verifySigned(session.Value)
+	token := csrfToken(id)
+
+	send := func(method, path, csrf string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.AddCookie(session)
+		if csrf != "" {
+			r.Header.Set(csrfHeader, csrf)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		csrf       string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{"add", "POST", "/favorites/3", token, http.StatusOK, []int{3}},
+		{"add another", "POST", "/favorites/1", token, http.StatusOK, []int{1, 3}},
+		{"add twice", "POST", "/favorites/1", token, http.StatusOK, []int{1, 3}},
+		{"missing token", "POST", "/favorites/2", "", http.StatusForbidden, nil},
+		{"wrong token", "POST", "/favorites/2", csrfToken("other"), http.StatusForbidden, nil},
+		{"unknown artist", "POST", "/favorites/99", token, http.StatusNotFound, nil},
+		{"remove", "DELETE", "/favorites/3", token, http.StatusOK, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.method, tt.path, tt.csrf)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantIDs == nil {
+				return
+			}
+			var got struct{ Favorites []int }
+			json.NewDecoder(w.Body).Decode(&got)
+			if fmt.Sprint(got.Favorites) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("favorites = %v, want %v", got.Favorites, tt.wantIDs)
+			}
+		})
+	}
+
+	w = send("GET", "/favorites?format=json", "")
+	var artists []Artist
+	json.NewDecoder(w.Body).Decode(&artists)
+	if len(artists) != 1 || artists[0].Name != "Queen" {
+		t.Errorf("GET /favorites = %+v, want Queen", artists)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/artists?q=queen#top", "/artists?q=queen#top"},
+		{"/", "/"},
+		{"", "/fallback"},
+		{"artists", "/fallback"},
+		{"//example.com", "/fallback"},
+		{"///example.com", "/fallback"},
+		{"/\\example.com", "/fallback"},
+		{"/\t/example.com", "/fallback"},
+		{"/\n/example.com", "/fallback"},
+		{"javascript:alert(1)", "/fallback"},
+		{"https://example.com/", "/fallback"},
+		{"/%0a/example.com", "/%0a/example.com"},
+	}
+	for _, tt := range tests {
+		if got := localPath(tt.path, "/fallback"); got != tt.want {
+			t.Errorf("localPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFavoriteFormRedirects(t *testing.T) {
+	useTestDataset(t)
+	useTempDataDir(t)
+	useTestSessionKey(t)
+
+	tests := []struct {
+		name         string
+		returnTo     string
+		wantLocation string
+	}{
+		{"local page", "/artists?q=queen", "/artists?q=queen"},
+		{"no return", "", "/favorites"},
+		{"other site", "https://example.com/", "/favorites"},
+		{"protocol relative", "//example.com/", "/favorites"},
+		{"backslash", "/\\example.com/", "/favorites"},
+		{"tab", "/\t/example.com/", "/favorites"},
+		{"newline", "/\n/example.com/", "/favorites"},
+		{"carriage return", "/\r\n/example.com/", "/favorites"},
+		{"null byte", "/\x00/example.com/", "/favorites"},
+		{"delete", "/\x7f/example.com/", "/favorites"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{csrfField: {csrfToken("session1")}, "return": {tt.returnTo}}
+			r := httptest.NewRequest("POST", "/favorites/2/delete", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(&http.Cookie{Name: sessionCookie, Value: signValue("session1")})
+			w := httptest.NewRecorder()
+			Routes().ServeHTTP(w, r)
+
+			if w.Code != http.StatusSeeOther || w.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("got %d to %q, want 303 to %q", w.Code, w.Header().Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+}
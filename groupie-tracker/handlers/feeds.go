@@ -0,0 +1,326 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFeedItems caps how many upcoming concerts a feed lists.
+const maxFeedItems = 200
+
+// feedTagAuthority is the authority part of the tag: URIs used as stable
+// feed and item IDs (RFC 4151).
+const feedTagAuthority = "groupie-tracker,2024"
+
+// FeedItem is one upcoming concert in a feed.
+type FeedItem struct {
+	ArtistID   int
+	ArtistName string
+	Concert    Concert
+}
+
+// GUID identifies the concert independently of when the feed was built,
+// so feed readers recognise the same show across refreshes.
+func (it FeedItem) GUID() string {
+	return fmt.Sprintf("tag:%s:concert/%d/%s/%s", feedTagAuthority, it.ArtistID,
+		url.PathEscape(it.Concert.Location), it.Concert.Date.Format("2006-01-02"))
+}
+
+// Title is the item headline, e.g. "Queen in London, UK on 20 Nov 2019".
+func (it FeedItem) Title() string {
+	return fmt.Sprintf("%s in %s, %s on %s", it.ArtistName, it.Concert.City, it.Concert.Country, it.Concert.DateString())
+}
+
+// Summary is the item body. It gives the concert date, which is not the
+// item's publication date.
+func (it FeedItem) Summary() string {
+	return fmt.Sprintf("%s plays %s, %s on %s.", it.ArtistName, it.Concert.City, it.Concert.Country,
+		it.Concert.Date.Format("Monday 2 January 2006"))
+}
+
+/*
+feedHistory remembers, for the store in dataDir, when each item GUID first
+appeared in a stored version of the dataset. Versions are scanned once,
+oldest first, as they are added to the store.
+*/
+var feedHistory struct {
+	sync.Mutex
+	path      string
+	scanned   int                  // stored versions scanned so far
+	versions  map[string]bool      // Dataset.Version() of the scanned versions
+	firstSeen map[string]time.Time // FeedItem.GUID() -> StoredAt
+}
+
+// scanFeedHistoryLocked scans the versions added to the store since the
+// last call, unless d is a version that was scanned already.
+func scanFeedHistoryLocked(d *Dataset) {
+	s, err := datasetStore()
+	if err != nil {
+		return
+	}
+	if feedHistory.path != s.path {
+		feedHistory.path, feedHistory.scanned = s.path, 0
+		feedHistory.versions = map[string]bool{}
+		feedHistory.firstSeen = map[string]time.Time{}
+	}
+	if feedHistory.versions[d.Version()] {
+		return
+	}
+	versions := s.Versions()
+	for _, v := range versions[feedHistory.scanned:] {
+		stored, err := s.Version(v.Version)
+		if err != nil {
+			log.Printf("Error reading version %d of the dataset: %v", v.Version, err)
+			return
+		}
+		feedHistory.versions[stored.Version()] = true
+		for _, c := range stored.AllConcerts() {
+			guid := FeedItem(c).GUID()
+			if _, ok := feedHistory.firstSeen[guid]; !ok {
+				feedHistory.firstSeen[guid] = v.StoredAt
+			}
+		}
+		feedHistory.scanned++
+	}
+}
+
+/*
+feedDates returns the date of the feed, d.Modified, and the date of each
+item, when its GUID first appeared in a stored version. An item keeps its
+date across refreshes, so readers only see it as new once.
+*/
+func feedDates(d *Dataset, items []FeedItem) (time.Time, []time.Time) {
+	updated := d.Modified()
+	feedHistory.Lock()
+	defer feedHistory.Unlock()
+	scanFeedHistoryLocked(d)
+
+	dates := make([]time.Time, len(items))
+	for i, it := range items {
+		dates[i] = updated
+		if seen, ok := feedHistory.firstSeen[it.GUID()]; ok && seen.Before(updated) {
+			dates[i] = seen
+		}
+	}
+	return updated, dates
+}
+
+/*
+upcomingConcerts lists the concerts on or after the day of now, soonest
+first, optionally limited to one artist (by ID or case-insensitive name)
+and one country. An empty filter value disables that filter.
+*/
+func upcomingConcerts(d *Dataset, now time.Time, artist, country string) []FeedItem {
+	artistID, _ := strconv.Atoi(artist)
+
+	items := []FeedItem{}
+	for _, c := range d.ConcertsBetween(now, time.Time{}) {
+		if artist != "" && c.ArtistID != artistID && !strings.EqualFold(c.ArtistName, artist) {
+			continue
+		}
+		if country != "" && !strings.EqualFold(c.Concert.Country, country) {
+			continue
+		}
+		items = append(items, FeedItem(c))
+		if len(items) == maxFeedItems {
+			break
+		}
+	}
+	return items
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// siteURL is the absolute root of the site as seen by the client.
+func siteURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedRequest reads the feed filters and collects the matching concerts.
+// It reports false when a response has been sent already: an error, or a
+// 304 for the feed reader's current copy of the media ("rss" or "atom").
+func feedRequest(w http.ResponseWriter, r *http.Request, media string) (*Dataset, []FeedItem, bool) {
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return nil, nil, false
+	}
+	now := time.Now()
+	if feedNotModified(w, r, data, media, now) {
+		return nil, nil, false
+	}
+	q := r.URL.Query()
+	return data, upcomingConcerts(data, now, strings.TrimSpace(q.Get("artist")), strings.TrimSpace(q.Get("country"))), true
+}
+
+func feedTitle(r *http.Request) string {
+	title := "Groupie Tracker - Upcoming concerts"
+	q := r.URL.Query()
+	if a := strings.TrimSpace(q.Get("artist")); a != "" {
+		title += " - artist " + a
+	}
+	if c := strings.TrimSpace(q.Get("country")); c != "" {
+		title += " - " + c
+	}
+	return title
+}
+
+/*
+RSSHandler serves the upcoming concerts as an RSS 2.0 feed at
+/feeds/upcoming.rss, optionally scoped with ?artist= and ?country=.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func RSSHandler(w http.ResponseWriter, r *http.Request) {
+	data, items, ok := feedRequest(w, r, "rss")
+	if !ok {
+		return
+	}
+
+	site := siteURL(r)
+	updated, published := feedDates(data, items)
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         feedTitle(r),
+			Link:          site + "/artists",
+			Description:   "Upcoming concerts of the artists on Groupie Tracker",
+			LastBuildDate: updated.UTC().Format(time.RFC1123Z),
+			Self:          atomLink{Href: site + r.URL.RequestURI(), Rel: "self", Type: "application/rss+xml"},
+			Items:         []rssItem{},
+		},
+	}
+	for i, it := range items {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       it.Title(),
+			Link:        fmt.Sprintf("%s/artist/%d#concerts", site, it.ArtistID),
+			Description: it.Summary(),
+			GUID:        rssGUID{IsPermaLink: false, Value: it.GUID()},
+			PubDate:     published[i].UTC().Format(time.RFC1123Z),
+			Category:    it.Concert.Country,
+		})
+	}
+
+	writeXML(w, "application/rss+xml; charset=utf-8", feed)
+}
+
+/*
+AtomHandler serves the upcoming concerts as an Atom feed at
+/feeds/upcoming.atom, optionally scoped with ?artist= and ?country=.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func AtomHandler(w http.ResponseWriter, r *http.Request) {
+	data, items, ok := feedRequest(w, r, "atom")
+	if !ok {
+		return
+	}
+
+	site := siteURL(r)
+	updated, published := feedDates(data, items)
+	feed := atomFeed{
+		ID:      fmt.Sprintf("tag:%s:feeds/upcoming?%s", feedTagAuthority, r.URL.Query().Encode()),
+		Title:   feedTitle(r),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: site + r.URL.RequestURI(), Rel: "self", Type: "application/atom+xml"},
+			{Href: site + "/artists", Rel: "alternate", Type: "text/html"},
+		},
+		Author:  atomAuthor{Name: "Groupie Tracker"},
+		Entries: []atomEntry{},
+	}
+	for i, it := range items {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      it.GUID(),
+			Title:   it.Title(),
+			Updated: published[i].UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: fmt.Sprintf("%s/artist/%d#concerts", site, it.ArtistID), Rel: "alternate"},
+			Summary: it.Summary(),
+		})
+	}
+
+	writeXML(w, "application/atom+xml; charset=utf-8", feed)
+}
+
+// writeXML writes v as an XML document with the given content type.
+func writeXML(w http.ResponseWriter, contentType string, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Error writing feed: %v", err)
+	}
+}
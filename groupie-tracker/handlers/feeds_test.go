@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFeedDataset serves a catalogue with concerts in the far future.
+func useFeedDataset(t *testing.T) *Dataset {
+	useTempDataDir(t)
+	d := useTestDataset(t)
+	d.Relations[1] = Relation{ID: 1, Locations: map[string][]string{
+		"london-uk":   {"20-11-2019", "01-06-2099"},
+		"osaka-japan": {"01-07-2099"},
+	}}
+	d.Relations[2] = Relation{ID: 2, Locations: map[string][]string{"paris-france": {"15-06-2099"}}}
+	return d
+}
+
+func TestUpcomingConcerts(t *testing.T) {
+	d := newTestDataset()
+	now := time.Date(2019, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		artist  string
+		country string
+		want    int
+	}{
+		{"Everything", "", "", 3},
+		{"Artist by ID", "1", "", 1},
+		{"Artist by name", "scorpions", "", 1},
+		{"Country", "", "Japan", 2},
+		{"Unknown artist", "Nobody", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := upcomingConcerts(d, now, tt.artist, tt.country)
+			if len(got) != tt.want {
+				t.Fatalf("upcomingConcerts() returned %d items, want %d", len(got), tt.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Concert.Date.Before(got[i-1].Concert.Date) {
+					t.Errorf("items are not sorted by date: %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestFeedItemGUIDIsStable(t *testing.T) {
+	d := newTestDataset()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	first := upcomingConcerts(d, now, "", "")
+	d.FetchedAt = d.FetchedAt.Add(24 * time.Hour)
+	second := upcomingConcerts(d, now, "", "")
+
+	for i := range first {
+		if first[i].GUID() != second[i].GUID() {
+			t.Errorf("GUID changed between refreshes: %q != %q", first[i].GUID(), second[i].GUID())
+		}
+	}
+	if first[0].GUID() != "tag:groupie-tracker,2024:concert/1/london-uk/2019-11-20" {
+		t.Errorf("unexpected GUID %q", first[0].GUID())
+	}
+}
+
+func TestRSSHandler(t *testing.T) {
+	d := useFeedDataset(t)
+
+	w := httptest.NewRecorder()
+	RSSHandler(w, httptest.NewRequest("GET", "http://example.com/feeds/upcoming.rss?country=uk", nil))
+
+	if got := w.Header().Get("Content-Type"); got != "application/rss+xml; charset=utf-8" {
+		t.Errorf("expected RSS content type; got %q", got)
+	}
+	var feed rssFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid RSS: %v", err)
+	}
+	if len(feed.Channel.Items) != 1 {
+		t.Fatalf("expected 1 item; got %d", len(feed.Channel.Items))
+	}
+	item := feed.Channel.Items[0]
+	if item.GUID.Value != "tag:groupie-tracker,2024:concert/1/london-uk/2099-06-01" || item.GUID.IsPermaLink {
+		t.Errorf("unexpected guid %+v", item.GUID)
+	}
+	if item.Link != "http://example.com/artist/1#concerts" {
+		t.Errorf("unexpected link %q", item.Link)
+	}
+	if want := d.FetchedAt.UTC().Format(time.RFC1123Z); item.PubDate != want || feed.Channel.LastBuildDate != want {
+		t.Errorf("expected items of unstored data to be dated when it was fetched, %s; got %s", want, item.PubDate)
+	}
+	if item.Description != "Queen plays London, UK on Monday 1 June 2099." {
+		t.Errorf("expected the concert date in the description; got %q", item.Description)
+	}
+	if !strings.Contains(w.Body.String(), `isPermaLink="false"`) {
+		t.Error("expected guids to be marked as not permalinks")
+	}
+}
+
+func TestAtomHandler(t *testing.T) {
+	d := useFeedDataset(t)
+
+	w := httptest.NewRecorder()
+	AtomHandler(w, httptest.NewRequest("GET", "http://example.com/feeds/upcoming.atom?artist=1", nil))
+
+	if got := w.Header().Get("Content-Type"); got != "application/atom+xml; charset=utf-8" {
+		t.Errorf("expected Atom content type; got %q", got)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid Atom: %v", err)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("expected 2 entries; got %d", len(feed.Entries))
+	}
+	if feed.Entries[0].Title != "Queen in London, UK on 01 Jun 2099" || feed.Entries[1].ID != "tag:groupie-tracker,2024:concert/1/osaka-japan/2099-07-01" {
+		t.Errorf("unexpected entries: %+v", feed.Entries)
+	}
+	for _, e := range feed.Entries {
+		if want := d.FetchedAt.UTC().Format(time.RFC3339); e.Updated != want || feed.Updated != want {
+			t.Errorf("expected entries of unstored data to be updated when it was fetched, %s; got %s", want, e.Updated)
+		}
+		if !strings.Contains(e.Summary, " 2099.") {
+			t.Errorf("expected the concert date in the summary; got %q", e.Summary)
+		}
+	}
+	if !strings.Contains(w.Body.String(), `xmlns="http://www.w3.org/2005/Atom"`) {
+		t.Error("expected the Atom namespace")
+	}
+}
+
+func TestFeedItemDatesAreStable(t *testing.T) {
+	useTempDataDir(t)
+	s, err := datasetStore()
+	if err != nil {
+		t.Fatalf("datasetStore() returned an error: %v", err)
+	}
+
+	first := newTestDataset()
+	first.Relations[1] = Relation{ID: 1, Locations: map[string][]string{"london-uk": {"01-06-2099"}}}
+	firstAt := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	s.Put(NewDataset(first.Artists, datasetRelations(first), firstAt))
+
+	// The next refresh changes one artist's members and announces a concert.
+	artists := append([]Artist(nil), first.Artists...)
+	artists[1].Members = []string{"Someone New"}
+	relations := datasetRelations(first)
+	relations[0] = Relation{ID: 1, Locations: map[string][]string{"london-uk": {"01-06-2099"}, "osaka-japan": {"01-07-2099"}}}
+	secondAt := firstAt.Add(24 * time.Hour)
+	s.Put(NewDataset(artists, relations, secondAt))
+
+	previous := currentDataset()
+	setDataset(NewDataset(artists, relations, time.Now()))
+	t.Cleanup(func() { setDataset(previous) })
+
+	w := httptest.NewRecorder()
+	RSSHandler(w, httptest.NewRequest("GET", "http://example.com/feeds/upcoming.rss?artist=1", nil))
+	var rss rssFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &rss); err != nil {
+		t.Fatalf("invalid RSS: %v", err)
+	}
+	if got, want := rss.Channel.LastBuildDate, secondAt.Format(time.RFC1123Z); got != want {
+		t.Errorf("lastBuildDate = %s, want the version time %s", got, want)
+	}
+	if len(rss.Channel.Items) != 2 {
+		t.Fatalf("expected 2 items; got %d", len(rss.Channel.Items))
+	}
+	if got, want := rss.Channel.Items[0].PubDate, firstAt.Format(time.RFC1123Z); got != want {
+		t.Errorf("unchanged London item pubDate = %s, want %s from the first refresh", got, want)
+	}
+	if got, want := rss.Channel.Items[1].PubDate, secondAt.Format(time.RFC1123Z); got != want {
+		t.Errorf("new Osaka item pubDate = %s, want %s", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	AtomHandler(w, httptest.NewRequest("GET", "http://example.com/feeds/upcoming.atom?artist=1", nil))
+	var atom atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &atom); err != nil {
+		t.Fatalf("invalid Atom: %v", err)
+	}
+	if len(atom.Entries) != 2 || atom.Entries[0].Updated != firstAt.Format(time.RFC3339) ||
+		atom.Entries[1].Updated != secondAt.Format(time.RFC3339) || atom.Updated != secondAt.Format(time.RFC3339) {
+		t.Errorf("unexpected Atom dates: feed %s, entries %+v", atom.Updated, atom.Entries)
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortKeys are the values accepted by the sort parameter; a leading "-"
+// reverses the order.
+var sortKeys = map[string]bool{
+	"id": true, "name": true, "created": true, "album": true, "members": true, "concerts": true,
+}
+
+/*
+ArtistFilter narrows and orders an artists listing. It is read from the
+query string so the same parameters work for the HTML listing and the exports:
+
+	q            name or member contains (case-insensitive)
+	created_from creation year at least
+	created_to   creation year at most
+	album_from   first album year at least
+	album_to     first album year at most
+	members_min  at least this many members
+	members_max  at most this many members
+	country      played at least one concert in this country
+	sort         id, name, created, album, members or concerts, "-" to reverse
+
+Zero values disable a filter.
+*/
+type ArtistFilter struct {
+	Query       string `json:"q,omitempty"`
+	CreatedFrom int    `json:"createdFrom,omitempty"`
+	CreatedTo   int    `json:"createdTo,omitempty"`
+	AlbumFrom   int    `json:"albumFrom,omitempty"`
+	AlbumTo     int    `json:"albumTo,omitempty"`
+	MembersMin  int    `json:"membersMin,omitempty"`
+	MembersMax  int    `json:"membersMax,omitempty"`
+	Country     string `json:"country,omitempty"`
+	Sort        string `json:"sort,omitempty"`
+}
+
+// ParseArtistFilter reads an ArtistFilter from query parameters.
+func ParseArtistFilter(q url.Values) (ArtistFilter, error) {
+	f := ArtistFilter{
+		Query:   strings.TrimSpace(q.Get("q")),
+		Country: strings.TrimSpace(q.Get("country")),
+		Sort:    strings.TrimSpace(q.Get("sort")),
+	}
+
+	numbers := []struct {
+		name string
+		dst  *int
+	}{
+		{"created_from", &f.CreatedFrom},
+		{"created_to", &f.CreatedTo},
+		{"album_from", &f.AlbumFrom},
+		{"album_to", &f.AlbumTo},
+		{"members_min", &f.MembersMin},
+		{"members_max", &f.MembersMax},
+	}
+	for _, n := range numbers {
+		raw := strings.TrimSpace(q.Get(n.name))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return f, fmt.Errorf("invalid %s: expected a non-negative whole number", n.name)
+		}
+		*n.dst = v
+	}
+
+	if f.Sort != "" && !sortKeys[strings.TrimPrefix(f.Sort, "-")] {
+		return f, errors.New("invalid sort: expected id, name, created, album, members or concerts, optionally prefixed with \"-\"")
+	}
+	return f, nil
+}
+
+// Values encodes the filter back into query parameters.
+func (f ArtistFilter) Values() url.Values {
+	v := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	setInt := func(name string, value int) {
+		if value != 0 {
+			v.Set(name, strconv.Itoa(value))
+		}
+	}
+	set("q", f.Query)
+	setInt("created_from", f.CreatedFrom)
+	setInt("created_to", f.CreatedTo)
+	setInt("album_from", f.AlbumFrom)
+	setInt("album_to", f.AlbumTo)
+	setInt("members_min", f.MembersMin)
+	setInt("members_max", f.MembersMax)
+	set("country", f.Country)
+	set("sort", f.Sort)
+	return v
+}
+
+// Apply returns the artists of the dataset that match the filter, sorted.
+func (f ArtistFilter) Apply(d *Dataset) []Artist {
+	artists := []Artist{}
+	for _, a := range d.Artists {
+		if f.matches(d, a) {
+			artists = append(artists, a)
+		}
+	}
+	f.sort(d, artists)
+	return artists
+}
+
+func (f ArtistFilter) matches(d *Dataset, a Artist) bool {
+	if f.Query != "" && !artistContains(a, f.Query) {
+		return false
+	}
+	if f.CreatedFrom != 0 && a.CreationDate < f.CreatedFrom {
+		return false
+	}
+	if f.CreatedTo != 0 && a.CreationDate > f.CreatedTo {
+		return false
+	}
+	if f.AlbumFrom != 0 || f.AlbumTo != 0 {
+		year := firstAlbumYear(a)
+		if f.AlbumFrom != 0 && year < f.AlbumFrom {
+			return false
+		}
+		if f.AlbumTo != 0 && year > f.AlbumTo {
+			return false
+		}
+	}
+	if f.MembersMin != 0 && len(a.Members) < f.MembersMin {
+		return false
+	}
+	if f.MembersMax != 0 && len(a.Members) > f.MembersMax {
+		return false
+	}
+	if f.Country != "" {
+		found := false
+		for _, c := range d.Concerts(a.ID) {
+			if strings.EqualFold(c.Country, f.Country) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (f ArtistFilter) sort(d *Dataset, artists []Artist) {
+	key := strings.TrimPrefix(f.Sort, "-")
+	desc := strings.HasPrefix(f.Sort, "-")
+
+	var less func(a, b Artist) bool
+	switch key {
+	case "name":
+		less = func(a, b Artist) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "created":
+		less = func(a, b Artist) bool { return a.CreationDate < b.CreationDate }
+	case "album":
+		less = func(a, b Artist) bool { return firstAlbumYear(a) < firstAlbumYear(b) }
+	case "members":
+		less = func(a, b Artist) bool { return len(a.Members) < len(b.Members) }
+	case "concerts":
+		counts := map[int]int{}
+		for _, a := range artists {
+			counts[a.ID] = len(d.Concerts(a.ID))
+		}
+		less = func(a, b Artist) bool { return counts[a.ID] < counts[b.ID] }
+	default:
+		less = func(a, b Artist) bool { return a.ID < b.ID }
+	}
+
+	sort.SliceStable(artists, func(i, j int) bool {
+		if desc {
+			return less(artists[j], artists[i])
+		}
+		return less(artists[i], artists[j])
+	})
+}
+
+func artistContains(a Artist, query string) bool {
+	query = strings.ToLower(query)
+	if strings.Contains(strings.ToLower(a.Name), query) {
+		return true
+	}
+	for _, m := range a.Members {
+		if strings.Contains(strings.ToLower(m), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstAlbumYear returns the year of the first album, or 0 if unknown.
+func firstAlbumYear(a Artist) int {
+	date, err := ParseConcertDate(a.FirstAlbum)
+	if err != nil {
+		return 0
+	}
+	return date.Year()
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseArtistFilter(t *testing.T) {
+	q, _ := url.ParseQuery("q=queen&created_from=1960&members_max=4&country=UK&sort=-name")
+	f, err := ParseArtistFilter(q)
+	if err != nil {
+		t.Fatalf("ParseArtistFilter() returned an error: %v", err)
+	}
+	want := ArtistFilter{Query: "queen", CreatedFrom: 1960, MembersMax: 4, Country: "UK", Sort: "-name"}
+	if f != want {
+		t.Errorf("ParseArtistFilter() = %+v, want %+v", f, want)
+	}
+	if got := f.Values().Encode(); got != q.Encode() {
+		t.Errorf("Values().Encode() = %q, want %q", got, q.Encode())
+	}
+
+	for _, bad := range []string{"created_from=abc", "members_min=-1", "sort=height", "sort=%3Cscript%3E"} {
+		q, _ := url.ParseQuery(bad)
+		_, err := ParseArtistFilter(q)
+		if err == nil {
+			t.Errorf("ParseArtistFilter(%q) expected an error", bad)
+			continue
+		}
+		// The message names the parameter but does not echo its value.
+		name, value, _ := strings.Cut(bad, "=")
+		if !strings.Contains(err.Error(), name) || strings.Contains(err.Error(), q.Get(name)) {
+			t.Errorf("ParseArtistFilter(%q) error %q should name %s without its value %q", bad, err, name, value)
+		}
+	}
+}
+
+func TestArtistFilterApply(t *testing.T) {
+	d := newTestDataset()
+
+	tests := []struct {
+		name   string
+		filter ArtistFilter
+		want   []int
+	}{
+		{"No filter", ArtistFilter{}, []int{1, 2, 3}},
+		{"Member name", ArtistFilter{Query: "roger"}, []int{1, 2}},
+		{"Creation range", ArtistFilter{CreatedFrom: 1966}, []int{1}},
+		{"First album", ArtistFilter{AlbumTo: 1972}, []int{2, 3}},
+		{"Members", ArtistFilter{MembersMin: 2, MembersMax: 2}, []int{2}},
+		{"Country", ArtistFilter{Country: "japan"}, []int{1, 3}},
+		{"Sort by name", ArtistFilter{Sort: "name"}, []int{2, 1, 3}},
+		{"Sort by members descending", ArtistFilter{Sort: "-members"}, []int{1, 2, 3}},
+		{"Sort by creation keeps ties stable", ArtistFilter{Sort: "created"}, []int{2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Apply(d)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Apply() returned %d artists, want %v", len(got), tt.want)
+			}
+			for i, id := range tt.want {
+				if got[i].ID != id {
+					t.Errorf("Apply()[%d] = artist %d, want %d", i, got[i].ID, id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+// fixtureArtists and fixtureRelations are the data of the "memory"
+// provider: a few artists in the upstream format, for working offline.
+var fixtureArtists = []Artist{
+	{
+		ID:           1,
+		Image:        "https://groupietrackers.herokuapp.com/api/images/queen.jpeg",
+		Name:         "Queen",
+		Members:      []string{"Freddie Mercury", "Brian May", "John Daecon", "Roger Meddows-Taylor", "Mike Grose", "Barry Mitchell", "Doug Fogie"},
+		CreationDate: 1970,
+		FirstAlbum:   "14-12-1973",
+	},
+	{
+		ID:           2,
+		Image:        "https://groupietrackers.herokuapp.com/api/images/soja.jpeg",
+		Name:         "SOJA",
+		Members:      []string{"Jacob Hemphill", "Bob Jefferson", "Ryan \"Byrd\" Berty", "Ken Brownell", "Patrick O'Shea", "Hellman Escorcia", "Rafael Rodriguez", "Trevor Young"},
+		CreationDate: 1997,
+		FirstAlbum:   "05-06-2002",
+	},
+	{
+		ID:           3,
+		Image:        "https://groupietrackers.herokuapp.com/api/images/pinkfloyd.jpeg",
+		Name:         "Pink Floyd",
+		Members:      []string{"David Gilmour", "Roger Waters", "Richard Wright", "Nick Mason", "Syd Barrett"},
+		CreationDate: 1965,
+		FirstAlbum:   "05-08-1967",
+	},
+}
+
+var fixtureRelations = []Relation{
+	{ID: 1, Locations: map[string][]string{
+		"north_carolina-usa":  {"30-12-2019"},
+		"georgia-usa":         {"22-08-2019"},
+		"los_angeles-usa":     {"20-08-2019"},
+		"saitama-japan":       {"26-01-2020", "28-01-2020"},
+		"osaka-japan":         {"30-01-2020"},
+		"nagoya-japan":        {"31-01-2020"},
+		"penrose-new_zealand": {"07-02-2020"},
+		"dunedin-new_zealand": {"10-02-2020"},
+	}},
+	{ID: 2, Locations: map[string][]string{
+		"playa_del_carmen-mexico":  {"05-12-2019", "06-12-2019", "07-12-2019", "08-12-2019", "09-12-2019"},
+		"papeete-french_polynesia": {"16-11-2019"},
+		"noumea-new_caledonia":     {"15-11-2019"},
+	}},
+	{ID: 3, Locations: map[string][]string{
+		"mumbai-india":    {"07-04-2020"},
+		"new_delhi-india": {"05-04-2020"},
+		"las_vegas-usa":   {"01-03-2020"},
+		"minnesota-usa":   {"05-03-2020"},
+		"birmingham-uk":   {"07-03-2020"},
+		"los_angeles-usa": {"09-03-2020"},
+	}},
+}
@@ -0,0 +1,944 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxGraphQLDepth is how deeply fields may be nested in a query.
+const maxGraphQLDepth = 6
+
+// maxGraphQLFields is how many fields, aliases included, a query may select
+// once its fragments are expanded. Without it a short query repeating
+// aliased fragments asks for an exponential amount of work.
+const maxGraphQLFields = 500
+
+/*
+maxGraphQLCost is how many fields a query may resolve, counting every item
+of the lists it selects. Lists are counted at the most items they can hold
+in the dataset, or at their first argument when it is smaller, so nesting
+lists in each other is only possible with pagination.
+*/
+const maxGraphQLCost = 10000
+
+// maxGraphQLBody caps the size of a POSTed query.
+const maxGraphQLBody = 1 << 20
+
+// graphqlSchema documents the schema served by /graphql.
+const graphqlSchema = `type Query {
+  artists(query: String, country: String, createdFrom: Int, createdTo: Int, albumFrom: Int, albumTo: Int, membersMin: Int, membersMax: Int, sort: String, first: Int, offset: Int): [Artist!]!
+  artist(id: Int!): Artist
+  concerts(artistId: Int, location: String, city: String, country: String, from: String, to: String, first: Int, offset: Int): [Concert!]!
+  locations(query: String, country: String, first: Int, offset: Int): [Location!]!
+  location(name: String!): Location
+}
+
+type Artist {
+  id: Int!
+  name: String!
+  image: String!
+  creationDate: Int!
+  firstAlbum: String!
+  members: [Member!]!
+  concertCount: Int!
+  concerts(location: String, city: String, country: String, from: String, to: String, first: Int, offset: Int): [Concert!]!
+  locations: [Location!]!
+}
+
+type Member {
+  name: String!
+  artist: Artist!
+}
+
+type Concert {
+  "YYYY-MM-DD"
+  date: String!
+  artist: Artist!
+  location: Location!
+  "Set when a local override renamed or added the concert"
+  corrected: Boolean!
+}
+
+type Location {
+  "The raw location key, e.g. north_carolina-usa"
+  name: String!
+  city: String!
+  country: String!
+  concerts(artistId: Int, from: String, to: String, first: Int, offset: Int): [Concert!]!
+  artists: [Artist!]!
+}
+`
+
+// gqlArgs are the coerced arguments of a field; absent and null ones are missing.
+type gqlArgs map[string]interface{}
+
+func (a gqlArgs) Int(name string) (int, bool) {
+	v, ok := a[name].(int)
+	return v, ok
+}
+
+func (a gqlArgs) String(name string) string {
+	v, _ := a[name].(string)
+	return v
+}
+
+/*
+gqlFieldDef describes one field of an object type. Type is a scalar (Int,
+String, Boolean) or an object type; List fields resolve to []interface{}
+of at most Size items. Args maps argument names to their types, "!"
+marking required ones.
+*/
+type gqlFieldDef struct {
+	Type    string
+	List    bool
+	Size    func(s gqlSizes) int
+	Args    map[string]string
+	Resolve func(ex *gqlExecution, parent interface{}, args gqlArgs) (interface{}, error)
+}
+
+// gqlSizes are the largest lists each list field can resolve to in a
+// dataset, which the cost of a query is estimated from.
+type gqlSizes struct {
+	artists, concerts, locations      int // in the whole dataset
+	members                           int // per artist
+	artistConcerts, artistLocations   int
+	locationConcerts, locationArtists int // per location
+}
+
+func newGQLSizes(d *Dataset) gqlSizes {
+	s := gqlSizes{artists: len(d.Artists), concerts: len(d.AllConcerts()), locations: len(d.Locations())}
+	raise := func(max *int, n int) {
+		if n > *max {
+			*max = n
+		}
+	}
+	for _, a := range d.Artists {
+		concerts := d.Concerts(a.ID)
+		locations := map[string]bool{}
+		for _, c := range concerts {
+			locations[c.Location] = true
+		}
+		raise(&s.members, len(a.Members))
+		raise(&s.artistConcerts, len(concerts))
+		raise(&s.artistLocations, len(locations))
+	}
+	for _, name := range d.Locations() {
+		concerts := d.ConcertsAt(name)
+		artists := map[int]bool{}
+		for _, c := range concerts {
+			artists[c.ArtistID] = true
+		}
+		raise(&s.locationConcerts, len(concerts))
+		raise(&s.locationArtists, len(artists))
+	}
+	return s
+}
+
+var (
+	concertArgs = map[string]string{
+		"location": "String", "city": "String", "country": "String",
+		"from": "String", "to": "String", "first": "Int", "offset": "Int",
+	}
+	locationConcertArgs = map[string]string{
+		"artistId": "Int", "from": "String", "to": "String", "first": "Int", "offset": "Int",
+	}
+)
+
+// gqlTypes is the schema: every object type and its fields.
+var gqlTypes = map[string]map[string]gqlFieldDef{
+	"Query": {
+		"artists": {Type: "Artist", List: true, Size: func(s gqlSizes) int { return s.artists }, Args: map[string]string{
+			"query": "String", "country": "String", "createdFrom": "Int", "createdTo": "Int",
+			"albumFrom": "Int", "albumTo": "Int", "membersMin": "Int", "membersMax": "Int",
+			"sort": "String", "first": "Int", "offset": "Int",
+		}, Resolve: resolveArtists},
+		"artist": {Type: "Artist", Args: map[string]string{"id": "Int!"}, Resolve: func(ex *gqlExecution, _ interface{}, args gqlArgs) (interface{}, error) {
+			id, _ := args.Int("id")
+			if a, ok := ex.data.Artist(id); ok {
+				return a, nil
+			}
+			return nil, nil
+		}},
+		"concerts": {Type: "Concert", List: true, Size: func(s gqlSizes) int { return s.concerts }, Args: map[string]string{
+			"artistId": "Int", "location": "String", "city": "String", "country": "String",
+			"from": "String", "to": "String", "first": "Int", "offset": "Int",
+		}, Resolve: func(ex *gqlExecution, _ interface{}, args gqlArgs) (interface{}, error) {
+			if location := args.String("location"); location != "" {
+				return filterConcerts(ex.data.ConcertsAt(location), args)
+			}
+			return filterConcerts(ex.allConcerts(args), args)
+		}},
+		"locations": {Type: "Location", List: true, Size: func(s gqlSizes) int { return s.locations }, Args: map[string]string{
+			"query": "String", "country": "String", "first": "Int", "offset": "Int",
+		}, Resolve: resolveLocations},
+		"location": {Type: "Location", Args: map[string]string{"name": "String!"}, Resolve: func(ex *gqlExecution, _ interface{}, args gqlArgs) (interface{}, error) {
+			name := args.String("name")
+			if len(ex.data.ConcertsAt(name)) > 0 {
+				return name, nil
+			}
+			return nil, nil
+		}},
+	},
+	"Artist": {
+		"id":           {Type: "Int", Resolve: artistField(func(a Artist) interface{} { return a.ID })},
+		"name":         {Type: "String", Resolve: artistField(func(a Artist) interface{} { return a.Name })},
+		"image":        {Type: "String", Resolve: artistField(func(a Artist) interface{} { return a.Image })},
+		"creationDate": {Type: "Int", Resolve: artistField(func(a Artist) interface{} { return a.CreationDate })},
+		"firstAlbum":   {Type: "String", Resolve: artistField(func(a Artist) interface{} { return a.FirstAlbum })},
+		"members": {Type: "Member", List: true, Size: func(s gqlSizes) int { return s.members }, Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			a := parent.(Artist)
+			members := make([]interface{}, len(a.Members))
+			for i, m := range a.Members {
+				members[i] = gqlMember{Name: m, Artist: a}
+			}
+			return members, nil
+		}},
+		"concertCount": {Type: "Int", Resolve: func(ex *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return len(ex.data.Concerts(parent.(Artist).ID)), nil
+		}},
+		"concerts": {Type: "Concert", List: true, Size: func(s gqlSizes) int { return s.artistConcerts }, Args: concertArgs, Resolve: func(ex *gqlExecution, parent interface{}, args gqlArgs) (interface{}, error) {
+			return filterConcerts(ex.artistConcerts(parent.(Artist)), args)
+		}},
+		"locations": {Type: "Location", List: true, Size: func(s gqlSizes) int { return s.artistLocations }, Resolve: func(ex *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			seen := map[string]bool{}
+			locations := []string{}
+			for _, c := range ex.data.Concerts(parent.(Artist).ID) {
+				if !seen[c.Location] {
+					seen[c.Location] = true
+					locations = append(locations, c.Location)
+				}
+			}
+			sort.Strings(locations)
+			return stringList(locations), nil
+		}},
+	},
+	"Member": {
+		"name": {Type: "String", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return parent.(gqlMember).Name, nil
+		}},
+		"artist": {Type: "Artist", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return parent.(gqlMember).Artist, nil
+		}},
+	},
+	"Concert": {
+		"date": {Type: "String", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return parent.(ArtistConcert).Concert.Date.Format("2006-01-02"), nil
+		}},
+		"artist": {Type: "Artist", Resolve: func(ex *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			a, _ := ex.data.Artist(parent.(ArtistConcert).ArtistID)
+			return a, nil
+		}},
+		"location": {Type: "Location", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return parent.(ArtistConcert).Concert.Location, nil
+		}},
+		"corrected": {Type: "Boolean", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return parent.(ArtistConcert).Concert.Corrected, nil
+		}},
+	},
+	"Location": {
+		"name": {Type: "String", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			return parent.(string), nil
+		}},
+		"city": {Type: "String", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			city, _ := ParseLocation(parent.(string))
+			return city, nil
+		}},
+		"country": {Type: "String", Resolve: func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			_, country := ParseLocation(parent.(string))
+			return country, nil
+		}},
+		"concerts": {Type: "Concert", List: true, Size: func(s gqlSizes) int { return s.locationConcerts }, Args: locationConcertArgs, Resolve: func(ex *gqlExecution, parent interface{}, args gqlArgs) (interface{}, error) {
+			return filterConcerts(ex.data.ConcertsAt(parent.(string)), args)
+		}},
+		"artists": {Type: "Artist", List: true, Size: func(s gqlSizes) int { return s.locationArtists }, Resolve: func(ex *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+			seen := map[int]bool{}
+			var artists []interface{}
+			for _, c := range ex.data.ConcertsAt(parent.(string)) {
+				if !seen[c.ArtistID] {
+					seen[c.ArtistID] = true
+					a, _ := ex.data.Artist(c.ArtistID)
+					artists = append(artists, a)
+				}
+			}
+			sort.Slice(artists, func(i, j int) bool { return artists[i].(Artist).ID < artists[j].(Artist).ID })
+			return artists, nil
+		}},
+	},
+}
+
+// gqlMember is a member of an artist; members have no ID of their own.
+type gqlMember struct {
+	Name   string
+	Artist Artist
+}
+
+func artistField(get func(Artist) interface{}) func(*gqlExecution, interface{}, gqlArgs) (interface{}, error) {
+	return func(_ *gqlExecution, parent interface{}, _ gqlArgs) (interface{}, error) {
+		return get(parent.(Artist)), nil
+	}
+}
+
+func stringList(values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
+
+// paginate applies the first and offset arguments to a list of n items.
+func paginate(n int, args gqlArgs) (start, end int, err error) {
+	end = n
+	if offset, ok := args.Int("offset"); ok {
+		if offset < 0 {
+			return 0, 0, fmt.Errorf("offset must not be negative")
+		}
+		start = offset
+		if start > n {
+			start = n
+		}
+	}
+	if first, ok := args.Int("first"); ok {
+		if first < 0 {
+			return 0, 0, fmt.Errorf("first must not be negative")
+		}
+		if start+first < end {
+			end = start + first
+		}
+	}
+	return start, end, nil
+}
+
+// resolveArtists filters artists with the same ArtistFilter as /artists.
+func resolveArtists(ex *gqlExecution, _ interface{}, args gqlArgs) (interface{}, error) {
+	f := ArtistFilter{Query: args.String("query"), Country: args.String("country"), Sort: args.String("sort")}
+	f.CreatedFrom, _ = args.Int("createdFrom")
+	f.CreatedTo, _ = args.Int("createdTo")
+	f.AlbumFrom, _ = args.Int("albumFrom")
+	f.AlbumTo, _ = args.Int("albumTo")
+	f.MembersMin, _ = args.Int("membersMin")
+	f.MembersMax, _ = args.Int("membersMax")
+	if f.Sort != "" && !sortKeys[strings.TrimPrefix(f.Sort, "-")] {
+		return nil, fmt.Errorf("invalid sort %q", f.Sort)
+	}
+
+	artists := f.Apply(ex.data)
+	start, end, err := paginate(len(artists), args)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, 0, end-start)
+	for _, a := range artists[start:end] {
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+func resolveLocations(ex *gqlExecution, _ interface{}, args gqlArgs) (interface{}, error) {
+	query, country := strings.ToLower(args.String("query")), args.String("country")
+	var locations []string
+	for _, location := range ex.data.Locations() {
+		city, c := ParseLocation(location)
+		if country != "" && !strings.EqualFold(c, country) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(location+" "+city+" "+c), query) {
+			continue
+		}
+		locations = append(locations, location)
+	}
+	start, end, err := paginate(len(locations), args)
+	if err != nil {
+		return nil, err
+	}
+	return stringList(locations[start:end]), nil
+}
+
+// filterConcerts applies the concert arguments to concerts in date order.
+func filterConcerts(concerts []ArtistConcert, args gqlArgs) (interface{}, error) {
+	var from, to time.Time
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := args.String(bound.name); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a date in YYYY-MM-DD format", bound.name)
+			}
+			*bound.dst = t
+		}
+	}
+	artistID, byArtist := args.Int("artistId")
+	location, city, country := args.String("location"), args.String("city"), args.String("country")
+
+	var matched []ArtistConcert
+	for _, c := range concerts {
+		switch {
+		case byArtist && c.ArtistID != artistID,
+			location != "" && c.Concert.Location != location,
+			city != "" && !strings.EqualFold(c.Concert.City, city),
+			country != "" && !strings.EqualFold(c.Concert.Country, country),
+			!from.IsZero() && c.Concert.Date.Before(from),
+			!to.IsZero() && c.Concert.Date.After(to):
+			continue
+		}
+		matched = append(matched, c)
+	}
+
+	start, end, err := paginate(len(matched), args)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, 0, end-start)
+	for _, c := range matched[start:end] {
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// gqlError is an error in a GraphQL response.
+type gqlError struct {
+	Message   string        `json:"message"`
+	Locations []gqlPos      `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+// gqlResponse is the body of every /graphql response. Data is left out
+// when the request fails before execution.
+type gqlResponse struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+// gqlObject is a result object that keeps the field order of the query.
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *gqlObject) set(key string, v interface{}) {
+	if o.values == nil {
+		o.values = map[string]interface{}{}
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlExecution is the state of one query.
+type gqlExecution struct {
+	doc    *gqlDocument
+	vars   map[string]interface{}
+	data   *Dataset
+	errors []gqlError
+}
+
+/*
+allConcerts lists the concerts of every artist in date order, narrowed to
+the days of the from and to arguments when they are valid dates.
+*/
+func (ex *gqlExecution) allConcerts(args gqlArgs) []ArtistConcert {
+	from, _ := time.Parse("2006-01-02", args.String("from"))
+	to, _ := time.Parse("2006-01-02", args.String("to"))
+	return ex.data.ConcertsBetween(from, to)
+}
+
+func (ex *gqlExecution) artistConcerts(a Artist) []ArtistConcert {
+	var list []ArtistConcert
+	for _, c := range ex.data.Concerts(a.ID) {
+		list = append(list, ArtistConcert{ArtistID: a.ID, ArtistName: a.Name, Concert: c})
+	}
+	return list
+}
+
+// graphqlRequest is a query as sent by a client.
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+/*
+ExecuteGraphQL runs a query against d. The status is 400 when the query
+cannot run at all (syntax, validation or variable errors) and 200 otherwise;
+errors while resolving fields are reported next to the partial data.
+*/
+func ExecuteGraphQL(d *Dataset, req graphqlRequest) (gqlResponse, int) {
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		if se, ok := err.(*gqlSyntaxError); ok {
+			return gqlResponse{Errors: []gqlError{{Message: "Syntax Error: " + se.Message, Locations: []gqlPos{se.Pos}}}}, http.StatusBadRequest
+		}
+		return gqlResponse{Errors: []gqlError{{Message: err.Error()}}}, http.StatusBadRequest
+	}
+
+	op, errs := selectOperation(doc, req.OperationName)
+	if errs == nil {
+		ex := &gqlExecution{doc: doc, data: d}
+		if ex.vars, errs = coerceVariables(op, req.Variables); errs == nil {
+			errs = ex.validate(op)
+		}
+		if errs == nil {
+			result := ex.selectionSet("Query", nil, op.Selections, nil)
+			return gqlResponse{Data: result, Errors: ex.errors}, http.StatusOK
+		}
+	}
+	return gqlResponse{Errors: errs}, http.StatusBadRequest
+}
+
+func selectOperation(doc *gqlDocument, name string) (*gqlOperation, []gqlError) {
+	var op *gqlOperation
+	switch {
+	case name != "":
+		for _, o := range doc.Operations {
+			if o.Name == name {
+				op = o
+			}
+		}
+		if op == nil {
+			return nil, []gqlError{{Message: fmt.Sprintf("Unknown operation named %q.", name)}}
+		}
+	case len(doc.Operations) > 1:
+		return nil, []gqlError{{Message: "Must provide operation name if query contains multiple operations."}}
+	default:
+		op = doc.Operations[0]
+	}
+	if op.Kind != "query" {
+		return nil, []gqlError{{Message: fmt.Sprintf("Only queries are supported, not %ss.", op.Kind)}}
+	}
+	return op, nil
+}
+
+// coerceVariables checks the JSON variables against the operation's definitions.
+func coerceVariables(op *gqlOperation, raw map[string]interface{}) (map[string]interface{}, []gqlError) {
+	vars := map[string]interface{}{}
+	var errs []gqlError
+	for _, def := range op.Variables {
+		if def.Type.Elem != nil || !isScalar(def.Type.Name) {
+			errs = append(errs, gqlError{Message: fmt.Sprintf("Variable \"$%s\" has unsupported type %s.", def.Name, def.Type), Locations: []gqlPos{def.Pos}})
+			continue
+		}
+		value, ok := raw[def.Name]
+		if !ok && def.Default != nil {
+			value, ok = def.Default, true
+		}
+		if !ok || value == nil {
+			if def.Type.NonNull {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Variable \"$%s\" of required type %s was not provided.", def.Name, def.Type), Locations: []gqlPos{def.Pos}})
+			}
+			continue
+		}
+		v, err := coerceScalar(def.Type.Name, value)
+		if err != nil {
+			errs = append(errs, gqlError{Message: fmt.Sprintf("Variable \"$%s\" got invalid value: %v.", def.Name, err), Locations: []gqlPos{def.Pos}})
+			continue
+		}
+		vars[def.Name] = v
+	}
+	return vars, errs
+}
+
+func isScalar(name string) bool {
+	return name == "Int" || name == "String" || name == "Boolean"
+}
+
+// coerceScalar converts a query literal or a JSON value to a scalar type.
+func coerceScalar(typ string, v interface{}) (interface{}, error) {
+	switch typ {
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %d", n)
+			}
+			return n, nil
+		case float64:
+			if n != math.Trunc(n) || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %v", n)
+			}
+			return int(n), nil
+		}
+		return nil, fmt.Errorf("Int cannot represent %s", describeValue(v))
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("String cannot represent %s", describeValue(v))
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("Boolean cannot represent %s", describeValue(v))
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func describeValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case gqlEnum:
+		return string(v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(v)
+}
+
+/*
+argument resolves a literal or variable to a value of typ. ok is false for
+values that are absent or null.
+*/
+func (ex *gqlExecution) argument(typ string, value interface{}) (v interface{}, ok bool, err error) {
+	if name, isVar := value.(gqlVariable); isVar {
+		v, ok = ex.vars[string(name)]
+		return v, ok, nil
+	}
+	if value == nil {
+		return nil, false, nil
+	}
+	v, err = coerceScalar(strings.TrimSuffix(typ, "!"), value)
+	return v, err == nil, err
+}
+
+// arguments coerces the arguments of a field or directive.
+func (ex *gqlExecution) arguments(defs map[string]string, given []gqlArgument) (gqlArgs, error) {
+	args := gqlArgs{}
+	for _, arg := range given {
+		typ, ok := defs[arg.Name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown argument %q.", arg.Name)
+		}
+		v, ok, err := ex.argument(typ, arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q has an invalid value: %v.", arg.Name, err)
+		}
+		if ok {
+			args[arg.Name] = v
+		}
+	}
+	for name, typ := range defs {
+		if _, ok := args[name]; !ok && strings.HasSuffix(typ, "!") {
+			return nil, fmt.Errorf("Argument %q of type %s is required.", name, typ)
+		}
+	}
+	return args, nil
+}
+
+var directiveArgs = map[string]string{"if": "Boolean!"}
+
+// included applies @include and @skip.
+func (ex *gqlExecution) included(dirs []gqlDirective) bool {
+	for _, d := range dirs {
+		args, _ := ex.arguments(directiveArgs, d.Args)
+		cond, _ := args["if"].(bool)
+		if (d.Name == "include" && !cond) || (d.Name == "skip" && cond) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+validate checks the whole operation against the schema before anything is
+executed: fields, arguments, variable uses, fragments, the depth limit, the
+field budget and the cost budget.
+*/
+func (ex *gqlExecution) validate(op *gqlOperation) []gqlError {
+	var errs []gqlError
+	defined := map[string]string{}
+	for _, def := range op.Variables {
+		defined[def.Name] = def.Type.String()
+	}
+
+	selected, cost := 0, 0
+	sizes := newGQLSizes(ex.data)
+	var walk func(typeName string, selections []gqlSelection, depth, count int, fragments []string)
+	checkArgs := func(defs map[string]string, args []gqlArgument, pos gqlPos) {
+		for _, arg := range args {
+			name, isVar := arg.Value.(gqlVariable)
+			if !isVar {
+				continue
+			}
+			typ, ok := defined[string(name)]
+			if !ok {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Variable \"$%s\" is not defined.", name), Locations: []gqlPos{arg.Pos}})
+				continue
+			}
+			if want, known := defs[arg.Name]; known && strings.TrimSuffix(want, "!") != strings.TrimSuffix(typ, "!") {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Variable \"$%s\" of type %s cannot be used for argument %q of type %s.", name, typ, arg.Name, want), Locations: []gqlPos{arg.Pos}})
+			}
+		}
+		if _, err := ex.arguments(defs, args); err != nil {
+			errs = append(errs, gqlError{Message: err.Error(), Locations: []gqlPos{pos}})
+		}
+	}
+
+	walk = func(typeName string, selections []gqlSelection, depth, count int, fragments []string) {
+		for _, sel := range selections {
+			if selected > maxGraphQLFields || cost > maxGraphQLCost {
+				return
+			}
+			for _, d := range sel.Directives {
+				if d.Name != "include" && d.Name != "skip" {
+					errs = append(errs, gqlError{Message: fmt.Sprintf("Unknown directive \"@%s\".", d.Name), Locations: []gqlPos{d.Pos}})
+					continue
+				}
+				checkArgs(directiveArgs, d.Args, d.Pos)
+			}
+
+			switch {
+			case sel.Spread != "":
+				f, ok := ex.doc.Fragments[sel.Spread]
+				if !ok {
+					errs = append(errs, gqlError{Message: fmt.Sprintf("Unknown fragment %q.", sel.Spread), Locations: []gqlPos{sel.Pos}})
+					continue
+				}
+				if containsString(fragments, f.Name) {
+					errs = append(errs, gqlError{Message: fmt.Sprintf("Cannot spread fragment %q within itself.", f.Name), Locations: []gqlPos{sel.Pos}})
+					continue
+				}
+				if f.TypeCondition != typeName {
+					errs = append(errs, gqlError{Message: fmt.Sprintf("Fragment %q cannot be spread here as objects of type %q can never be of type %q.", f.Name, typeName, f.TypeCondition), Locations: []gqlPos{sel.Pos}})
+					continue
+				}
+				walk(typeName, f.Selections, depth, count, append(fragments, f.Name))
+				continue
+			case sel.Inline:
+				if sel.TypeCondition != "" && sel.TypeCondition != typeName {
+					errs = append(errs, gqlError{Message: fmt.Sprintf("Fragment cannot be spread here as objects of type %q can never be of type %q.", typeName, sel.TypeCondition), Locations: []gqlPos{sel.Pos}})
+					continue
+				}
+				walk(typeName, sel.Selections, depth, count, fragments)
+				continue
+			}
+
+			if selected++; selected > maxGraphQLFields {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Query selects too many fields: the limit is %d, counting aliases and expanded fragments.", maxGraphQLFields), Locations: []gqlPos{sel.Pos}})
+				return
+			}
+			// count is how many times the selection is resolved.
+			if cost += count; cost > maxGraphQLCost {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Query is too expensive: it may resolve %d fields or more, the limit is %d. Use first to page through lists.", cost, maxGraphQLCost), Locations: []gqlPos{sel.Pos}})
+				return
+			}
+			if depth > maxGraphQLDepth {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Query is nested too deeply: the limit is %d levels.", maxGraphQLDepth), Locations: []gqlPos{sel.Pos}})
+				return
+			}
+			if sel.Name == "__typename" {
+				if len(sel.Args) > 0 || sel.Selections != nil {
+					errs = append(errs, gqlError{Message: "Field \"__typename\" takes no arguments or subfields.", Locations: []gqlPos{sel.Pos}})
+				}
+				continue
+			}
+			def, ok := gqlTypes[typeName][sel.Name]
+			if !ok {
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Cannot query field %q on type %q.", sel.Name, typeName), Locations: []gqlPos{sel.Pos}})
+				continue
+			}
+			checkArgs(def.Args, sel.Args, sel.Pos)
+
+			switch {
+			case isScalar(def.Type) && sel.Selections != nil:
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Field %q must not have a selection since type %q has no subfields.", sel.Name, def.Type), Locations: []gqlPos{sel.Pos}})
+			case !isScalar(def.Type) && sel.Selections == nil:
+				errs = append(errs, gqlError{Message: fmt.Sprintf("Field %q of type %q must have a selection of subfields.", sel.Name, def.Type), Locations: []gqlPos{sel.Pos}})
+			case !isScalar(def.Type):
+				items := count
+				if def.List {
+					n := def.Size(sizes)
+					if args, err := ex.arguments(def.Args, sel.Args); err == nil {
+						if first, ok := args.Int("first"); ok && first >= 0 && first < n {
+							n = first
+						}
+					}
+					items *= n
+				}
+				walk(def.Type, sel.Selections, depth+1, items, fragments)
+			}
+		}
+	}
+	walk("Query", op.Selections, 1, 1, nil)
+	return errs
+}
+
+// collectFields groups the fields selected on an object by response key,
+// expanding fragments and applying directives.
+func (ex *gqlExecution) collectFields(selections []gqlSelection, keys *[]string, fields map[string][]gqlSelection) {
+	for _, sel := range selections {
+		if !ex.included(sel.Directives) {
+			continue
+		}
+		switch {
+		case sel.Spread != "":
+			ex.collectFields(ex.doc.Fragments[sel.Spread].Selections, keys, fields)
+		case sel.Inline:
+			ex.collectFields(sel.Selections, keys, fields)
+		default:
+			key := sel.ResponseKey()
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], sel)
+		}
+	}
+}
+
+// selectionSet resolves the selected fields of one object.
+func (ex *gqlExecution) selectionSet(typeName string, parent interface{}, selections []gqlSelection, path []interface{}) *gqlObject {
+	var keys []string
+	fields := map[string][]gqlSelection{}
+	ex.collectFields(selections, &keys, fields)
+
+	result := &gqlObject{}
+	for _, key := range keys {
+		field := fields[key][0]
+		fieldPath := append(append([]interface{}(nil), path...), key)
+		if field.Name == "__typename" {
+			result.set(key, typeName)
+			continue
+		}
+
+		def := gqlTypes[typeName][field.Name]
+		args, err := ex.arguments(def.Args, field.Args)
+		var value interface{}
+		if err == nil {
+			value, err = def.Resolve(ex, parent, args)
+		}
+		if err != nil {
+			ex.errors = append(ex.errors, gqlError{Message: err.Error(), Locations: []gqlPos{field.Pos}, Path: fieldPath})
+			result.set(key, nil)
+			continue
+		}
+
+		var sub []gqlSelection
+		for _, f := range fields[key] {
+			sub = append(sub, f.Selections...)
+		}
+		result.set(key, ex.complete(def, value, sub, fieldPath))
+	}
+	return result
+}
+
+// complete turns a resolved value into its result.
+func (ex *gqlExecution) complete(def gqlFieldDef, value interface{}, sub []gqlSelection, path []interface{}) interface{} {
+	if value == nil {
+		if def.List {
+			return []interface{}{}
+		}
+		return nil
+	}
+	if isScalar(def.Type) {
+		return value
+	}
+	if !def.List {
+		return ex.selectionSet(def.Type, value, sub, path)
+	}
+	items := value.([]interface{})
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		list[i] = ex.selectionSet(def.Type, item, sub, append(append([]interface{}(nil), path...), i))
+	}
+	return list
+}
+
+/*
+GraphQLHandler answers GraphQL queries over the catalogue. Queries come as
+GET /graphql?query=...&variables=... or as a POSTed JSON body
+{"query", "variables", "operationName"}; a body sent as application/graphql
+is the query itself. The schema is documented at /graphql/schema.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	badRequest := func(msg string) {
+		writeJSON(w, http.StatusBadRequest, gqlResponse{Errors: []gqlError{{Message: msg}}})
+	}
+
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				badRequest("Variables must be a JSON object.")
+				return
+			}
+		}
+	} else {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxGraphQLBody+1))
+		if err != nil {
+			badRequest("Error reading the request body.")
+			return
+		}
+		if len(body) > maxGraphQLBody {
+			writeJSON(w, http.StatusRequestEntityTooLarge, gqlResponse{Errors: []gqlError{{Message: "The query is too large."}}})
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			badRequest("The body must be a JSON object with a query.")
+			return
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		badRequest("Must provide a query.")
+		return
+	}
+
+	data, err := requestDataset(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, gqlResponse{Errors: []gqlError{{Message: "Error fetching artists"}}})
+		return
+	}
+	resp, status := ExecuteGraphQL(data, req)
+	writeJSON(w, status, resp)
+}
+
+/*
+GraphQLSchemaHandler serves the schema of /graphql in the GraphQL schema
+definition language.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func GraphQLSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, graphqlSchema)
+}
@@ -0,0 +1,804 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+This file parses the executable subset of the GraphQL language used by the
+/graphql endpoint: operations with variables, fields with aliases and
+arguments, fragments, inline fragments and the @include/@skip directives.
+Type system definitions are not accepted in queries.
+*/
+
+// gqlDocument is a parsed query document.
+type gqlDocument struct {
+	Operations []*gqlOperation
+	Fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	Kind       string // query, mutation or subscription
+	Name       string
+	Variables  []gqlVariableDef
+	Selections []gqlSelection
+}
+
+type gqlVariableDef struct {
+	Name    string
+	Type    gqlTypeRef
+	Default interface{} // nil when there is none
+	Pos     gqlPos
+}
+
+// gqlTypeRef is a type in a variable definition, such as [Int!]!.
+type gqlTypeRef struct {
+	Name    string      // named type, empty for lists
+	Elem    *gqlTypeRef // list element type
+	NonNull bool
+}
+
+func (t gqlTypeRef) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+type gqlFragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []gqlSelection
+}
+
+/*
+gqlSelection is a field, a fragment spread (Spread set) or an inline
+fragment (Inline set) in a selection set.
+*/
+type gqlSelection struct {
+	Alias      string
+	Name       string
+	Args       []gqlArgument
+	Directives []gqlDirective
+	Selections []gqlSelection
+
+	Spread        string
+	Inline        bool
+	TypeCondition string
+
+	Pos gqlPos
+}
+
+// ResponseKey is the name the field has in the result.
+func (s gqlSelection) ResponseKey() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+type gqlArgument struct {
+	Name  string
+	Value interface{}
+	Pos   gqlPos
+}
+
+type gqlDirective struct {
+	Name string
+	Args []gqlArgument
+	Pos  gqlPos
+}
+
+/*
+Values in a query are represented as nil (null), bool, int, float64,
+string, gqlEnum, gqlVariable, []interface{} and map[string]interface{}.
+*/
+type (
+	gqlEnum     string
+	gqlVariable string
+)
+
+// gqlPos is a line and column in the query, both starting at 1.
+type gqlPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlSyntaxError is a query that does not parse.
+type gqlSyntaxError struct {
+	Pos     gqlPos
+	Message string
+}
+
+func (e *gqlSyntaxError) Error() string {
+	return fmt.Sprintf("Syntax Error: %s (line %d, column %d)", e.Message, e.Pos.Line, e.Pos.Column)
+}
+
+// Token kinds.
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type gqlToken struct {
+	Kind  int
+	Value string
+	Pos   gqlPos
+}
+
+// gqlLexer splits a query into tokens.
+type gqlLexer struct {
+	src   string
+	i     int
+	line  int
+	colAt int // offset up to which col counts the runes of the line
+	col   int
+}
+
+// pos returns the position of offset i. The column is counted on from the
+// previous call, as the lexer only moves forward, so a long line is scanned
+// once rather than once per token.
+func (l *gqlLexer) pos() gqlPos {
+	l.col += utf8.RuneCountInString(l.src[l.colAt:l.i])
+	l.colAt = l.i
+	return gqlPos{Line: l.line, Column: l.col + 1}
+}
+
+func (l *gqlLexer) errorf(format string, args ...interface{}) error {
+	return &gqlSyntaxError{Pos: l.pos(), Message: fmt.Sprintf(format, args...)}
+}
+
+func (l *gqlLexer) newline() {
+	l.line++
+	l.colAt, l.col = l.i, 0
+}
+
+// skipIgnored skips whitespace, commas, comments and a byte order mark.
+func (l *gqlLexer) skipIgnored() {
+	for l.i < len(l.src) {
+		switch c := l.src[l.i]; {
+		case c == '\n':
+			l.i++
+			l.newline()
+		case c == '\r':
+			l.i++
+			if l.i < len(l.src) && l.src[l.i] == '\n' {
+				l.i++
+			}
+			l.newline()
+		case c == ' ' || c == '\t' || c == ',':
+			l.i++
+		case c == '#':
+			for l.i < len(l.src) && l.src[l.i] != '\n' && l.src[l.i] != '\r' {
+				l.i++
+			}
+		case strings.HasPrefix(l.src[l.i:], "\ufeff"):
+			l.i += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *gqlLexer) next() (gqlToken, error) {
+	l.skipIgnored()
+	pos := l.pos()
+	if l.i >= len(l.src) {
+		return gqlToken{Kind: tokEOF, Pos: pos}, nil
+	}
+
+	c := l.src[l.i]
+	switch {
+	case strings.HasPrefix(l.src[l.i:], "..."):
+		l.i += 3
+		return gqlToken{Kind: tokPunct, Value: "...", Pos: pos}, nil
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.i++
+		return gqlToken{Kind: tokPunct, Value: string(c), Pos: pos}, nil
+	case isNameStart(c):
+		start := l.i
+		for l.i < len(l.src) && (isNameStart(l.src[l.i]) || isDigit(l.src[l.i])) {
+			l.i++
+		}
+		return gqlToken{Kind: tokName, Value: l.src[start:l.i], Pos: pos}, nil
+	case c == '-' || isDigit(c):
+		return l.number(pos)
+	case strings.HasPrefix(l.src[l.i:], `"""`):
+		return l.blockString(pos)
+	case c == '"':
+		return l.string(pos)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.i:])
+	return gqlToken{}, l.errorf("unexpected character %q", r)
+}
+
+func (l *gqlLexer) number(pos gqlPos) (gqlToken, error) {
+	start := l.i
+	if l.src[l.i] == '-' {
+		l.i++
+	}
+	digits := func() int {
+		n := 0
+		for l.i < len(l.src) && isDigit(l.src[l.i]) {
+			l.i++
+			n++
+		}
+		return n
+	}
+	intStart := l.i
+	if digits() == 0 {
+		return gqlToken{}, l.errorf("invalid number")
+	}
+	if l.src[intStart] == '0' && l.i-intStart > 1 {
+		return gqlToken{}, l.errorf("invalid number, unexpected digit after 0")
+	}
+	kind := tokInt
+	if l.i < len(l.src) && l.src[l.i] == '.' {
+		l.i++
+		kind = tokFloat
+		if digits() == 0 {
+			return gqlToken{}, l.errorf("invalid number, expected digit after '.'")
+		}
+	}
+	if l.i < len(l.src) && (l.src[l.i] == 'e' || l.src[l.i] == 'E') {
+		l.i++
+		kind = tokFloat
+		if l.i < len(l.src) && (l.src[l.i] == '+' || l.src[l.i] == '-') {
+			l.i++
+		}
+		if digits() == 0 {
+			return gqlToken{}, l.errorf("invalid number, expected digit in exponent")
+		}
+	}
+	if l.i < len(l.src) && (isNameStart(l.src[l.i]) || l.src[l.i] == '.') {
+		return gqlToken{}, l.errorf("invalid number, unexpected %q", l.src[l.i])
+	}
+	return gqlToken{Kind: kind, Value: l.src[start:l.i], Pos: pos}, nil
+}
+
+func (l *gqlLexer) string(pos gqlPos) (gqlToken, error) {
+	l.i++ // opening quote
+	var b strings.Builder
+	for l.i < len(l.src) {
+		c := l.src[l.i]
+		switch {
+		case c == '"':
+			l.i++
+			return gqlToken{Kind: tokString, Value: b.String(), Pos: pos}, nil
+		case c == '\n' || c == '\r':
+			return gqlToken{}, l.errorf("unterminated string")
+		case c == '\\':
+			if l.i+1 >= len(l.src) {
+				return gqlToken{}, l.errorf("unterminated string")
+			}
+			esc := l.src[l.i+1]
+			l.i += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.i+4 > len(l.src) {
+					return gqlToken{}, l.errorf("invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(l.src[l.i:l.i+4], 16, 32)
+				if err != nil {
+					return gqlToken{}, l.errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(n))
+				l.i += 4
+			default:
+				return gqlToken{}, l.errorf("invalid escape \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+			l.i++
+		}
+	}
+	return gqlToken{}, l.errorf("unterminated string")
+}
+
+// blockString reads a """block string""", removing common indentation.
+func (l *gqlLexer) blockString(pos gqlPos) (gqlToken, error) {
+	l.i += 3
+	var b strings.Builder
+	for l.i < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.i:], `"""`):
+			l.i += 3
+			return gqlToken{Kind: tokString, Value: blockStringValue(b.String()), Pos: pos}, nil
+		case strings.HasPrefix(l.src[l.i:], `\"""`):
+			b.WriteString(`"""`)
+			l.i += 4
+		default:
+			c := l.src[l.i]
+			b.WriteByte(c)
+			l.i++
+			if c == '\n' {
+				l.newline()
+			}
+		}
+	}
+	return gqlToken{}, l.errorf("unterminated block string")
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// gqlParser is a recursive descent parser over the lexer's tokens.
+type gqlParser struct {
+	lex *gqlLexer
+	tok gqlToken
+}
+
+// parseGraphQL parses a query document.
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{lex: &gqlLexer{src: src, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &gqlDocument{Fragments: map[string]*gqlFragment{}}
+	for p.tok.Kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &gqlOperation{Kind: "query", Selections: sel})
+		case p.tok.Kind == tokName && p.tok.Value == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.Fragments[f.Name]; dup {
+				return nil, p.errorf("there can be only one fragment named %q", f.Name)
+			}
+			doc.Fragments[f.Name] = f
+		case p.tok.Kind == tokName && (p.tok.Value == "query" || p.tok.Value == "mutation" || p.tok.Value == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, &gqlSyntaxError{Pos: gqlPos{Line: 1, Column: 1}, Message: "the document has no operation"}
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	return &gqlSyntaxError{Pos: p.tok.Pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.tok.Kind == tokEOF {
+		return p.errorf("unexpected end of query")
+	}
+	return p.errorf("unexpected %q", p.tok.Value)
+}
+
+// peek reports whether the current token is the punctuator s.
+func (p *gqlParser) peek(s string) bool {
+	return p.tok.Kind == tokPunct && p.tok.Value == s
+}
+
+// expect consumes the punctuator s.
+func (p *gqlParser) expect(s string) error {
+	if !p.peek(s) {
+		if p.tok.Kind == tokEOF {
+			return p.errorf("expected %q, found end of query", s)
+		}
+		return p.errorf("expected %q, found %q", s, p.tok.Value)
+	}
+	return p.advance()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.Kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.Value
+	return name, p.advance()
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{Kind: p.tok.Value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind == tokName {
+		op.Name = p.tok.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		vars, err := p.variableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.Variables = vars
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = sel
+	return op, nil
+}
+
+func (p *gqlParser) variableDefinitions() ([]gqlVariableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []gqlVariableDef
+	for !p.peek(")") {
+		def := gqlVariableDef{Pos: p.tok.Pos}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		def.Name = name
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if def.Type, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.Default, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+func (p *gqlParser) typeRef() (gqlTypeRef, error) {
+	var t gqlTypeRef
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return t, err
+		}
+		elem, err := p.typeRef()
+		if err != nil {
+			return t, err
+		}
+		if err := p.expect("]"); err != nil {
+			return t, err
+		}
+		t.Elem = &elem
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return t, err
+		}
+		t.Name = name
+	}
+	if p.peek("!") {
+		t.NonNull = true
+		return t, p.advance()
+	}
+	return t, nil
+}
+
+func (p *gqlParser) fragment() (*gqlFragment, error) {
+	if err := p.advance(); err != nil { // "fragment"
+		return nil, err
+	}
+	if p.tok.Kind == tokName && p.tok.Value == "on" {
+		return nil, p.errorf("a fragment cannot be named \"on\"")
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.Kind != tokName || p.tok.Value != "on" {
+		return nil, p.errorf("expected \"on\" after the fragment name")
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &gqlFragment{Name: name, TypeCondition: typeCondition, Selections: sel}, nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []gqlSelection
+	for !p.peek("}") {
+		if p.tok.Kind == tokEOF {
+			return nil, p.unexpected()
+		}
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf("a selection set cannot be empty")
+	}
+	return selections, p.advance()
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	sel := gqlSelection{Pos: p.tok.Pos}
+	var err error
+
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		if p.tok.Kind == tokName && p.tok.Value != "on" {
+			sel.Spread = p.tok.Value
+			if err := p.advance(); err != nil {
+				return sel, err
+			}
+			sel.Directives, err = p.directives()
+			return sel, err
+		}
+		sel.Inline = true
+		if p.tok.Kind == tokName && p.tok.Value == "on" {
+			if err := p.advance(); err != nil {
+				return sel, err
+			}
+			if sel.TypeCondition, err = p.name(); err != nil {
+				return sel, err
+			}
+		}
+		if sel.Directives, err = p.directives(); err != nil {
+			return sel, err
+		}
+		sel.Selections, err = p.selectionSet()
+		return sel, err
+	}
+
+	if sel.Name, err = p.name(); err != nil {
+		return sel, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		sel.Alias = sel.Name
+		if sel.Name, err = p.name(); err != nil {
+			return sel, err
+		}
+	}
+	if p.peek("(") {
+		if sel.Args, err = p.arguments(false); err != nil {
+			return sel, err
+		}
+	}
+	if sel.Directives, err = p.directives(); err != nil {
+		return sel, err
+	}
+	if p.peek("{") {
+		sel.Selections, err = p.selectionSet()
+	}
+	return sel, err
+}
+
+func (p *gqlParser) arguments(constant bool) ([]gqlArgument, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []gqlArgument
+	seen := map[string]bool{}
+	for !p.peek(")") {
+		arg := gqlArgument{Pos: p.tok.Pos}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, &gqlSyntaxError{Pos: arg.Pos, Message: fmt.Sprintf("there can be only one argument named %q", name)}
+		}
+		seen[name] = true
+		arg.Name = name
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arg.Value, err = p.value(constant); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return nil, p.errorf("an argument list cannot be empty")
+	}
+	return args, p.advance()
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var dirs []gqlDirective
+	for p.peek("@") {
+		d := gqlDirective{Pos: p.tok.Pos}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d.Name = name
+		if p.peek("(") {
+			if d.Args, err = p.arguments(false); err != nil {
+				return nil, err
+			}
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value parses a value; constant values cannot contain variables.
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.Kind {
+	case tokInt:
+		n, err := strconv.Atoi(tok.Value)
+		if err != nil {
+			return nil, p.errorf("integer %s is out of range", tok.Value)
+		}
+		return n, p.advance()
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.Value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", tok.Value)
+		}
+		return f, p.advance()
+	case tokString:
+		return tok.Value, p.advance()
+	case tokName:
+		var v interface{}
+		switch tok.Value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = gqlEnum(tok.Value)
+		}
+		return v, p.advance()
+	}
+
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return gqlVariable(name), err
+	case p.peek("["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+	case p.peek("{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.advance()
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		# a comment
+		query Artist($id: Int!, $upcoming: Boolean = false) {
+			band: artist(id: $id) {
+				name
+				... on Artist { creationDate }
+				...Tour @include(if: $upcoming)
+			}
+		}
+		fragment Tour on Artist { concerts(from: "2020-01-01", first: 2) { date } }
+	`)
+	if err != nil {
+		t.Fatalf("parseGraphQL() returned an error: %v", err)
+	}
+	op := doc.Operations[0]
+	if op.Kind != "query" || op.Name != "Artist" || len(op.Variables) != 2 {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	if v := op.Variables[0]; v.Name != "id" || v.Type.String() != "Int!" {
+		t.Errorf("unexpected variable %+v", v)
+	}
+	if v := op.Variables[1]; v.Default != false {
+		t.Errorf("expected default false, got %#v", v.Default)
+	}
+	band := op.Selections[0]
+	if band.Alias != "band" || band.Name != "artist" || band.Args[0].Value != gqlVariable("id") {
+		t.Errorf("unexpected field %+v", band)
+	}
+	if !band.Selections[1].Inline || band.Selections[1].TypeCondition != "Artist" {
+		t.Errorf("expected an inline fragment, got %+v", band.Selections[1])
+	}
+	if spread := band.Selections[2]; spread.Spread != "Tour" || spread.Directives[0].Name != "include" {
+		t.Errorf("expected a fragment spread with @include, got %+v", spread)
+	}
+	if f := doc.Fragments["Tour"]; f == nil || f.Selections[0].Args[1].Value != 2 {
+		t.Errorf("unexpected fragment %+v", f)
+	}
+
+	errs := []struct {
+		query string
+		want  string
+	}{
+		{`{ artists { name }`, "unexpected end of query"},
+		{`{ artists(first: 01) { name } }`, "unexpected digit after 0"},
+		{`{ artist(name: "open) { name } }`, "unterminated string"},
+		{`{ }`, "selection set cannot be empty"},
+		{`query Q { a } fragment F on Artist { name } fragment F on Artist { id }`, "only one fragment"},
+		{"{ artists { name } }\n?", "line 2, column 1"},
+	}
+	for _, tt := range errs {
+		_, err := parseGraphQL(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseGraphQL(%q) error = %v, want %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+// graphql posts a query and decodes the response.
+func graphql(t *testing.T, query string, variables map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	r := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	Routes().ServeHTTP(w, r)
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestLexLongLine(t *testing.T) {
+	// A 1 MB query on one line; columns used to be recounted from the
+	// start of the line for every token.
+	var q strings.Builder
+	q.WriteString("{ artists { ")
+	for q.Len() < maxGraphQLBody-16 {
+		q.WriteString("name ")
+	}
+	q.WriteString("é }")
+	src := q.String()
+
+	start := time.Now()
+	l := &gqlLexer{src: src, line: 1}
+	var last gqlToken
+	for {
+		tok, err := l.next()
+		if err != nil {
+			if last.Kind != tokName || last.Value != "name" {
+				t.Fatalf("unexpected error before the last name: %v", err)
+			}
+			break
+		}
+		if tok.Kind == tokEOF {
+			t.Fatal("expected an error for the \"é\"")
+		}
+		last = tok
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("lexing %d bytes took %v", len(src), elapsed)
+	}
+	_, err := parseGraphQL(src)
+	syntax, ok := err.(*gqlSyntaxError)
+	if want := len(src) - len("é }") + 1; !ok || syntax.Pos != (gqlPos{Line: 1, Column: want}) {
+		t.Errorf("expected a syntax error at column %d; got %v", want, err)
+	}
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	useTestDataset(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			name:  "filtered artists",
+			query: `{ artists(query: "floyd") { id name members { name } } }`,
+			want:  `{"artists":[{"id":2,"members":[{"name":"Roger Waters"},{"name":"David Gilmour"}],"name":"Pink Floyd"}]}`,
+		},
+		{
+			name:  "nested concerts and locations",
+			query: `{ artist(id: 1) { name concerts(first: 1) { date location { city country artists { name } } } } }`,
+			want:  `{"artist":{"concerts":[{"date":"2019-11-20","location":{"artists":[{"name":"Queen"},{"name":"Pink Floyd"},{"name":"Scorpions"}],"city":"London","country":"UK"}}],"name":"Queen"}}`,
+		},
+		{
+			name:      "variables, aliases and fragments",
+			query:     `query($id: Int!, $more: Boolean!) { a: artist(id: $id) { ...Basics creationDate @skip(if: $more) } b: artist(id: 99) { name } } fragment Basics on Artist { name __typename }`,
+			variables: map[string]interface{}{"id": 3, "more": true},
+			want:      `{"a":{"__typename":"Artist","name":"Scorpions"},"b":null}`,
+		},
+		{
+			name:  "concerts by date and country",
+			query: `{ concerts(country: "japan", from: "2020-01-01") { date artist { name } } }`,
+			want:  `{"concerts":[{"artist":{"name":"Queen"},"date":"2020-01-28"},{"artist":{"name":"Scorpions"},"date":"2020-01-28"}]}`,
+		},
+		{
+			name:  "locations",
+			query: `{ locations(country: "UK") { name concerts(artistId: 2) { date } } }`,
+			want:  `{"locations":[{"concerts":[{"date":"2019-11-22"}],"name":"london-uk"}]}`,
+		},
+		{
+			name:  "sorted and paginated artists",
+			query: `{ artists(sort: "-created", first: 1, offset: 1) { name } }`,
+			want:  `{"artists":[{"name":"Pink Floyd"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := graphql(t, tt.query, tt.variables)
+			if status != http.StatusOK || resp["errors"] != nil {
+				t.Fatalf("expected status 200 without errors; got %d %v", status, resp["errors"])
+			}
+			got, _ := json.Marshal(resp["data"])
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLKeepsFieldOrder(t *testing.T) {
+	useTestDataset(t)
+	w := httptest.NewRecorder()
+	q := url.Values{"query": {`{ artist(id: 1) { name id } }`}}
+	Routes().ServeHTTP(w, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Index(body, `"name"`) > strings.Index(body, `"id"`) {
+		t.Errorf("expected fields in query order; got %d %s", w.Code, body)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	useTestDataset(t)
+
+	deep := `{ artists { concerts { location { artists { concerts { artist { name } } } } } } }`
+	var aliases strings.Builder
+	aliases.WriteString("{ artists { ")
+	for i := 0; i <= maxGraphQLFields; i++ {
+		fmt.Fprintf(&aliases, "n%d: name ", i)
+	}
+	aliases.WriteString("} }")
+	tests := []struct {
+		name       string
+		query      string
+		variables  map[string]interface{}
+		wantStatus int
+		want       string
+	}{
+		{"syntax", `{ artists { name }`, nil, http.StatusBadRequest, "Syntax Error"},
+		{"unknown field", `{ artists { nickname } }`, nil, http.StatusBadRequest, `Cannot query field \"nickname\" on type \"Artist\"`},
+		{"missing subfields", `{ artists }`, nil, http.StatusBadRequest, "must have a selection of subfields"},
+		{"scalar subfields", `{ artists { name { x } } }`, nil, http.StatusBadRequest, "has no subfields"},
+		{"missing argument", `{ artist { name } }`, nil, http.StatusBadRequest, `Argument \"id\" of type Int! is required`},
+		{"wrong argument type", `{ artist(id: "1") { name } }`, nil, http.StatusBadRequest, "Int cannot represent"},
+		{"unknown argument", `{ artists(genre: "rock") { name } }`, nil, http.StatusBadRequest, `Unknown argument \"genre\"`},
+		{"undefined variable", `{ artist(id: $id) { name } }`, nil, http.StatusBadRequest, `Variable \"$id\" is not defined`},
+		{"missing variable", `query($id: Int!) { artist(id: $id) { name } }`, nil, http.StatusBadRequest, "was not provided"},
+		{"invalid variable", `query($id: Int!) { artist(id: $id) { name } }`, map[string]interface{}{"id": 1.5}, http.StatusBadRequest, "Int cannot represent 1.5"},
+		{"mutation", `mutation { artists { name } }`, nil, http.StatusBadRequest, "Only queries are supported"},
+		{"fragment cycle", `{ artists { ...A } } fragment A on Artist { members { artist { ...A } } }`, nil, http.StatusBadRequest, "within itself"},
+		{"too deep", deep, nil, http.StatusBadRequest, "nested too deeply"},
+		{"too many aliases", aliases.String(), nil, http.StatusBadRequest, "too many fields"},
+		{"field error", `{ concerts(from: "yesterday") { date } }`, nil, http.StatusOK, `"path":["concerts"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := graphql(t, tt.query, tt.variables)
+			if status != tt.wantStatus {
+				t.Errorf("expected status %d; got %d", tt.wantStatus, status)
+			}
+			errs, _ := json.Marshal(resp["errors"])
+			if !strings.Contains(string(errs), tt.want) {
+				t.Errorf("expected an error containing %s; got %s", tt.want, errs)
+			}
+			if _, hasData := resp["data"]; hasData != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("unexpected data entry in %v", resp)
+			}
+		})
+	}
+}
+
+func TestGraphQLFieldBudget(t *testing.T) {
+	useTestDataset(t)
+
+	// Every fragment spreads the previous one twice: 2^30 aliased fields
+	// from well under 2 KB of query.
+	var q strings.Builder
+	q.WriteString("{ artists { ...F30 } } fragment F0 on Artist { a: name b: id }")
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&q, " fragment F%d on Artist { ...F%d ...F%d }", i, i-1, i-1)
+	}
+	if q.Len() > 2048 {
+		t.Fatalf("the query is %d bytes", q.Len())
+	}
+
+	start := time.Now()
+	status, resp := graphql(t, q.String(), nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the query to be rejected quickly; took %v", elapsed)
+	}
+	errs, _ := json.Marshal(resp["errors"])
+	if status != http.StatusBadRequest || !strings.Contains(string(errs), "too many fields") {
+		t.Errorf("expected 400 for too many fields; got %d %s", status, errs)
+	}
+	if _, hasData := resp["data"]; hasData {
+		t.Error("expected the query not to run")
+	}
+}
+
+func TestGraphQLCostBudget(t *testing.T) {
+	useTestDataset(t)
+
+	// 52 artists touring the same 10 cities twice: 1040 concerts.
+	var artists []Artist
+	var relations []Relation
+	for id := 1; id <= 52; id++ {
+		artists = append(artists, Artist{ID: id, Name: fmt.Sprintf("Band %d", id), Members: []string{"Someone"}})
+		locations := map[string][]string{}
+		for city := 0; city < 10; city++ {
+			locations[fmt.Sprintf("city_%d-uk", city)] = []string{"01-06-2020", "01-07-2021"}
+		}
+		relations = append(relations, Relation{ID: int64(id), Locations: locations})
+	}
+	setDataset(NewDataset(artists, relations, time.Now()))
+
+	nested := `{ concerts { artist { concerts { location { concerts { date } } } } } }`
+	var aliases strings.Builder
+	aliases.WriteString("{ ")
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&aliases, "a%d: artists { name } ", i)
+	}
+	aliases.WriteString("}")
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		wantOK    bool
+	}{
+		{"nested lists", nested, nil, false},
+		{"aliased lists", strings.Replace(aliases.String(), "{ name }", "{ concerts { date } }", -1), nil, false},
+		{"paginated", `{ concerts(first: 5) { artist { concerts(first: 5) { location { concerts(first: 5) { date } } } } } }`, nil, true},
+		{"paginated with a variable", `query($n: Int) { artists(first: $n) { concerts { date } } }`, map[string]interface{}{"n": 3}, true},
+		{"variable over the budget", `query($n: Int) { artists(first: $n) { concerts { location { artists { name } } } } }`, map[string]interface{}{"n": 52}, false},
+		{"lists of scalars", aliases.String(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			status, resp := graphql(t, tt.query, tt.variables)
+			errs, _ := json.Marshal(resp["errors"])
+			if tt.wantOK {
+				if status != http.StatusOK || resp["errors"] != nil {
+					t.Errorf("expected the query to run; got %d %s", status, errs)
+				}
+				return
+			}
+			if status != http.StatusBadRequest || !strings.Contains(string(errs), "too expensive") {
+				t.Errorf("expected 400 for an expensive query; got %d %s", status, errs)
+			}
+			if _, hasData := resp["data"]; hasData {
+				t.Error("expected the query not to run")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected the query to be rejected quickly; took %v", elapsed)
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"path/filepath"
-	"strings"
-	"text/template"
 )
 
 var errorTemplate *template.Template
@@ -17,124 +18,193 @@ it creates a simple fallback template to ensure error rendering.
 This function should be called once at the start of the application.
 */
 func Init() {
-	var err error
-	errorTemplate, err = template.ParseFiles("template/error.html")
+	errorTemplate = parseErrorTemplate("template/error.html")
+}
+
+/*
+parseErrorTemplate parses the error page at path, or returns the fallback
+page if it cannot. Both are html/template templates: error messages may
+quote user input, so they must be escaped.
+*/
+func parseErrorTemplate(path string) *template.Template {
+	t, err := template.New(filepath.Base(path)).Funcs(template.FuncMap{"asset": asset}).ParseFiles(path)
 	if err != nil {
 		// log.Printf("Warning: Error parsing error template: %v", err)
 		// Create a simple fallback template
-		errorTemplate = template.Must(template.New("error").Parse(`
+		t = template.Must(template.New("error").Parse(`
             <html><body>
             <h1>Error {{.Code}}</h1>
             <p>{{.Message}}</p>
+            {{if .RequestID}}<p>Request ID: {{.RequestID}}</p>{{end}}
             </body></html>
         `))
 		//  log.Println("Error parsing, using fallback template")
 	}
+	return t
+}
+
+// errorPage is the data passed to the error template.
+type errorPage struct {
+	Code      int
+	Message   string
+	RequestID string
+}
+
+// problem is an RFC 7807 problem details document.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
 }
 
 /*
-renderError handles the rendering of error pages.
-It sets the HTTP status code, executes the error template with the provided status and message,
-and logs any errors that occur during template execution.
+renderError handles the rendering of error responses.
+It negotiates on the Accept header: browsers get the HTML error page,
+API clients get an RFC 7807 application/problem+json document and
+everything else (curl, for example) gets plain text.
+Each variant includes the status, the message and the request ID.
 Parameters:
   - w: http.ResponseWriter to write the response
+  - r: *http.Request being answered
   - status: HTTP status code for the error
   - message: Error message to display
 */
-func renderError(w http.ResponseWriter, status int, message string) {
-	Init()
-	w.WriteHeader(status)
-	err := errorTemplate.Execute(w, struct {
-		Code    int
-		Message string
-	}{
-		Code:    status,
-		Message: message,
-	})
-	if err != nil {
-		log.Printf("Error rendering error template: %v", err)
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	id := requestID(r)
+	offers := []string{mediaHTML, mediaProblem, mediaJSON, mediaPlain}
+
+	// The validators set for the page do not describe the error.
+	w.Header().Del("ETag")
+	w.Header().Del("Last-Modified")
+	w.Header().Del("Cache-Control")
+
+	switch negotiate(r, offers, mediaPlain) {
+	case mediaHTML:
+		Init()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		err := errorTemplate.Execute(w, errorPage{Code: status, Message: message, RequestID: id})
+		if err != nil {
+			log.Printf("Error rendering error template: %v", err)
+		}
+	case mediaProblem, mediaJSON:
+		w.Header().Set("Content-Type", mediaProblem)
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    message,
+			Instance:  r.URL.Path,
+			RequestID: id,
+		})
+		if err != nil {
+			log.Printf("Error writing problem response: %v", err)
+		}
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "Error %d %s: %s\n", status, http.StatusText(status), message)
+		if id != "" {
+			fmt.Fprintf(w, "Request ID: %s\n", id)
+		}
 	}
 }
 
 /*
 HomeHandler manages requests to the home page of the application.
-It checks if the requested path is the root ("/") and if the HTTP method is GET.
-If these conditions are not met, it renders appropriate error pages.
-Otherwise, it parses and executes the home.html template.
+The router only sends GET requests for the root ("/") here.
+It parses and executes the home.html template.
 
 Parameters:
   - w: http.ResponseWriter to write the response
   - r: *http.Request containing the request details
 */
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		renderError(w, http.StatusMethodNotAllowed, "Wrong method")
-		return
-	}
-
 	// Parse the homepage template
-	temp, err := template.ParseFiles("template/home.html") // Ensure you have home.html in the template directory
+	temp, err := parseTemplate("template/home.html") // Ensure you have home.html in the template directory
 	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error loading template")
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
 		return
 	}
 
 	// Execute the template and write to the response
 	err = temp.Execute(w, nil) // No data is passed to the homepage template
 	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error executing template")
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
 	}
 }
 
+// ArtistsPage is the data passed to the artists template.
+type ArtistsPage struct {
+	Artists   []Artist
+	Filter    ArtistFilter
+	Query     template.URL // the filter as an encoded query string, for export links
+	Favorites map[int]bool // artists starred by the user or in the visitor's session
+	User      string       // signed-in username, "" for anonymous visitors
+	CSRF      string
+	Return    string // this page's URL, for the favorite toggles to come back to
+}
+
 /*
 ArtistsHandler manages requests to the artists listing page.
-It verifies the correct URL path and HTTP method, then fetches and displays
-the list of artists. If any errors occur during this process, it renders
-appropriate error pages.
+It fetches the list of artists, narrows and orders it with the ArtistFilter
+query parameters and displays it with a favorite toggle on every artist.
+If any errors occur during this process, it renders appropriate error pages.
 
 Parameters:
   - w: http.ResponseWriter to write the response
   - r: *http.Request containing the request details
 */
 func ArtistsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/artists/" {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
+	filter, err := ParseArtistFilter(r.URL.Query())
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if r.Method != http.MethodGet {
-		renderError(w, http.StatusMethodNotAllowed, "Wrong method")
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
 		return
 	}
-
-	templatePath := filepath.Join("template", "artists.html")
-	temp1, err := template.ParseFiles(templatePath)
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error loading template")
+	if pageNotModified(w, r, datasetValidator(data), "artists") {
 		return
 	}
 
-	result, err := ReadArtists("https://groupietrackers.herokuapp.com/api/artists")
+	templatePath := filepath.Join("template", "artists.html")
+	temp1, err := parseTemplate(templatePath)
 	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error fetching artists")
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
 		return
 	}
 
-	err = temp1.Execute(w, result)
+	session := ensureSession(w, r)
+	err = temp1.Execute(w, ArtistsPage{
+		Artists:   filter.Apply(data),
+		Filter:    filter,
+		Query:     template.URL(filter.Values().Encode()),
+		Favorites: favoriteSet(favoritesOwner(r)),
+		User:      currentUser(r),
+		CSRF:      csrfToken(session),
+		Return:    r.URL.RequestURI(),
+	})
 	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error executing template")
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
 	}
 }
 
 /*
 ArtistHandler manages requests for individual artist pages.
-It checks for the correct HTTP method and URL format, extracts the artist ID
-from the URL, fetches the artist data, and renders it using the artist template.
+It takes the artist ID validated by the router, looks the artist and its
+concert history up in the dataset and renders them using the artist
+template. Locations, dates and relations are all shown
+inline on this page, along with the tour travel analytics and a favorite
+toggle.
+With ?format=json or a JSON Accept header the same data is returned as JSON.
 If any errors occur during this process, it renders appropriate error pages.
 
 Parameters:
@@ -142,193 +212,92 @@ Parameters:
   - r: *http.Request containing the request details
 */
 func ArtistHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		renderError(w, http.StatusMethodNotAllowed, "Wrong method")
+	id := PathInt(r, "id")
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artist")
 		return
 	}
-	if !strings.HasPrefix(r.URL.Path, "/artist/") || len(strings.Split(r.URL.Path, "/")) != 3 {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
+	result, err := readArtistDetail(data, id)
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that page")
 		return
 	}
 
-	id1 := strings.Split(r.URL.Path, "/")
-	if len(id1) < 3 {
-		renderError(w, http.StatusBadRequest, "Artist ID not found")
-		return
+	check := pageNotModified // the page shows the favorite toggle
+	if wantsJSON(r) {
+		check = dataNotModified
 	}
-	id := id1[len(id1)-1]
-
-	temp1, err := template.ParseFiles("template/artist.html")
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error loading template")
+	if check(w, r, datasetValidator(data), artistKey(id)) {
 		return
 	}
-	baseURL := "https://groupietrackers.herokuapp.com/api/artists/"
 
-	result, err := ReadArtist(baseURL, id)
-	if err != nil {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, result)
 		return
 	}
 
-	if result.ID == 0 {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
+	temp1, err := parseTemplate("template/artist.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
 		return
 	}
 
-	err = temp1.Execute(w, result)
+	session := ensureSession(w, r)
+	err = temp1.Execute(w, ArtistPage{
+		ArtistDetail: result,
+		Favorite:     favoriteSet(favoritesOwner(r))[result.Artist.ID],
+		CSRF:         csrfToken(session),
+	})
 	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error executing template")
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
 	}
 }
 
+// ArtistPage is the data passed to the artist template.
+type ArtistPage struct {
+	ArtistDetail
+	Favorite bool
+	CSRF     string
+}
+
+// redirectToArtist sends a legacy concert page to the artist page.
+func redirectToArtist(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/artist/"+PathParam(r, "id")+"#concerts", http.StatusMovedPermanently)
+}
+
 /*
-LocationHandler manages requests for location information of artists.
-It verifies the HTTP method, extracts the location ID from the URL,
-fetches the location data, and renders it using the locations template.
-If any errors occur during this process, it renders appropriate error pages.
+LocationHandler keeps old /locations/{id} links working by redirecting
+them to the concerts section of the artist page.
 
 Parameters:
   - w: http.ResponseWriter to write the response
   - r: *http.Request containing the request details
 */
 func LocationHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		renderError(w, http.StatusMethodNotAllowed, "Wrong method")
-		return
-	}
-
-	id1 := strings.Split(r.URL.Path, "/")
-	if len(id1) < 3 {
-		renderError(w, http.StatusBadRequest, "Artist ID not found")
-		return
-	}
-	id := id1[len(id1)-1]
-
-	temp1, err := template.ParseFiles("template/locations.html")
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error loading template")
-		return
-	}
-	url := "https://groupietrackers.herokuapp.com/api/locations/"
-
-	Result, err := ReadLocation(url, id)
-	if err != nil {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	if Result.ID == 0 {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	err = temp1.Execute(w, Result)
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error executing template")
-	}
+	redirectToArtist(w, r)
 }
 
 /*
-DateHandler manages requests for concert date information of artists.
-It verifies the HTTP method, extracts the artist ID from the URL,
-fetches the date data, and renders it using the dates template.
-If any errors occur during this process, it renders appropriate error pages.
+DateHandler keeps old /dates/{id} links working by redirecting
+them to the concerts section of the artist page.
 
 Parameters:
   - w: http.ResponseWriter to write the response
   - r: *http.Request containing the request details
 */
 func DateHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		renderError(w, http.StatusMethodNotAllowed, "Wrong method")
-		return
-	}
-
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) != 3 || parts[1] != "dates" {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	id := parts[2]
-	if id == "" {
-		renderError(w, http.StatusBadRequest, "Artist ID not found")
-		return
-	}
-
-	temp1, err := template.ParseFiles("template/dates.html")
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error loading template")
-		return
-	}
-
-	baseURL := "https://groupietrackers.herokuapp.com/api/dates/"
-	Result, err := ReadDate(baseURL, id)
-	if err != nil {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	if Result.ID == 0 {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	err = temp1.Execute(w, Result)
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error executing template")
-	}
+	redirectToArtist(w, r)
 }
 
 /*
-RelationHandler manages requests for relation information of artists.
-It verifies the HTTP method, extracts the relation ID from the URL,
-fetches the relation data, and renders it using the relation template.
-If any errors occur during this process, it renders appropriate error pages.
+RelationHandler keeps old /relation/{id} links working by redirecting
+them to the concerts section of the artist page.
 
 Parameters:
   - w: http.ResponseWriter to write the response
   - r: *http.Request containing the request details
 */
 func RelationHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		renderError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-	if !strings.HasPrefix(r.URL.Path, "/relation/") || len(strings.Split(r.URL.Path, "/")) != 3 {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	id1 := strings.Split(r.URL.Path, "/")
-	if len(id1) < 3 {
-		renderError(w, http.StatusBadRequest, "ID not found")
-		return
-	}
-	id := id1[len(id1)-1]
-
-	relationTemplate, err := template.ParseFiles("template/relation.html")
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Error loading template: "+err.Error())
-		return
-	}
-	baseURL := "https://groupietrackers.herokuapp.com/api/relation/"
-
-	relations, err := ReadRelations(baseURL, id)
-	if err != nil {
-
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	if relations.ID == 0 {
-		renderError(w, http.StatusNotFound, "Oops! We Can't find that page")
-		return
-	}
-
-	err = relationTemplate.Execute(w, relations)
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Failed to render template: "+err.Error())
-	}
+	redirectToArtist(w, r)
 }
@@ -1,12 +1,10 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
-	"text/template"
 )
 
 func TestRenderError(t *testing.T) {
@@ -44,7 +42,9 @@ func TestRenderError(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			renderError(w, tt.status, tt.message)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", "text/html")
+			renderError(w, r, tt.status, tt.message)
 
 			if w.Code != tt.expectedStatus {
 				t.Errorf("expected status %d; got %d", tt.expectedStatus, w.Code)
@@ -61,6 +61,25 @@ func TestRenderError(t *testing.T) {
 	}
 }
 
+func TestErrorPageEscapesMessage(t *testing.T) {
+	originalTemplate := errorTemplate
+	defer func() { errorTemplate = originalTemplate }()
+
+	message := `Invalid sort <script>alert(1)</script>`
+	for _, path := range []string{"../template/error.html", "missing.html"} {
+		errorTemplate = parseErrorTemplate(path)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", "text/html")
+		renderError(w, r, http.StatusBadRequest, message)
+
+		body := w.Body.String()
+		if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+			t.Errorf("%s: expected the message to be escaped; got %s", path, body)
+		}
+	}
+}
+
 func TestInit(t *testing.T) {
 	// Temporarily replace the global errorTemplate
 	originalTemplate := errorTemplate
@@ -91,10 +110,7 @@ func TestInit(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			err := errorTemplate.Execute(w, struct {
-				Code    int
-				Message string
-			}{
+			err := errorTemplate.Execute(w, errorPage{
 				Code:    tc.code,
 				Message: tc.message,
 			})
@@ -120,198 +136,9 @@ func TestInit(t *testing.T) {
 	}
 }
 
-// type Artist struct {
-// 	ID   int    `json:"id"`
-// 	Name string `json:"name"`
-// }
-
-var ReadArtistFunc = ReadArtist // Function variable for testing
-
-func MockReadArtist(baseURL, id string) (Artist, error) {
-	if id == "1" {
-		return Artist{ID: 1, Name: "Test Artist"}, nil
-	}
-	return Artist{}, fmt.Errorf("Artist ID not found")
-}
-
 func TestArtistHandler(t *testing.T) {
-	mockTemplate, _ := template.New("test").Parse("{{.Name}}")
-
-	tests := []struct {
-		method       string
-		url          string
-		expectedCode int
-		expectedBody string
-	}{
-		{"GET", "/artist/1", http.StatusOK, "Test Artist"},
-		{"GET", "/artist/2", http.StatusNotFound, "Artist not found"},
-		{"POST", "/artist/1", http.StatusMethodNotAllowed, "Wrong method"},
-		{"GET", "/wrongpath", http.StatusNotFound, "Page Not Found"},
-		{"GET", "/artist/", http.StatusBadRequest, "Artist ID not found"},
-	}
-
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Wrong method", http.StatusMethodNotAllowed)
-			return
-		}
-
-		parts := strings.Split(r.URL.Path, "/")
-		if len(parts) != 3 || parts[1] != "artist" {
-			http.Error(w, "Page Not Found", http.StatusNotFound)
-			return
-		}
-
-		id := parts[2]
-		if id == "" {
-			http.Error(w, "Artist ID not found", http.StatusBadRequest)
-			return
-		}
-
-		result, err := ReadArtistFunc("https://groupietrackers.herokuapp.com/api/artists/", id)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				http.Error(w, "Artist not found", http.StatusNotFound)
-			} else {
-				http.Error(w, "Error fetching artist: "+err.Error(), http.StatusInternalServerError)
-			}
-			return
-		}
-
-		if result.ID == 0 {
-			http.Error(w, "Artist not found", http.StatusNotFound)
-			return
-		}
-
-		if err := mockTemplate.Execute(w, result); err != nil {
-			http.Error(w, "Error executing template", http.StatusInternalServerError)
-		}
-	}
-
-	ReadArtistFunc = MockReadArtist
-	defer func() { ReadArtistFunc = ReadArtist }() // Restore the original function
-
-	for _, test := range tests {
-		req := httptest.NewRequest(test.method, test.url, nil)
-		w := httptest.NewRecorder()
-
-		handler(w, req)
-
-		res := w.Result()
-		if res.StatusCode != test.expectedCode {
-			t.Errorf("expected status code %d, got %d", test.expectedCode, res.StatusCode)
-		}
-
-		body := w.Body.String()
-		if !strings.Contains(body, test.expectedBody) {
-			t.Errorf("expected body to contain %q, got %q", test.expectedBody, body)
-		}
-	}
-}
-
-var ReadLocationFunc = ReadLocation // Function variable for testing
-
-func MockReadLocation(baseURL, id string) (Location, error) {
-	if id == "1" {
-		return Location{ID: 1, Locations: []string{"Location A", "Location B"}}, nil
-	} else if id == "3" {
-		return Location{ID: 3, Locations: []string{"Location C"}}, nil
-	}
-	return Location{}, fmt.Errorf("Location not found")
-}
-
-func TestLocationHandler(t *testing.T) {
-	mockTemplate, _ := template.New("test").Parse("{{range .}}{{.}} {{end}}") // Output locations
-
-	tests := []struct {
-		method       string
-		url          string
-		expectedCode int
-		expectedBody string
-	}{
-		{"GET", "/location/1", http.StatusOK, "Location A Location B "},
-		{"GET", "/location/3", http.StatusOK, "Location C "},
-		{"GET", "/location/2", http.StatusNotFound, "Location not found"},
-		{"POST", "/location/1", http.StatusMethodNotAllowed, "Wrong method"},
-		{"GET", "/wrongpath", http.StatusNotFound, "Page Not Found"},
-		{"GET", "/location/", http.StatusBadRequest, "Location ID not found"},
-		{"GET", "/location/invalid", http.StatusNotFound, "Location not found"},
-		{"GET", "/location/0", http.StatusNotFound, "Location not found"},
-		{"GET", "/location/999", http.StatusNotFound, "Location not found"},
-	}
-
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Wrong method", http.StatusMethodNotAllowed)
-			return
-		}
-
-		parts := strings.Split(r.URL.Path, "/")
-		if len(parts) != 3 || parts[1] != "location" {
-			http.Error(w, "Page Not Found", http.StatusNotFound)
-			return
-		}
-
-		id := parts[2]
-		if id == "" {
-			http.Error(w, "Location ID not found", http.StatusBadRequest)
-			return
-		}
-
-		result, err := ReadLocationFunc("https://groupietrackers.herokuapp.com/api/locations/", id)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				http.Error(w, "Location not found", http.StatusNotFound)
-			} else {
-				http.Error(w, "Error fetching location: "+err.Error(), http.StatusInternalServerError)
-			}
-			return
-		}
-
-		if result.ID == 0 {
-			http.Error(w, "Location not found", http.StatusNotFound)
-			return
-		}
-
-		if err := mockTemplate.Execute(w, result.Locations); err != nil {
-			http.Error(w, "Error executing template", http.StatusInternalServerError)
-		}
-	}
-
-	ReadLocationFunc = MockReadLocation
-	defer func() { ReadLocationFunc = ReadLocation }() // Restore the original function
-
-	for _, test := range tests {
-		req := httptest.NewRequest(test.method, test.url, nil)
-		w := httptest.NewRecorder()
-
-		handler(w, req)
-
-		res := w.Result()
-		if res.StatusCode != test.expectedCode {
-			t.Errorf("expected status code %d, got %d", test.expectedCode, res.StatusCode)
-		}
-
-		body := w.Body.String()
-		if !strings.Contains(body, test.expectedBody) {
-			t.Errorf("expected body to contain %q, got %q", test.expectedBody, body)
-		}
-	}
-}
-
-var ReadDateFunc = ReadDate // Function variable for testing
-
-func MockReadDate(baseURL, id string) (DateEntry, error) {
-	if id == "1" {
-		return DateEntry{ID: 1, Dates: []string{"2023-01-01", "2023-02-01"}}, nil
-	} else if id == "3" {
-		return DateEntry{ID: 3, Dates: []string{"2023-03-01"}}, nil
-	}
-	return DateEntry{}, fmt.Errorf("Date not found")
-}
-
-func TestDateHandler(t *testing.T) {
-	mockTemplate, _ := template.New("test").Parse("{{range .}}{{.}} {{end}}") // Output dates
+	useProvider(t, &MemoryProvider{data: useTestDataset(t)})
+	handler := Routes()
 
 	tests := []struct {
 		method       string
@@ -319,170 +146,20 @@ func TestDateHandler(t *testing.T) {
 		expectedCode int
 		expectedBody string
 	}{
-		{"GET", "/dates/1", http.StatusOK, "2023-01-01 2023-02-01 "},
-		{"GET", "/dates/3", http.StatusOK, "2023-03-01 "},
-		{"GET", "/dates/2", http.StatusNotFound, "Date entry not found"},
-		{"POST", "/dates/1", http.StatusMethodNotAllowed, "Wrong method"},
-		{"GET", "/wrongpath", http.StatusNotFound, "Page Not Found"},
-		{"GET", "/dates/", http.StatusBadRequest, "Artist ID not found"},
-		{"GET", "/dates/invalid", http.StatusNotFound, "Date entry not found"},
-		{"GET", "/dates/0", http.StatusNotFound, "Date entry not found"},
-		{"GET", "/dates/999", http.StatusNotFound, "Date entry not found"},
-	}
-
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Wrong method", http.StatusMethodNotAllowed)
-			return
-		}
-
-		parts := strings.Split(r.URL.Path, "/")
-		if len(parts) != 3 || parts[1] != "dates" {
-			http.Error(w, "Page Not Found", http.StatusNotFound)
-			return
-		}
-
-		id := parts[2]
-		if id == "" {
-			http.Error(w, "Artist ID not found", http.StatusBadRequest)
-			return
-		}
-
-		result, err := ReadDateFunc("https://groupietrackers.herokuapp.com/api/dates/", id)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				http.Error(w, "Date entry not found", http.StatusNotFound)
-			} else {
-				http.Error(w, "Error fetching date: "+err.Error(), http.StatusInternalServerError)
-			}
-			return
-		}
-
-		if result.ID == 0 {
-			http.Error(w, "Date entry not found", http.StatusNotFound)
-			return
-		}
-
-		if err := mockTemplate.Execute(w, result.Dates); err != nil {
-			http.Error(w, "Error executing template", http.StatusInternalServerError)
-		}
+		{"GET", "/artist/1?format=json", http.StatusOK, `"name": "Queen"`},
+		{"GET", "/artist/42?format=json", http.StatusNotFound, "find that page"},
+		{"GET", "/artist/abc?format=json", http.StatusBadRequest, ""},
+		{"POST", "/artist/1?format=json", http.StatusMethodNotAllowed, ""},
 	}
 
-	ReadDateFunc = MockReadDate
-	defer func() { ReadDateFunc = ReadDate }() // Restore the original function
-
 	for _, test := range tests {
-		req := httptest.NewRequest(test.method, test.url, nil)
 		w := httptest.NewRecorder()
-
-		handler(w, req)
-
-		res := w.Result()
-		if res.StatusCode != test.expectedCode {
-			t.Errorf("expected status code %d, got %d", test.expectedCode, res.StatusCode)
-		}
-
-		body := w.Body.String()
-		if !strings.Contains(body, test.expectedBody) {
-			t.Errorf("expected body to contain %q, got %q", test.expectedBody, body)
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.url, nil))
+		if w.Code != test.expectedCode {
+			t.Errorf("%s %s: expected status code %d, got %d", test.method, test.url, test.expectedCode, w.Code)
 		}
-	}
-}
-
-// Mock function for FetchRelations for testing purposes
-var FetchRelationsFunc = ReadRelations
-
-func MockFetchRelations(baseURL, id string) (Relation, error) {
-	if id == "1" {
-		return Relation{ID: 1, Locations: map[string][]string{
-			"New York":    {"2023-01-01", "2023-02-01"},
-			"Los Angeles": {"2023-03-01"},
-		}}, nil
-	} else if id == "3" {
-		return Relation{ID: 3, Locations: map[string][]string{
-			"London": {"2023-05-01"},
-		}}, nil
-	}
-	return Relation{}, fmt.Errorf("Relation not found")
-}
-
-func TestRelationHandler(t *testing.T) {
-	// Mock template for testing rendering
-	mockTemplate, _ := template.New("test").Parse("{{range $location, $dates := .Locations}}{{$location}}: {{range $dates}}{{.}} {{end}} {{end}}")
-
-	tests := []struct {
-		method       string
-		url          string
-		expectedCode int
-		expectedBody []string // We will check for multiple substrings
-	}{
-		{"GET", "/relation/1", http.StatusOK, []string{"New York: 2023-01-01 2023-02-01", "Los Angeles: 2023-03-01"}},
-		{"GET", "/relation/3", http.StatusOK, []string{"London: 2023-05-01"}},
-		{"GET", "/relation/2", http.StatusNotFound, []string{"Relation not found"}},
-		{"POST", "/relation/1", http.StatusMethodNotAllowed, []string{"Method not allowed"}},
-		{"GET", "/wrongpath", http.StatusNotFound, []string{"Page Not Found"}},
-		{"GET", "/relation/invalid", http.StatusNotFound, []string{"Relation not found"}},
-		{"GET", "/relation/0", http.StatusNotFound, []string{"Relation not found"}},
-		{"GET", "/relation/999", http.StatusNotFound, []string{"Relation not found"}},
-	}
-
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			renderError(w, http.StatusMethodNotAllowed, "Method not allowed")
-			return
-		}
-		if !strings.HasPrefix(r.URL.Path, "/relation/") || len(strings.Split(r.URL.Path, "/")) != 3 {
-			renderError(w, http.StatusNotFound, "Page Not Found")
-			return
-		}
-
-		id1 := strings.Split(r.URL.Path, "/")
-		if len(id1) < 3 {
-			renderError(w, http.StatusBadRequest, "ID not found")
-			return
-		}
-		id := id1[len(id1)-1]
-
-		relations, err := FetchRelationsFunc("https://groupietrackers.herokuapp.com/api/relation/", id)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				renderError(w, http.StatusNotFound, "Relation not found")
-			} else {
-				renderError(w, http.StatusInternalServerError, "Error fetching relations: "+err.Error())
-			}
-			return
-		}
-
-		if relations.ID == 0 {
-			renderError(w, http.StatusNotFound, "Relation not found")
-			return
-		}
-
-		if err := mockTemplate.Execute(w, relations); err != nil {
-			renderError(w, http.StatusInternalServerError, "Failed to render template: "+err.Error())
-		}
-	}
-
-	// Replace FetchRelationsFunc with mock function and restore afterward
-	FetchRelationsFunc = MockFetchRelations
-	defer func() { FetchRelationsFunc = ReadRelations }()
-
-	for _, test := range tests {
-		req := httptest.NewRequest(test.method, test.url, nil)
-		w := httptest.NewRecorder()
-
-		handler(w, req)
-
-		res := w.Result()
-		if res.StatusCode != test.expectedCode {
-			t.Errorf("expected status code %d, got %d", test.expectedCode, res.StatusCode)
-		}
-
-		body := w.Body.String()
-		for _, expected := range test.expectedBody {
-			if !strings.Contains(body, expected) {
-				t.Errorf("expected body to contain %q, got %q", expected, body)
-			}
+		if !strings.Contains(w.Body.String(), test.expectedBody) {
+			t.Errorf("%s %s: expected body to contain %q, got %q", test.method, test.url, test.expectedBody, w.Body.String())
 		}
 	}
 }
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// asOfLayout is the format of the ?asof parameter.
+const asOfLayout = "2006-01-02"
+
+// AsOf is the stored version of the dataset a historical request is
+// served from.
+type AsOf struct {
+	Date     time.Time // the requested day
+	Snapshot StoredVersion
+	Data     *Dataset
+}
+
+type asOfKey struct{}
+
+// requestAsOf returns the snapshot chosen by withAsOf, or nil for requests
+// about the current catalogue.
+func requestAsOf(r *http.Request) *AsOf {
+	a, _ := r.Context().Value(asOfKey{}).(*AsOf)
+	return a
+}
+
+/*
+requestDataset returns the dataset a request is served from: the snapshot
+picked by ?asof, or the current dataset.
+*/
+func requestDataset(r *http.Request) (*Dataset, error) {
+	if a := requestAsOf(r); a != nil {
+		return a.Data, nil
+	}
+	return loadDataset()
+}
+
+// requestProvider is requestDataset for handlers that use a Provider.
+func requestProvider(r *http.Request) Provider {
+	if a := requestAsOf(r); a != nil {
+		return &MemoryProvider{data: a.Data}
+	}
+	return provider
+}
+
+/*
+snapshotAsOf finds the stored version that was current at the end of day.
+It returns ErrNotFound when no version has been stored yet.
+*/
+func snapshotAsOf(day time.Time) (*AsOf, error) {
+	s, err := datasetStore()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := s.AsOf(day.AddDate(0, 0, 1))
+	if !ok {
+		return nil, fmt.Errorf("snapshot as of %s: %w", day.Format(asOfLayout), ErrNotFound)
+	}
+	d, err := s.Version(v.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &AsOf{Date: day, Snapshot: v, Data: d}, nil
+}
+
+/*
+withAsOf serves GET requests with ?asof=YYYY-MM-DD from the stored version
+of the dataset that was current on that day. HTML pages get a banner
+saying so, and their links and redirects keep the parameter so browsing
+stays in the past. Dates from today on serve the current catalogue.
+*/
+func withAsOf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := r.URL.Query().Get("asof")
+		if raw == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		day, err := time.Parse(asOfLayout, raw)
+		if err != nil {
+			renderError(w, r, http.StatusBadRequest, "Invalid asof date, want YYYY-MM-DD")
+			return
+		}
+		now := time.Now().UTC()
+		if !day.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		asOf, err := snapshotAsOf(day)
+		if errors.Is(err, ErrNotFound) {
+			renderError(w, r, http.StatusNotFound, "No snapshot of the catalogue is available yet")
+			return
+		}
+		if err != nil {
+			renderError(w, r, http.StatusInternalServerError, "Error reading the snapshot")
+			return
+		}
+
+		w.Header().Set("X-Groupie-Snapshot", asOf.Snapshot.StoredAt.UTC().Format(time.RFC3339))
+		hw := &historyWriter{ResponseWriter: w, asOf: asOf, raw: raw, current: withoutAsOf(r.URL)}
+		next.ServeHTTP(hw, r.WithContext(context.WithValue(r.Context(), asOfKey{}, asOf)))
+		hw.finish()
+	})
+}
+
+// withoutAsOf returns the local URL of u without the asof parameter.
+func withoutAsOf(u *url.URL) string {
+	q := u.Query()
+	q.Del("asof")
+	current := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return current.String()
+}
+
+/*
+historyWriter buffers HTML responses so the historical banner can be added
+and links rewritten. Other responses pass straight through.
+*/
+type historyWriter struct {
+	http.ResponseWriter
+	asOf    *AsOf
+	raw     string // the asof parameter
+	current string // the same page without asof
+
+	status  int
+	decided bool
+	html    bool
+	buf     bytes.Buffer
+}
+
+func (h *historyWriter) WriteHeader(status int) {
+	if h.status != 0 {
+		return
+	}
+	h.status = status
+	if loc := h.Header().Get("Location"); loc != "" {
+		h.Header().Set("Location", h.link(loc))
+	}
+}
+
+func (h *historyWriter) Write(p []byte) (int, error) {
+	if !h.decided {
+		h.decide(p)
+	}
+	if h.html {
+		return h.buf.Write(p)
+	}
+	return h.ResponseWriter.Write(p)
+}
+
+// decide buffers HTML and sends the header of anything else.
+func (h *historyWriter) decide(p []byte) {
+	if h.status == 0 {
+		h.WriteHeader(http.StatusOK)
+	}
+	h.decided = true
+	ct := h.Header().Get("Content-Type")
+	if ct == "" && len(p) > 0 {
+		ct = http.DetectContentType(p)
+		h.Header().Set("Content-Type", ct)
+	}
+	h.html = strings.HasPrefix(ct, "text/html")
+	if !h.html {
+		h.ResponseWriter.WriteHeader(h.status)
+	}
+}
+
+/*
+Flush passes flushes through, so streamed responses such as /events work
+with asof. HTML pages are buffered until the handler returns, so flushing
+them does nothing.
+*/
+func (h *historyWriter) Flush() {
+	if !h.decided {
+		h.decide(nil)
+	}
+	if h.html {
+		return
+	}
+	if f, ok := h.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish sends a buffered HTML page with the banner and rewritten links.
+func (h *historyWriter) finish() {
+	if !h.decided {
+		if h.status != 0 {
+			h.ResponseWriter.WriteHeader(h.status)
+		}
+		return
+	}
+	if !h.html {
+		return
+	}
+	body := h.rewrite(h.buf.Bytes())
+	h.Header().Del("Content-Length")
+	h.ResponseWriter.WriteHeader(h.status)
+	h.ResponseWriter.Write(body)
+}
+
+// link adds the asof parameter to local links outside /static/.
+func (h *historyWriter) link(href string) string {
+	if !strings.HasPrefix(href, "/") || strings.HasPrefix(href, "//") || strings.HasPrefix(href, "/static/") {
+		return href
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	q := u.Query()
+	q.Set("asof", h.raw)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+var (
+	headEnd  = regexp.MustCompile(`(?i)</head>`)
+	bodyTag  = regexp.MustCompile(`(?i)<body[^>]*>`)
+	hrefAttr = regexp.MustCompile(`href="(/[^"]*)"`)
+	formTag  = regexp.MustCompile(`(?i)<form\b[^>]*>`)
+	postForm = regexp.MustCompile(`(?i)method="post"`)
+)
+
+func (h *historyWriter) rewrite(page []byte) []byte {
+	page = hrefAttr.ReplaceAllFunc(page, func(m []byte) []byte {
+		href := html.UnescapeString(string(hrefAttr.FindSubmatch(m)[1]))
+		return []byte(`href="` + html.EscapeString(h.link(href)) + `"`)
+	})
+	// GET forms replace the query of their action, so they need a field.
+	hidden := []byte(`<input type="hidden" name="asof" value="` + html.EscapeString(h.raw) + `">`)
+	page = formTag.ReplaceAllFunc(page, func(m []byte) []byte {
+		if postForm.Match(m) {
+			return m
+		}
+		return append(append([]byte(nil), m...), hidden...)
+	})
+	if loc := headEnd.FindIndex(page); loc != nil {
+		stylesheet := `<link rel="stylesheet" type="text/css" href="` + html.EscapeString(asset("history.css")) + `" />`
+		page = append(page[:loc[0]:loc[0]], append([]byte(stylesheet), page[loc[0]:]...)...)
+	}
+	if loc := bodyTag.FindIndex(page); loc != nil {
+		page = append(page[:loc[1]:loc[1]], append(h.banner(), page[loc[1]:]...)...)
+	}
+	return page
+}
+
+// banner is the notice shown at the top of historical pages, styled by
+// static/history.css.
+func (h *historyWriter) banner() []byte {
+	snapshot := "the snapshot of " + h.asOf.Snapshot.StoredAt.UTC().Format("02 Jan 2006 15:04 MST")
+	if h.asOf.Snapshot.StoredAt.After(h.asOf.Date.AddDate(0, 0, 1)) {
+		snapshot = "the earliest snapshot, of " + h.asOf.Snapshot.StoredAt.UTC().Format("02 Jan 2006")
+	}
+	return []byte(fmt.Sprintf(`
+    <div class="asof-banner" role="status">
+        Historical view: the catalogue as of %s, from %s.
+        <a href="%s">Back to today</a>
+    </div>`, h.asOf.Date.Format("02 Jan 2006"), snapshot, html.EscapeString(h.current)))
+}
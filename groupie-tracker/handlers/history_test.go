@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useHistory stores two versions of the test catalogue: the original one
+// on 1 January 2020 and one with Queen renamed on 1 June 2020.
+func useHistory(t *testing.T) {
+	t.Helper()
+	useTempDataDir(t)
+	useProvider(t, &MemoryProvider{data: useTestDataset(t)})
+
+	s, err := datasetStore()
+	if err != nil {
+		t.Fatalf("datasetStore() returned an error: %v", err)
+	}
+	first := newTestDataset()
+	first.FetchedAt = time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	s.Put(first)
+
+	artists := append([]Artist(nil), first.Artists...)
+	artists[0].Name = "Queen + Adam Lambert"
+	s.Put(NewDataset(artists, datasetRelations(first), time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestAsOf(t *testing.T) {
+	useHistory(t)
+	handler := Routes()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantName   string
+	}{
+		{"current", "/artist/1?format=json", http.StatusOK, "Queen"},
+		{"first version", "/artist/1?format=json&asof=2020-03-01", http.StatusOK, "Queen"},
+		{"second version", "/artist/1?format=json&asof=2020-07-01", http.StatusOK, "Queen + Adam Lambert"},
+		{"same day", "/artist/1?format=json&asof=2020-06-01", http.StatusOK, "Queen + Adam Lambert"},
+		{"before all snapshots", "/artist/1?format=json&asof=1999-01-01", http.StatusOK, "Queen"},
+		{"invalid date", "/artist/1?format=json&asof=yesterday", http.StatusBadRequest, ""},
+		{"unknown artist", "/artist/4?format=json&asof=2020-03-01", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d; got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantName == "" {
+				return
+			}
+			var got ArtistDetail
+			json.NewDecoder(w.Body).Decode(&got)
+			if got.Artist.Name != tt.wantName {
+				t.Errorf("expected %q; got %q", tt.wantName, got.Artist.Name)
+			}
+		})
+	}
+}
+
+func TestAsOfDatasetRoutes(t *testing.T) {
+	useHistory(t)
+
+	w := httptest.NewRecorder()
+	Routes().ServeHTTP(w, httptest.NewRequest("GET", "/artists/export?format=ndjson&asof=2020-07-01", nil))
+	if !strings.Contains(w.Body.String(), "Queen + Adam Lambert") {
+		t.Errorf("expected the export to use the snapshot; got %s", w.Body.String())
+	}
+	if w.Header().Get("X-Groupie-Snapshot") != "2020-06-01T12:00:00Z" {
+		t.Errorf("unexpected X-Groupie-Snapshot %q", w.Header().Get("X-Groupie-Snapshot"))
+	}
+}
+
+func TestAsOfWithoutSnapshots(t *testing.T) {
+	useTempDataDir(t)
+	useTestDataset(t)
+
+	w := httptest.NewRecorder()
+	Routes().ServeHTTP(w, httptest.NewRequest("GET", "/artist/1?format=json&asof=2020-03-01", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d without snapshots; got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestHistoricalPages(t *testing.T) {
+	useHistory(t)
+	handler := withAsOf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/artist/1#concerts", http.StatusMovedPermanently)
+			return
+		}
+		w.Write([]byte(`<!DOCTYPE html><html><head><link href="/static/artist.css"></head><body>` +
+			`<a href="/artists?q=a&amp;sort=name">All</a> <a href="https://example.com/">Out</a>` +
+			`<form method="get" action="/artists"></form><form method="post" action="/favorites/1"></form></body></html>`))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/artists?asof=2020-03-01&q=a", nil))
+	page := w.Body.String()
+
+	for _, want := range []string{
+		`class="asof-banner"`,
+		`href="/static/history.css"`,
+		"as of 01 Mar 2020, from the snapshot of 01 Jan 2020",
+		`href="/artists?q=a"`, // back to today
+		`href="/artists?asof=2020-03-01&amp;q=a&amp;sort=name"`,
+		`href="https://example.com/"`,
+		`href="/static/artist.css"`,
+		`<form method="get" action="/artists"><input type="hidden" name="asof" value="2020-03-01">`,
+		`<form method="post" action="/favorites/1"></form>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %s in the page; got %s", want, page)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/old?asof=2020-03-01", nil))
+	if got := w.Header().Get("Location"); got != "/artist/1?asof=2020-03-01#concerts" {
+		t.Errorf("expected the redirect to keep asof; got %q", got)
+	}
+
+	// Without asof pages are left alone.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/artists", nil))
+	if strings.Contains(w.Body.String(), "asof") {
+		t.Errorf("expected no historical markup; got %s", w.Body.String())
+	}
+}
+
+func TestHistoricalStreams(t *testing.T) {
+	useHistory(t)
+	handler := withAsOf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected the writer to support flushing")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		f.Flush()
+		w.Write([]byte("data: 1\n\n"))
+		f.Flush()
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/events?asof=2020-03-01", nil))
+	if !w.Flushed || w.Body.String() != "data: 1\n\n" {
+		t.Errorf("expected the stream to be flushed untouched; got %v %q", w.Flushed, w.Body.String())
+	}
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxImageBytes and maxImagePixels reject oversized originals.
+	maxImageBytes  = 10 << 20
+	maxImagePixels = 40e6
+	// imageMaxAge is how long browsers may cache images; placeholders are
+	// only cached briefly so a recovered origin is picked up.
+	imageMaxAge       = 30 * 24 * time.Hour
+	placeholderMaxAge = 5 * time.Minute
+	// imageRetryAfter is how long a failed download is remembered before
+	// the image host is asked again.
+	imageRetryAfter = time.Minute
+)
+
+// imageFetchTimeout bounds a download from the image host, which holds the
+// image's lock: a hung host must not hold every request for the image.
+var imageFetchTimeout = 5 * time.Second
+
+// imageWidths are the widths /img resizes to, narrowest first. Other widths
+// are snapped to one of them, so each image has a bounded number of
+// thumbnails on disk.
+var imageWidths = []int{120, 240, 300, 350, 600, 700, 1200}
+
+// snapImageWidth returns the narrowest of imageWidths that is at least n
+// pixels wide, or the widest when n is wider than all of them.
+func snapImageWidth(n int) int {
+	for _, w := range imageWidths {
+		if w >= n {
+			return w
+		}
+	}
+	return imageWidths[len(imageWidths)-1]
+}
+
+// imageLocks serialises work on one cache entry so concurrent requests for
+// the same image fetch and resize it once.
+var imageLocks = struct {
+	sync.Mutex
+	m map[string]*imageLock
+}{m: map[string]*imageLock{}}
+
+// imageLock is the lock of one cache entry and the number of requests
+// holding or waiting for it. It is dropped from imageLocks when the last
+// one releases it.
+type imageLock struct {
+	sync.Mutex
+	users int
+}
+
+func lockImage(key string) func() {
+	imageLocks.Lock()
+	l, ok := imageLocks.m[key]
+	if !ok {
+		l = &imageLock{}
+		imageLocks.m[key] = l
+	}
+	l.users++
+	imageLocks.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		imageLocks.Lock()
+		if l.users--; l.users == 0 {
+			delete(imageLocks.m, key)
+		}
+		imageLocks.Unlock()
+	}
+}
+
+// imageCacheDir holds the downloaded originals and their thumbnails.
+func imageCacheDir() string {
+	return filepath.Join(dataDir, "images")
+}
+
+// imageKey names the cache files of an image URL, so a changed URL (for
+// example through an override) is fetched again.
+func imageKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:12])
+}
+
+// imageFailures remembers the cache entries whose download failed lately,
+// so a dead image is not fetched again on every request.
+var imageFailures = struct {
+	sync.Mutex
+	m map[string]imageFailure
+}{m: map[string]imageFailure{}}
+
+type imageFailure struct {
+	err   error
+	until time.Time
+}
+
+// recentImageFailure returns the error of a download of the cache entry at
+// path that failed less than imageRetryAfter ago.
+func recentImageFailure(path string) error {
+	imageFailures.Lock()
+	defer imageFailures.Unlock()
+	f, ok := imageFailures.m[path]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(f.until) {
+		delete(imageFailures.m, path)
+		return nil
+	}
+	return f.err
+}
+
+// rememberImageFailure records a failed download, dropping expired ones.
+func rememberImageFailure(path string, err error) {
+	imageFailures.Lock()
+	defer imageFailures.Unlock()
+	now := time.Now()
+	for p, f := range imageFailures.m {
+		if now.After(f.until) {
+			delete(imageFailures.m, p)
+		}
+	}
+	imageFailures.m[path] = imageFailure{err: err, until: now.Add(imageRetryAfter)}
+}
+
+/*
+originalImage returns the image at url from the disk cache, downloading it
+on the first request. Only responses that decode as JPEG, PNG or GIF are
+stored. A failed download is not retried for imageRetryAfter.
+*/
+func originalImage(url string) ([]byte, error) {
+	if url == "" {
+		return nil, errors.New("artist has no image")
+	}
+	path := filepath.Join(imageCacheDir(), imageKey(url))
+	defer lockImage(path)()
+
+	if data, err := os.ReadFile(path); err == nil {
+		return data, nil
+	}
+	if err := recentImageFailure(path); err != nil {
+		return nil, err
+	}
+	data, err := downloadImage(url)
+	if err != nil {
+		rememberImageFailure(path, err)
+		return nil, err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// downloadImage fetches the image at url within imageFetchTimeout and
+// checks that it is an image of a reasonable size.
+func downloadImage(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), imageFetchTimeout)
+	defer cancel()
+	res, err := upstreamGetContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image %s: unexpected status %s", url, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image %s is larger than %d bytes", url, maxImageBytes)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", url, err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("image %s is too large (%dx%d)", url, cfg.Width, cfg.Height)
+	}
+	return data, nil
+}
+
+/*
+thumbnail returns original scaled down to width, cached next to it. Images
+that are already narrow enough are returned unchanged. JPEGs stay JPEGs;
+PNGs and GIFs become PNGs to keep their transparency.
+*/
+func thumbnail(url string, original []byte, width int) ([]byte, error) {
+	path := filepath.Join(imageCacheDir(), fmt.Sprintf("%s-w%d", imageKey(url), width))
+	defer lockImage(path)()
+
+	if data, err := os.ReadFile(path); err == nil {
+		return data, nil
+	}
+	src, format, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	if src.Bounds().Dx() <= width {
+		return original, nil
+	}
+
+	var buf bytes.Buffer
+	dst := resizeImage(src, width)
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+resizeImage scales src down to width pixels, keeping its aspect ratio.
+Each destination pixel is the average of the source pixels it covers, which
+avoids the aliasing of nearest-neighbour sampling.
+*/
+func resizeImage(src image.Image, width int) *image.NRGBA {
+	b := src.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	in := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*b.Dy()/height, (y+1)*b.Dy()/height
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*b.Dx()/width, (x+1)*b.Dx()/width
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					// weight colours by alpha so transparent pixels don't darken edges
+					r += int(p[0]) * int(p[3])
+					g += int(p[1]) * int(p[3])
+					bl += int(p[2]) * int(p[3])
+					a += int(p[3])
+					n++
+				}
+			}
+			if a > 0 {
+				out.SetNRGBA(x, y, color.NRGBA{uint8(r / a), uint8(g / a), uint8(bl / a), uint8(a / n)})
+			}
+		}
+	}
+	return out
+}
+
+// placeholderImage is a plain square shown when an image cannot be loaded.
+func placeholderImage(width int) []byte {
+	if width == 0 {
+		width = 300
+	}
+	img := image.NewPaletted(image.Rect(0, 0, width, width), color.Palette{color.RGBA{0x33, 0x33, 0x33, 0xff}})
+	var buf bytes.Buffer
+	gif.Encode(&buf, img, nil)
+	return buf.Bytes()
+}
+
+/*
+ImageHandler serves an artist's image through a disk cache, so the pages do
+not depend on the speed of the upstream image host. ?w=N scales it down to
+about N pixels wide: N is snapped to the next of imageWidths. When the
+image cannot be fetched a placeholder is served instead, with a short cache
+lifetime.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func ImageHandler(w http.ResponseWriter, r *http.Request) {
+	width := 0
+	if raw := r.URL.Query().Get("w"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			renderError(w, r, http.StatusBadRequest, "Invalid width, want a positive number of pixels")
+			return
+		}
+		width = snapImageWidth(n)
+	}
+	d, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Failed to load artists")
+		return
+	}
+	artist, ok := d.Artist(PathInt(r, "id"))
+	if !ok {
+		renderError(w, r, http.StatusNotFound, "Artist not found")
+		return
+	}
+
+	data, err := originalImage(artist.Image)
+	if err == nil && width > 0 {
+		data, err = thumbnail(artist.Image, data, width)
+	}
+	maxAge := imageMaxAge
+	if err != nil {
+		log.Printf("Error loading the image of artist %d: %v", artist.ID, err)
+		data, maxAge = placeholderImage(width), placeholderMaxAge
+		w.Header().Set("X-Groupie-Placeholder", "true")
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// imageOrigin is a fake image host that counts its requests.
+type imageOrigin struct {
+	mu       sync.Mutex
+	requests int
+	fail     bool
+}
+
+func (o *imageOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	o.requests++
+	fail := o.fail
+	o.mu.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x % 2 * 255), 0, 0, 255})
+		}
+	}
+	switch r.URL.Path {
+	case "/a.png":
+		png.Encode(w, img)
+	case "/b.jpeg":
+		jpeg.Encode(w, img, nil)
+	default:
+		w.Write([]byte("not an image"))
+	}
+}
+
+func (o *imageOrigin) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests
+}
+
+// useImageOrigin points the test artists' images at a fake host: Queen
+// has a PNG, Pink Floyd a JPEG and Scorpions something else.
+func useImageOrigin(t *testing.T) *imageOrigin {
+	t.Helper()
+	useTempDataDir(t)
+	origin := &imageOrigin{}
+	server := httptest.NewServer(origin)
+	t.Cleanup(server.Close)
+
+	d := useTestDataset(t)
+	d.Artists[0].Image = server.URL + "/a.png"
+	d.Artists[1].Image = server.URL + "/b.jpeg"
+	d.Artists[2].Image = server.URL + "/c.txt"
+	return origin
+}
+
+func TestImageHandler(t *testing.T) {
+	useImageOrigin(t)
+	handler := Routes()
+
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantType        string
+		wantWidth       int
+		wantPlaceholder bool
+	}{
+		{"original", "/img/1", http.StatusOK, "image/png", 400, false},
+		{"png thumbnail", "/img/1?w=120", http.StatusOK, "image/png", 120, false},
+		{"jpeg thumbnail", "/img/2?w=240", http.StatusOK, "image/jpeg", 240, false},
+		{"snapped width", "/img/2?w=200", http.StatusOK, "image/jpeg", 240, false},
+		{"wider than the original", "/img/2?w=800", http.StatusOK, "image/jpeg", 400, false},
+		{"wider than any thumbnail", "/img/1?w=5000", http.StatusOK, "image/png", 400, false},
+		{"not an image", "/img/3?w=100", http.StatusOK, "image/gif", 120, true},
+		{"invalid width", "/img/1?w=0", http.StatusBadRequest, "", 0, false},
+		{"not a number", "/img/1?w=wide", http.StatusBadRequest, "", 0, false},
+		{"unknown artist", "/img/42", http.StatusNotFound, "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d; got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("expected %s; got %s", tt.wantType, got)
+			}
+			if got := w.Header().Get("X-Groupie-Placeholder") == "true"; got != tt.wantPlaceholder {
+				t.Errorf("expected placeholder %v; got %v", tt.wantPlaceholder, got)
+			}
+			wantCache := "public, max-age=2592000"
+			if tt.wantPlaceholder {
+				wantCache = "public, max-age=300"
+			}
+			if got := w.Header().Get("Cache-Control"); got != wantCache {
+				t.Errorf("expected Cache-Control %q; got %q", wantCache, got)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("invalid image: %v", err)
+			}
+			if cfg.Width != tt.wantWidth {
+				t.Errorf("expected width %d; got %d", tt.wantWidth, cfg.Width)
+			}
+		})
+	}
+}
+
+func TestImageHandlerCachesOnDisk(t *testing.T) {
+	origin := useImageOrigin(t)
+	handler := Routes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	first := get("/img/1?w=100")
+	get("/img/1?w=50")
+	get("/img/1")
+	if n := origin.count(); n != 1 {
+		t.Errorf("expected the origin to be asked once; got %d requests", n)
+	}
+
+	// Once cached, an origin outage goes unnoticed.
+	origin.mu.Lock()
+	origin.fail = true
+	origin.mu.Unlock()
+	if w := get("/img/1?w=100"); !bytes.Equal(w.Body.Bytes(), first.Body.Bytes()) {
+		t.Error("expected the cached thumbnail to be served")
+	}
+	if w := get("/img/2?w=100"); w.Header().Get("X-Groupie-Placeholder") != "true" {
+		t.Error("expected a placeholder when the origin fails")
+	}
+
+	// w=100 and w=50 share one thumbnail, next to the original.
+	if files, _ := os.ReadDir(imageCacheDir()); len(files) != 2 {
+		t.Errorf("expected the original and one thumbnail on disk; got %d files", len(files))
+	}
+	imageLocks.Lock()
+	defer imageLocks.Unlock()
+	if n := len(imageLocks.m); n != 0 {
+		t.Errorf("expected released locks to be dropped; %d left", n)
+	}
+}
+
+func TestImageHandlerHungOrigin(t *testing.T) {
+	useTempDataDir(t)
+	previous := imageFetchTimeout
+	imageFetchTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { imageFetchTimeout = previous })
+
+	var mu sync.Mutex
+	requests := 0
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	d := useTestDataset(t)
+	d.Artists[0].Image = server.URL + "/hung.png"
+	handler := Routes()
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/img/1?w=120", nil))
+		if w.Header().Get("X-Groupie-Placeholder") != "true" {
+			t.Errorf("request %d: expected a placeholder for a hung origin", i+1)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("request %d: expected the placeholder after the timeout; took %v", i+1, elapsed)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("expected the failure to be remembered; the origin got %d requests", requests)
+	}
+}
+
+func TestLockImage(t *testing.T) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	inside := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockImage("a")
+			mu.Lock()
+			inside++
+			if inside > 1 {
+				t.Error("expected one holder at a time")
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			inside--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+	imageLocks.Lock()
+	defer imageLocks.Unlock()
+	if n := len(imageLocks.m); n != 0 {
+		t.Errorf("expected released locks to be dropped; %d left", n)
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		src.Set(x, 0, color.NRGBA{255, 255, 255, 255})
+		src.Set(x, 1, color.NRGBA{0, 0, 0, 0})
+	}
+	dst := resizeImage(src, 2)
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Fatalf("expected a 2x1 image; got %v", dst.Bounds())
+	}
+	// transparent pixels lower the alpha but do not darken the colour
+	if got := dst.NRGBAAt(0, 0); got != (color.NRGBA{255, 255, 255, 127}) {
+		t.Errorf("unexpected pixel %v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types the application can respond with.
+const (
+	mediaHTML    = "text/html"
+	mediaJSON    = "application/json"
+	mediaProblem = "application/problem+json"
+	mediaPlain   = "text/plain"
+)
+
+/*
+negotiate picks the offer that best matches the request's Accept header.
+Offers are compared by quality, then by how specifically the Accept header
+names them, then by their order. If the header is missing or an offer is
+only matched by "*\/*", fallback is returned instead so that generic clients
+such as curl get a predictable type.
+*/
+func negotiate(r *http.Request, offers []string, fallback string) string {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return fallback
+	}
+
+	best, bestQ, bestSpec := "", 0.0, -1
+	for _, offer := range offers {
+		q, spec := acceptQuality(accept, offer)
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && spec > bestSpec) {
+			best, bestQ, bestSpec = offer, q, spec
+		}
+	}
+	if best == "" || bestSpec == 0 {
+		return fallback
+	}
+	return best
+}
+
+/*
+acceptQuality returns the quality the Accept header gives to mediaType and
+the specificity of the range that matched it: 2 for an exact match,
+1 for "type/*" and 0 for "*\/*". It returns -1 specificity if nothing matched.
+*/
+func acceptQuality(accept, mediaType string) (q float64, specificity int) {
+	specificity = -1
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		rng := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		spec := -1
+		switch {
+		case rng == mediaType:
+			spec = 2
+		case rng == major+"/*":
+			spec = 1
+		case rng == "*/*":
+			spec = 0
+		}
+		if spec < specificity || spec < 0 {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = v
+				}
+			}
+		}
+		q, specificity = quality, spec
+	}
+	return q, specificity
+}
+
+/*
+wantsJSON reports whether the client asked for the JSON variant of a page,
+either with ?format=json or through the Accept header.
+*/
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return negotiate(r, []string{mediaHTML, mediaJSON}, mediaHTML) == mediaJSON
+}
+
+// writeJSON writes v as an indented JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
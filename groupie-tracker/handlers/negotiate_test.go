@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mediaHTML, mediaProblem, mediaJSON, mediaPlain}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"No header", "", mediaPlain},
+		{"Wildcard", "*/*", mediaPlain},
+		{"Browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", mediaHTML},
+		{"Problem JSON", "application/problem+json", mediaProblem},
+		{"Plain JSON", "application/json", mediaJSON},
+		{"Preferred by quality", "text/html;q=0.5, application/json", mediaJSON},
+		{"Type wildcard", "text/*", mediaHTML},
+		{"Refused type", "text/html;q=0, text/plain", mediaPlain},
+		{"Unknown type", "image/png", mediaPlain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			if got := negotiate(r, offers, mediaPlain); got != tt.want {
+				t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderErrorNegotiation(t *testing.T) {
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renderError(w, r, http.StatusNotFound, "Artist not found")
+	}))
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        []string
+	}{
+		{"HTML for browsers", "text/html", "text/html; charset=utf-8", []string{"Error 404", "Artist not found", "req-42"}},
+		{"Problem JSON for API clients", "application/json", "application/problem+json", []string{`"status":404`, `"detail":"Artist not found"`, `"requestId":"req-42"`}},
+		{"Plain text for curl", "*/*", "text/plain; charset=utf-8", []string{"Error 404 Not Found: Artist not found", "Request ID: req-42"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/artist/9", nil)
+			r.Header.Set("Accept", tt.accept)
+			r.Header.Set("X-Request-ID", "req-42")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected status %d; got %d", http.StatusNotFound, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("expected Content-Type %q; got %q", tt.wantContentType, got)
+			}
+			if got := w.Header().Get("X-Request-ID"); got != "req-42" {
+				t.Errorf("expected X-Request-ID %q; got %q", "req-42", got)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("expected body to contain %q; got %q", want, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestProblemDocument(t *testing.T) {
+	r := httptest.NewRequest("GET", "/artist/9", nil)
+	r.Header.Set("Accept", "application/problem+json")
+	w := httptest.NewRecorder()
+
+	renderError(w, r, http.StatusBadRequest, "Invalid id")
+
+	var p problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if p.Status != http.StatusBadRequest || p.Title != "Bad Request" || p.Instance != "/artist/9" {
+		t.Errorf("unexpected problem document: %+v", p)
+	}
+}
+
+func TestWithRequestIDGenerates(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-ID", "bad id with spaces")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if seen == "" || seen == "bad id with spaces" {
+		t.Errorf("expected a generated request ID, got %q", seen)
+	}
+	if w.Header().Get("X-Request-ID") != seen {
+		t.Errorf("response header %q does not match request ID %q", w.Header().Get("X-Request-ID"), seen)
+	}
+}
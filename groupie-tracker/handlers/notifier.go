@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is one notification to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// Notifier delivers messages to users. SMTPNotifier sends email;
+// LogNotifier only writes them to the log.
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+/*
+SMTPNotifier sends messages as plain-text email through an SMTP server.
+Addr is "host:port"; Auth may be nil for servers that accept mail without
+authentication, such as a local relay.
+*/
+type SMTPNotifier struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// Notify sends msg as an email.
+func (n SMTPNotifier) Notify(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid header value in message to %q", msg.To)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return smtp.SendMail(n.Addr, n.Auth, n.From, []string{msg.To}, []byte(b.String()))
+}
+
+// LogNotifier writes messages to the log instead of sending them.
+type LogNotifier struct{}
+
+// Notify logs the recipient and subject of msg.
+func (LogNotifier) Notify(msg Message) error {
+	log.Printf("Notification to %s: %s", msg.To, msg.Subject)
+	return nil
+}
+
+/*
+NotifierFromEnv builds the notifier configured in the environment:
+GROUPIE_SMTP_ADDR (host:port) and GROUPIE_SMTP_FROM select SMTP, with
+GROUPIE_SMTP_USER and GROUPIE_SMTP_PASSWORD for PLAIN authentication.
+Without GROUPIE_SMTP_ADDR messages are only logged.
+*/
+func NotifierFromEnv() Notifier {
+	addr := os.Getenv("GROUPIE_SMTP_ADDR")
+	if addr == "" {
+		return LogNotifier{}
+	}
+	n := SMTPNotifier{Addr: addr, From: os.Getenv("GROUPIE_SMTP_FROM")}
+	if n.From == "" {
+		n.From = "groupie-tracker@localhost"
+	}
+	if user := os.Getenv("GROUPIE_SMTP_USER"); user != "" {
+		host := addr
+		if i := strings.LastIndex(addr, ":"); i >= 0 {
+			host = addr[:i]
+		}
+		n.Auth = smtp.PlainAuth("", user, os.Getenv("GROUPIE_SMTP_PASSWORD"), host)
+	}
+	return n
+}
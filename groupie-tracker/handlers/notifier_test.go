@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server that accepts every message.
+type fakeSMTP struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: l}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeSMTP) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTP) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost fake SMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{From: strings.Trim(strings.TrimSpace(line)[10:], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := newFakeSMTP(t)
+	n := SMTPNotifier{Addr: server.addr(), From: "digest@example.com"}
+
+	err := n.Notify(Message{To: "fan@example.com", Subject: "Your digest", Body: "Line one\nLine two\n"})
+	if err != nil {
+		t.Fatalf("Notify() returned an error: %v", err)
+	}
+
+	got := server.received()
+	if len(got) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(got))
+	}
+	m := got[0]
+	if m.From != "digest@example.com" || len(m.To) != 1 || m.To[0] != "fan@example.com" {
+		t.Errorf("envelope = %s -> %v", m.From, m.To)
+	}
+	for _, want := range []string{"Subject: Your digest\r\n", "To: fan@example.com\r\n", "Content-Type: text/plain; charset=utf-8\r\n", "\r\n\r\nLine one\r\nLine two\r\n"} {
+		if !strings.Contains(m.Data, want) {
+			t.Errorf("message is missing %q:\n%s", want, m.Data)
+		}
+	}
+
+	if err := n.Notify(Message{To: "fan@example.com\r\nBcc: x@example.com", Subject: "x"}); err == nil {
+		t.Error("expected an error for a header injection attempt")
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultOverlapWindow is how many days apart two concerts may be and
+	// still count as an overlap when no window is requested.
+	defaultOverlapWindow = 7
+	maxOverlapWindow     = 365
+)
+
+// ArtistConcert is a concert together with the artist who played it.
+type ArtistConcert struct {
+	ArtistID   int     `json:"artistId"`
+	ArtistName string  `json:"artistName"`
+	Concert    Concert `json:"concert"`
+}
+
+// Overlap is two artists playing the same location within the window.
+type Overlap struct {
+	Location  string        `json:"location"`
+	City      string        `json:"city"`
+	Country   string        `json:"country"`
+	First     ArtistConcert `json:"first"`
+	Second    ArtistConcert `json:"second"`
+	DaysApart int           `json:"daysApart"`
+}
+
+// OverlapOptions filters the overlap analysis. Zero values disable a filter.
+type OverlapOptions struct {
+	WindowDays int    `json:"windowDays"`
+	Country    string `json:"country,omitempty"`
+	Year       int    `json:"year,omitempty"`
+}
+
+// OverlapReport is the data behind /insights/overlaps.
+type OverlapReport struct {
+	Options  OverlapOptions `json:"options"`
+	Overlaps []Overlap      `json:"overlaps"`
+}
+
+/*
+FindOverlaps looks through every relation in the dataset for concerts by
+different artists at the same location at most opts.WindowDays apart.
+The country filter matches the parsed country case-insensitively and the year
+filter keeps pairs where either concert falls in that year.
+Results are ordered by the first concert's date, then location.
+*/
+func FindOverlaps(d *Dataset, opts OverlapOptions) []Overlap {
+	byLocation := map[string][]ArtistConcert{}
+	for _, artist := range d.Artists {
+		for _, c := range d.Concerts(artist.ID) {
+			if opts.Country != "" && !strings.EqualFold(c.Country, opts.Country) {
+				continue
+			}
+			byLocation[c.Location] = append(byLocation[c.Location], ArtistConcert{
+				ArtistID: artist.ID, ArtistName: artist.Name, Concert: c,
+			})
+		}
+	}
+
+	overlaps := []Overlap{}
+	for location, concerts := range byLocation {
+		sort.SliceStable(concerts, func(i, j int) bool {
+			return concerts[i].Concert.Date.Before(concerts[j].Concert.Date)
+		})
+		for i := range concerts {
+			for j := i + 1; j < len(concerts); j++ {
+				days := int(concerts[j].Concert.Date.Sub(concerts[i].Concert.Date).Hours() / 24)
+				if days > opts.WindowDays {
+					break
+				}
+				a, b := concerts[i], concerts[j]
+				if a.ArtistID == b.ArtistID {
+					continue
+				}
+				if opts.Year != 0 && a.Concert.Date.Year() != opts.Year && b.Concert.Date.Year() != opts.Year {
+					continue
+				}
+				overlaps = append(overlaps, Overlap{
+					Location:  location,
+					City:      a.Concert.City,
+					Country:   a.Concert.Country,
+					First:     a,
+					Second:    b,
+					DaysApart: days,
+				})
+			}
+		}
+	}
+
+	sort.Slice(overlaps, func(i, j int) bool {
+		di, dj := overlaps[i].First.Concert.Date, overlaps[j].First.Concert.Date
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		if overlaps[i].Location != overlaps[j].Location {
+			return overlaps[i].Location < overlaps[j].Location
+		}
+		return overlaps[i].Second.Concert.Date.Before(overlaps[j].Second.Concert.Date)
+	})
+	return overlaps
+}
+
+// parseOverlapOptions reads the window, country and year query parameters.
+func parseOverlapOptions(r *http.Request) (OverlapOptions, error) {
+	q := r.URL.Query()
+	opts := OverlapOptions{
+		WindowDays: defaultOverlapWindow,
+		Country:    strings.TrimSpace(q.Get("country")),
+	}
+
+	if raw := q.Get("window"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxOverlapWindow {
+			return opts, fmt.Errorf("window must be a number of days between 0 and %d", maxOverlapWindow)
+		}
+		opts.WindowDays = n
+	}
+	if raw := q.Get("year"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1000 || n > 9999 {
+			return opts, fmt.Errorf("year must be a four-digit year")
+		}
+		opts.Year = n
+	}
+	return opts, nil
+}
+
+/*
+OverlapsHandler lists artists who played the same city on the same or
+nearby dates. It accepts ?window=days, ?country= and ?year= filters and
+returns JSON for ?format=json or an Accept header asking for JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func OverlapsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseOverlapOptions(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	if dataNotModified(w, r, datasetValidator(data), "overlaps") {
+		return
+	}
+
+	report := OverlapReport{Options: opts, Overlaps: FindOverlaps(data, opts)}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	temp, err := parseTemplate("template/overlaps.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+
+	err = temp.Execute(w, report)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	d := newTestDataset()
+
+	tests := []struct {
+		name      string
+		opts      OverlapOptions
+		wantPairs [][2]string
+	}{
+		{"Same day only", OverlapOptions{WindowDays: 0}, [][2]string{{"Queen", "Scorpions"}}},
+		{"Within a week", OverlapOptions{WindowDays: 7}, [][2]string{{"Queen", "Pink Floyd"}, {"Queen", "Scorpions"}}},
+		{"Country filter", OverlapOptions{WindowDays: 7, Country: "uk"}, [][2]string{{"Queen", "Pink Floyd"}}},
+		{"Year filter", OverlapOptions{WindowDays: 7, Year: 2020}, [][2]string{{"Queen", "Scorpions"}}},
+		{"Wide window", OverlapOptions{WindowDays: 400, Country: "UK"}, [][2]string{{"Scorpions", "Queen"}, {"Scorpions", "Pink Floyd"}, {"Queen", "Pink Floyd"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindOverlaps(d, tt.opts)
+			if len(got) != len(tt.wantPairs) {
+				t.Fatalf("FindOverlaps() returned %d overlaps, want %d: %+v", len(got), len(tt.wantPairs), got)
+			}
+			for i, pair := range tt.wantPairs {
+				if got[i].First.ArtistName != pair[0] || got[i].Second.ArtistName != pair[1] {
+					t.Errorf("overlap %d = %s/%s, want %s/%s", i, got[i].First.ArtistName, got[i].Second.ArtistName, pair[0], pair[1])
+				}
+			}
+		})
+	}
+}
+
+func TestParseOverlapOptions(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    OverlapOptions
+		wantErr bool
+	}{
+		{"", OverlapOptions{WindowDays: defaultOverlapWindow}, false},
+		{"?window=0&country=USA&year=2019", OverlapOptions{WindowDays: 0, Country: "USA", Year: 2019}, false},
+		{"?window=-1", OverlapOptions{}, true},
+		{"?window=1000", OverlapOptions{}, true},
+		{"?year=abc", OverlapOptions{}, true},
+		{"?year=%3Cb%3E", OverlapOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseOverlapOptions(httptest.NewRequest("GET", "/insights/overlaps"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOverlapOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && (strings.Contains(err.Error(), "abc") || strings.Contains(err.Error(), "<")) {
+				t.Errorf("parseOverlapOptions() error %q echoes the input", err)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseOverlapOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Override is one artist's entry in data/overrides.json, which corrects
+upstream data we cannot fix at the source. The file maps artist IDs to
+overrides:
+
+	{
+	  "1": {
+	    "artist": {"members": ["Freddie Mercury", "Brian May", "John Deacon", "Roger Taylor"]},
+	    "renameLocations": {"north_carolina-usa": "charlotte-usa"},
+	    "dropLocations": ["playa_del_carmen-mexico"],
+	    "addDates": {"london-uk": ["01-07-2020"]},
+	    "removeDates": {"osaka-japan": ["30-01-2020"]},
+	    "note": "Member names reported by fans"
+	  }
+	}
+
+Renames are applied first, then drops, additions and removals, so the
+other operations use the corrected location names.
+*/
+type Override struct {
+	Artist          ArtistPatch         `json:"artist"`
+	RenameLocations map[string]string   `json:"renameLocations,omitempty"`
+	DropLocations   []string            `json:"dropLocations,omitempty"`
+	AddDates        map[string][]string `json:"addDates,omitempty"`
+	RemoveDates     map[string][]string `json:"removeDates,omitempty"`
+	Note            string              `json:"note,omitempty"`
+}
+
+// ArtistPatch replaces the Artist fields that are set.
+type ArtistPatch struct {
+	Name         *string  `json:"name,omitempty"`
+	Image        *string  `json:"image,omitempty"`
+	Members      []string `json:"members,omitempty"`
+	CreationDate *int     `json:"creationDate,omitempty"`
+	FirstAlbum   *string  `json:"firstAlbum,omitempty"`
+}
+
+// Overrides are the corrections of every artist, by artist ID.
+type Overrides map[int]Override
+
+// overridesMu serializes read-modify-write updates of the overrides file.
+var overridesMu sync.Mutex
+
+func overridesPath() string {
+	return filepath.Join(dataDir, "overrides.json")
+}
+
+// readOverrides loads the overrides file; a missing file means none.
+func readOverrides() (Overrides, error) {
+	raw := map[string]Override{}
+	err := readJSONFile(overridesPath(), &raw)
+	if os.IsNotExist(err) {
+		return Overrides{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	overrides := Overrides{}
+	for key, o := range raw {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		overrides[id] = o
+	}
+	return overrides, nil
+}
+
+// writeOverrides replaces the overrides file.
+func writeOverrides(overrides Overrides) error {
+	raw := map[string]Override{}
+	for id, o := range overrides {
+		raw[strconv.Itoa(id)] = o
+	}
+	return writeJSONFile(overridesPath(), raw)
+}
+
+// applyArtist patches a and records the corrected fields in a.Corrected.
+func (o Override) applyArtist(a Artist) Artist {
+	p := o.Artist
+	var corrected []string
+	if p.Name != nil && *p.Name != a.Name {
+		a.Name = *p.Name
+		corrected = append(corrected, "name")
+	}
+	if p.Image != nil && *p.Image != a.Image {
+		a.Image = *p.Image
+		corrected = append(corrected, "image")
+	}
+	if p.Members != nil && !equalStrings(p.Members, a.Members) {
+		a.Members = append([]string(nil), p.Members...)
+		corrected = append(corrected, "members")
+	}
+	if p.CreationDate != nil && *p.CreationDate != a.CreationDate {
+		a.CreationDate = *p.CreationDate
+		corrected = append(corrected, "creationDate")
+	}
+	if p.FirstAlbum != nil && *p.FirstAlbum != a.FirstAlbum {
+		a.FirstAlbum = *p.FirstAlbum
+		corrected = append(corrected, "firstAlbum")
+	}
+	a.Corrected = corrected
+	return a
+}
+
+/*
+applyRelation returns a corrected copy of rel. The dates that came from a
+renamed location or from AddDates are listed in its Corrected field.
+*/
+func (o Override) applyRelation(rel Relation) Relation {
+	locations := map[string][]string{}
+	corrected := map[string][]string{}
+	for loc, dates := range rel.Locations {
+		if to, ok := o.RenameLocations[loc]; ok && to != "" {
+			loc = to
+			corrected[loc] = append(corrected[loc], dates...)
+		}
+		locations[loc] = append(locations[loc], dates...)
+	}
+	for _, loc := range o.DropLocations {
+		delete(locations, loc)
+	}
+	for loc, dates := range o.AddDates {
+		for _, d := range dates {
+			if !containsDate(locations[loc], d) {
+				locations[loc] = append(locations[loc], upstreamDate(d))
+				corrected[loc] = append(corrected[loc], upstreamDate(d))
+			}
+		}
+	}
+	for loc, dates := range o.RemoveDates {
+		var kept []string
+		for _, d := range locations[loc] {
+			if !containsDate(dates, d) {
+				kept = append(kept, d)
+			}
+		}
+		if len(kept) == 0 {
+			delete(locations, loc)
+		} else {
+			locations[loc] = kept
+		}
+	}
+
+	rel.Corrected = nil
+	for loc, dates := range corrected {
+		var kept []string
+		for _, d := range dates {
+			if containsDate(locations[loc], d) {
+				kept = append(kept, d)
+			}
+		}
+		if len(kept) > 0 {
+			if rel.Corrected == nil {
+				rel.Corrected = map[string][]string{}
+			}
+			rel.Corrected[loc] = sortDates(kept)
+		}
+	}
+	for loc := range locations {
+		sortDates(locations[loc])
+	}
+	rel.Locations = locations
+	return rel
+}
+
+// sortDates sorts concert dates by day, in place. Dates that do not parse
+// go last.
+func sortDates(dates []string) []string {
+	sort.SliceStable(dates, func(i, j int) bool {
+		a, errA := ParseConcertDate(dates[i])
+		b, errB := ParseConcertDate(dates[j])
+		if errA != nil || errB != nil {
+			return errA == nil && errB != nil
+		}
+		return a.Before(b)
+	})
+	return dates
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+containsDate reports whether list holds the concert date d. Dates are
+compared as days, so "2019-11-20", "20-11-2019" and the upstream
+"*20-11-2019" are the same; dates that do not parse are compared as text.
+*/
+func containsDate(list []string, d string) bool {
+	t, err := ParseConcertDate(d)
+	for _, v := range list {
+		if u, err2 := ParseConcertDate(v); err == nil && err2 == nil {
+			if t.Equal(u) {
+				return true
+			}
+		} else if strings.TrimSpace(v) == strings.TrimSpace(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamDate writes a concert date the way the API does, DD-MM-YYYY, so
+// added dates sort and compare like the others.
+func upstreamDate(d string) string {
+	t, err := ParseConcertDate(d)
+	if err != nil {
+		return d
+	}
+	return t.Format("02-01-2006")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+OverrideProvider applies the corrections in data/overrides.json on top of
+another provider. The file is read on every call, so edits take effect
+with the next fetch. Corrected artist fields are listed in
+Artist.Corrected and corrected concerts have Concert.Corrected set.
+*/
+type OverrideProvider struct {
+	Base Provider
+}
+
+// WithOverrides wraps p so that local corrections are applied to its data.
+func WithOverrides(p Provider) Provider {
+	return OverrideProvider{Base: p}
+}
+
+func (p OverrideProvider) ListArtists() ([]Artist, error) {
+	artists, err := p.Base.ListArtists()
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := readOverrides()
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range artists {
+		if o, ok := overrides[a.ID]; ok {
+			artists[i] = o.applyArtist(a)
+		}
+	}
+	return artists, nil
+}
+
+func (p OverrideProvider) GetArtist(id int) (Artist, error) {
+	a, err := p.Base.GetArtist(id)
+	if err != nil {
+		return Artist{}, err
+	}
+	overrides, err := readOverrides()
+	if err != nil {
+		return Artist{}, err
+	}
+	if o, ok := overrides[id]; ok {
+		a = o.applyArtist(a)
+	}
+	return a, nil
+}
+
+func (p OverrideProvider) ListRelations() ([]Relation, error) {
+	relations, err := p.Base.ListRelations()
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := readOverrides()
+	if err != nil {
+		return nil, err
+	}
+	for i, rel := range relations {
+		if o, ok := overrides[int(rel.ID)]; ok {
+			relations[i] = o.applyRelation(rel)
+		}
+	}
+	return relations, nil
+}
+
+func (p OverrideProvider) GetConcerts(id int) ([]Concert, error) {
+	concerts, err := p.Base.GetConcerts(id)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := readOverrides()
+	if err != nil {
+		return nil, err
+	}
+	o, ok := overrides[id]
+	if !ok {
+		return concerts, nil
+	}
+
+	// Rebuild the relation so the same rules apply as for ListRelations.
+	rel := Relation{ID: int64(id), Locations: map[string][]string{}}
+	for _, c := range concerts {
+		rel.Locations[c.Location] = append(rel.Locations[c.Location], c.Date.Format("02-01-2006"))
+	}
+	return BuildConcerts(o.applyRelation(rel)), nil
+}
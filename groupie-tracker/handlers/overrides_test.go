@@ -0,0 +1,190 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func TestOverrideApplyArtist(t *testing.T) {
+	base := Artist{ID: 1, Name: "Queen", Members: []string{"Freddie Mercury", "John Daecon"}, CreationDate: 1970}
+
+	tests := []struct {
+		name          string
+		patch         ArtistPatch
+		wantName      string
+		wantMembers   string
+		wantCorrected string
+	}{
+		{"no patch", ArtistPatch{}, "Queen", "Freddie Mercury,John Daecon", ""},
+		{"members", ArtistPatch{Members: []string{"Freddie Mercury", "John Deacon"}}, "Queen", "Freddie Mercury,John Deacon", "members"},
+		{"same value is not a correction", ArtistPatch{Name: stringPtr("Queen")}, "Queen", "Freddie Mercury,John Daecon", ""},
+		{"name and members", ArtistPatch{Name: stringPtr("QUEEN"), Members: []string{"Freddie Mercury"}}, "QUEEN", "Freddie Mercury", "name,members"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Override{Artist: tt.patch}.applyArtist(base)
+			if got.Name != tt.wantName || strings.Join(got.Members, ",") != tt.wantMembers {
+				t.Errorf("applyArtist() = %q %v", got.Name, got.Members)
+			}
+			if strings.Join(got.Corrected, ",") != tt.wantCorrected {
+				t.Errorf("Corrected = %v, want %q", got.Corrected, tt.wantCorrected)
+			}
+		})
+	}
+	if base.Members[1] != "John Daecon" {
+		t.Error("applyArtist() modified the original artist")
+	}
+}
+
+func TestOverrideApplyRelation(t *testing.T) {
+	rel := Relation{ID: 1, Locations: map[string][]string{
+		"londn-uk":    {"20-11-2019"},
+		"london-uk":   {"21-11-2019"},
+		"osaka-japan": {"28-01-2020", "30-01-2020"},
+		"nowhere-xx":  {"01-01-2020"},
+	}}
+	o := Override{
+		RenameLocations: map[string]string{"londn-uk": "london-uk"},
+		DropLocations:   []string{"nowhere-xx"},
+		AddDates:        map[string][]string{"paris-france": {"01-07-2020"}, "london-uk": {"21-11-2019"}},
+		RemoveDates:     map[string][]string{"osaka-japan": {"30-01-2020"}},
+	}
+
+	got := o.applyRelation(rel).Locations
+	want := map[string]string{
+		"london-uk":    "20-11-2019,21-11-2019",
+		"osaka-japan":  "28-01-2020",
+		"paris-france": "01-07-2020",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("applyRelation() = %v, want %v", got, want)
+	}
+	for loc, dates := range want {
+		if strings.Join(got[loc], ",") != dates {
+			t.Errorf("%s = %v, want %s", loc, got[loc], dates)
+		}
+	}
+	if len(rel.Locations["osaka-japan"]) != 2 {
+		t.Error("applyRelation() modified the original relation")
+	}
+
+	// Only the renamed and added dates are corrections, not the date that
+	// was at london-uk upstream already.
+	corrected := o.applyRelation(rel).Corrected
+	wantCorrected := map[string]string{
+		"london-uk":    "20-11-2019",
+		"paris-france": "01-07-2020",
+	}
+	if len(corrected) != len(wantCorrected) {
+		t.Fatalf("Corrected = %v, want %v", corrected, wantCorrected)
+	}
+	for loc, dates := range wantCorrected {
+		if strings.Join(corrected[loc], ",") != dates {
+			t.Errorf("Corrected[%s] = %v, want %s", loc, corrected[loc], dates)
+		}
+	}
+}
+
+func TestOverrideApplyRelationSortsByDay(t *testing.T) {
+	rel := Relation{ID: 1, Locations: map[string][]string{
+		"london-uk": {"03-02-2020", "15-01-2019"},
+	}}
+	o := Override{AddDates: map[string][]string{"london-uk": {"2019-12-01"}}}
+
+	got := o.applyRelation(rel).Locations["london-uk"]
+	if want := "15-01-2019,01-12-2019,03-02-2020"; strings.Join(got, ",") != want {
+		t.Errorf("london-uk = %v, want %s", got, want)
+	}
+}
+
+func TestOverrideApplyRelationDateFormats(t *testing.T) {
+	rel := Relation{ID: 1, Locations: map[string][]string{
+		"london-uk":   {"20-11-2019", "*21-11-2019"},
+		"osaka-japan": {"28-01-2020", "30-01-2020"},
+	}}
+	o := Override{
+		AddDates:    map[string][]string{"london-uk": {"2019-11-20", "2019-11-22"}},
+		RemoveDates: map[string][]string{"london-uk": {"21-11-2019"}, "osaka-japan": {"2020-01-30"}},
+	}
+
+	got := o.applyRelation(rel).Locations
+	want := map[string]string{
+		"london-uk":   "20-11-2019,22-11-2019",
+		"osaka-japan": "28-01-2020",
+	}
+	for loc, dates := range want {
+		if strings.Join(got[loc], ",") != dates {
+			t.Errorf("%s = %v, want %s", loc, got[loc], dates)
+		}
+	}
+}
+
+func TestOverrideProvider(t *testing.T) {
+	useTempDataDir(t)
+	overrides := Overrides{
+		1: {
+			Artist:          ArtistPatch{Members: []string{"Freddie Mercury", "Brian May", "John Deacon", "Roger Taylor"}},
+			RenameLocations: map[string]string{"north_carolina-usa": "charlotte-usa"},
+			DropLocations:   []string{"georgia-usa"},
+			AddDates:        map[string][]string{"london-uk": {"01-07-2020"}},
+		},
+	}
+	if err := writeOverrides(overrides); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readOverrides(); err != nil || len(got[1].Artist.Members) != 4 {
+		t.Fatalf("readOverrides() = %+v, %v", got, err)
+	}
+
+	p := WithOverrides(NewMemoryProvider(fixtureArtists, fixtureRelations))
+
+	a, err := p.GetArtist(1)
+	if err != nil || a.Members[2] != "John Deacon" || !a.IsCorrected("members") || a.IsCorrected("name") {
+		t.Errorf("GetArtist(1) = %+v, %v", a, err)
+	}
+	other, _ := p.GetArtist(2)
+	if len(other.Corrected) != 0 {
+		t.Errorf("GetArtist(2) has corrections %v, want none", other.Corrected)
+	}
+
+	concerts, err := p.GetConcerts(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrected := map[string]bool{}
+	for _, c := range concerts {
+		if c.Location == "georgia-usa" {
+			t.Error("dropped location georgia-usa is still listed")
+		}
+		corrected[c.Location] = c.Corrected
+	}
+	if !corrected["charlotte-usa"] || !corrected["london-uk"] || corrected["osaka-japan"] {
+		t.Errorf("unexpected provenance %v", corrected)
+	}
+
+	d, err := fetchDataset(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Relations[1].Locations["charlotte-usa"]; !ok {
+		t.Errorf("ListRelations() did not apply the rename: %v", d.Relations[1].Locations)
+	}
+	if a, _ := d.Artist(1); !a.IsCorrected("members") {
+		t.Error("ListArtists() did not apply the artist patch")
+	}
+	for _, c := range d.Concerts(1) {
+		if c.Corrected != (c.Location == "charlotte-usa" || c.Location == "london-uk") {
+			t.Errorf("dataset concert %s %s has Corrected = %v", c.Location, c.DateString(), c.Corrected)
+		}
+	}
+
+	useProvider(t, p)
+	w := httptest.NewRecorder()
+	Routes().ServeHTTP(w, httptest.NewRequest("GET", "/artist/1?format=json", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"corrected": [`) || !strings.Contains(body, `"corrected": true`) {
+		t.Errorf("artist JSON does not mark corrections: %s", body)
+	}
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by providers for an artist they do not know.
+var ErrNotFound = errors.New("not found")
+
+/*
+Provider is where the application gets its artists and concerts from.
+The handlers only talk to a Provider, so the upstream API, a saved snapshot
+and in-memory fixtures are interchangeable. Single-artist lookups return
+ErrNotFound (possibly wrapped) for unknown IDs.
+*/
+type Provider interface {
+	// ListArtists returns every artist.
+	ListArtists() ([]Artist, error)
+	// GetArtist returns one artist.
+	GetArtist(id int) (Artist, error)
+	// ListRelations returns the locations and dates of every artist.
+	ListRelations() ([]Relation, error)
+	// GetConcerts returns the concerts of one artist in date order.
+	GetConcerts(id int) ([]Concert, error)
+}
+
+// defaultAPIURL is the root of the upstream API.
+const defaultAPIURL = "https://groupietrackers.herokuapp.com/api/"
+
+// provider is the data source used by the handlers. Tests replace it.
+var provider Provider = HTTPProvider{BaseURL: defaultAPIURL}
+
+// SetProvider replaces the data source. It should be called before serving.
+func SetProvider(p Provider) {
+	provider = p
+}
+
+// HTTPProvider reads the upstream JSON API rooted at BaseURL (with a
+// trailing slash).
+type HTTPProvider struct {
+	BaseURL string
+}
+
+func (p HTTPProvider) ListArtists() ([]Artist, error) {
+	return ReadArtists(p.BaseURL + "artists")
+}
+
+func (p HTTPProvider) GetArtist(id int) (Artist, error) {
+	var a Artist
+	err := p.get("artists/"+strconv.Itoa(id), &a)
+	if err == nil && a.ID == 0 {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return Artist{}, fmt.Errorf("artist %d: %w", id, err)
+	}
+	return a, nil
+}
+
+func (p HTTPProvider) ListRelations() ([]Relation, error) {
+	return ReadAllRelations(p.BaseURL + "relation")
+}
+
+func (p HTTPProvider) GetConcerts(id int) ([]Concert, error) {
+	var rel Relation
+	err := p.get("relation/"+strconv.Itoa(id), &rel)
+	if err == nil && rel.ID == 0 {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("concerts of artist %d: %w", id, err)
+	}
+	return BuildConcerts(rel), nil
+}
+
+// get decodes the JSON document at path; a 404 is reported as ErrNotFound.
+func (p HTTPProvider) get(path string, v interface{}) error {
+	res, err := upstreamGet(p.BaseURL + path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(res.Body).Decode(v)
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("API returned status code: %d", res.StatusCode)
+	}
+}
+
+/*
+MemoryProvider serves a fixed set of artists and relations from memory.
+It backs the snapshot provider and is handy as a test fixture.
+*/
+type MemoryProvider struct {
+	data *Dataset
+}
+
+// NewMemoryProvider returns a provider serving artists and relations.
+func NewMemoryProvider(artists []Artist, relations []Relation) *MemoryProvider {
+	return &MemoryProvider{data: NewDataset(artists, relations, time.Now())}
+}
+
+func (p *MemoryProvider) ListArtists() ([]Artist, error) {
+	return append([]Artist(nil), p.data.Artists...), nil
+}
+
+func (p *MemoryProvider) GetArtist(id int) (Artist, error) {
+	a, ok := p.data.Artist(id)
+	if !ok {
+		return Artist{}, fmt.Errorf("artist %d: %w", id, ErrNotFound)
+	}
+	return a, nil
+}
+
+func (p *MemoryProvider) ListRelations() ([]Relation, error) {
+	var relations []Relation
+	for _, a := range p.data.Artists {
+		if rel, ok := p.data.Relations[a.ID]; ok {
+			relations = append(relations, rel)
+		}
+	}
+	return relations, nil
+}
+
+func (p *MemoryProvider) GetConcerts(id int) ([]Concert, error) {
+	if _, ok := p.data.Relations[id]; !ok {
+		return nil, fmt.Errorf("concerts of artist %d: %w", id, ErrNotFound)
+	}
+	return p.data.Concerts(id), nil
+}
+
+/*
+SnapshotProvider serves a dataset snapshot saved on disk, in the format
+the refresher writes to data/snapshot.json. The file is read on first use.
+*/
+type SnapshotProvider struct {
+	Path string
+
+	once   sync.Once
+	memory *MemoryProvider
+	err    error
+}
+
+func (p *SnapshotProvider) load() (*MemoryProvider, error) {
+	p.once.Do(func() {
+		var s snapshot
+		if err := readJSONFile(p.Path, &s); err != nil {
+			p.err = fmt.Errorf("reading snapshot %s: %w", p.Path, err)
+			return
+		}
+		p.memory = NewMemoryProvider(s.Artists, s.Relations)
+	})
+	return p.memory, p.err
+}
+
+func (p *SnapshotProvider) ListArtists() ([]Artist, error) {
+	m, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return m.ListArtists()
+}
+
+func (p *SnapshotProvider) GetArtist(id int) (Artist, error) {
+	m, err := p.load()
+	if err != nil {
+		return Artist{}, err
+	}
+	return m.GetArtist(id)
+}
+
+func (p *SnapshotProvider) ListRelations() ([]Relation, error) {
+	m, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return m.ListRelations()
+}
+
+func (p *SnapshotProvider) GetConcerts(id int) ([]Concert, error) {
+	m, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetConcerts(id)
+}
+
+/*
+ProviderFromEnv builds the provider selected by GROUPIE_PROVIDER:
+
+	http      the upstream API at GROUPIE_API_URL (the default)
+	snapshot  the snapshot file at GROUPIE_SNAPSHOT (default data/snapshot.json)
+	memory    the built-in fixture artists, for working offline
+*/
+func ProviderFromEnv() (Provider, error) {
+	switch kind := os.Getenv("GROUPIE_PROVIDER"); kind {
+	case "", "http":
+		url := os.Getenv("GROUPIE_API_URL")
+		if url == "" {
+			url = defaultAPIURL
+		}
+		if !strings.HasSuffix(url, "/") {
+			url += "/"
+		}
+		return HTTPProvider{BaseURL: url}, nil
+	case "snapshot":
+		path := os.Getenv("GROUPIE_SNAPSHOT")
+		if path == "" {
+			path = filepath.Join(dataDir, "snapshot.json")
+		}
+		p := &SnapshotProvider{Path: path}
+		if _, err := p.load(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "memory":
+		return NewMemoryProvider(fixtureArtists, fixtureRelations), nil
+	default:
+		return nil, fmt.Errorf("unknown GROUPIE_PROVIDER %q (want http, snapshot or memory)", kind)
+	}
+}
+
+/*
+fetchDataset reads all artists and relations from p concurrently.
+*/
+func fetchDataset(p Provider) (*Dataset, error) {
+	var (
+		wg                   sync.WaitGroup
+		artists              []Artist
+		relations            []Relation
+		artistsErr, relError error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		artists, artistsErr = p.ListArtists()
+	}()
+	go func() {
+		defer wg.Done()
+		relations, relError = p.ListRelations()
+	}()
+	wg.Wait()
+
+	if artistsErr != nil {
+		return nil, artistsErr
+	}
+	if relError != nil {
+		return nil, relError
+	}
+	return NewDataset(artists, relations, time.Now()), nil
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fixtureAPI serves the fixture artists in the upstream API format.
+func fixtureAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	mem := NewMemoryProvider(fixtureArtists, fixtureRelations)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/artists":
+			json.NewEncoder(w).Encode(fixtureArtists)
+		case r.URL.Path == "/relation":
+			json.NewEncoder(w).Encode(map[string][]Relation{"index": fixtureRelations})
+		case strings.HasPrefix(r.URL.Path, "/artists/"):
+			id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/artists/"))
+			a, err := mem.GetArtist(id)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(a)
+		case strings.HasPrefix(r.URL.Path, "/relation/"):
+			id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/relation/"))
+			rel, ok := mem.data.Relations[id]
+			if !ok {
+				// The upstream answers unknown IDs with an empty object.
+				w.Write([]byte(`{}`))
+				return
+			}
+			json.NewEncoder(w).Encode(rel)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProviders(t *testing.T) {
+	dir := useTempDataDir(t)
+	server := fixtureAPI(t)
+
+	snapshotFile := filepath.Join(dir, "fixture-snapshot.json")
+	if err := writeJSONFile(snapshotFile, snapshot{Artists: fixtureArtists, Relations: fixtureRelations}); err != nil {
+		t.Fatal(err)
+	}
+
+	providers := []struct {
+		name string
+		p    Provider
+	}{
+		{"http", HTTPProvider{BaseURL: server.URL + "/"}},
+		{"snapshot", &SnapshotProvider{Path: snapshotFile}},
+		{"memory", NewMemoryProvider(fixtureArtists, fixtureRelations)},
+	}
+
+	for _, tt := range providers {
+		t.Run(tt.name, func(t *testing.T) {
+			artists, err := tt.p.ListArtists()
+			if err != nil || len(artists) != len(fixtureArtists) {
+				t.Errorf("ListArtists() = %d artists, %v", len(artists), err)
+			}
+			relations, err := tt.p.ListRelations()
+			if err != nil || len(relations) != len(fixtureRelations) {
+				t.Errorf("ListRelations() = %d relations, %v", len(relations), err)
+			}
+
+			a, err := tt.p.GetArtist(3)
+			if err != nil || a.Name != "Pink Floyd" {
+				t.Errorf("GetArtist(3) = %q, %v", a.Name, err)
+			}
+			if _, err := tt.p.GetArtist(99); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetArtist(99) error = %v, want ErrNotFound", err)
+			}
+
+			concerts, err := tt.p.GetConcerts(1)
+			if err != nil || len(concerts) != 9 || concerts[0].Location != "los_angeles-usa" {
+				t.Errorf("GetConcerts(1) = %d concerts, %v", len(concerts), err)
+			}
+			if _, err := tt.p.GetConcerts(99); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetConcerts(99) error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+
+	missing := &SnapshotProvider{Path: filepath.Join(dir, "missing.json")}
+	if _, err := missing.ListArtists(); err == nil {
+		t.Error("expected an error for a missing snapshot file")
+	}
+}
+
+func TestProviderFromEnv(t *testing.T) {
+	dir := useTempDataDir(t)
+	writeJSONFile(filepath.Join(dir, "snapshot.json"), snapshot{Artists: fixtureArtists, Relations: fixtureRelations})
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantType string
+		wantErr  bool
+	}{
+		{"default", nil, "api.HTTPProvider", false},
+		{"custom API URL", map[string]string{"GROUPIE_API_URL": "http://localhost:8080/api"}, "api.HTTPProvider", false},
+		{"snapshot", map[string]string{"GROUPIE_PROVIDER": "snapshot"}, "*api.SnapshotProvider", false},
+		{"missing snapshot", map[string]string{"GROUPIE_PROVIDER": "snapshot", "GROUPIE_SNAPSHOT": filepath.Join(dir, "none.json")}, "", true},
+		{"memory", map[string]string{"GROUPIE_PROVIDER": "memory"}, "*api.MemoryProvider", false},
+		{"unknown", map[string]string{"GROUPIE_PROVIDER": "ftp"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GROUPIE_PROVIDER", "")
+			t.Setenv("GROUPIE_API_URL", "")
+			t.Setenv("GROUPIE_SNAPSHOT", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			p, err := ProviderFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProviderFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && fmt.Sprintf("%T", p) != tt.wantType {
+				t.Errorf("ProviderFromEnv() = %s, want %s", fmt.Sprintf("%T", p), tt.wantType)
+			}
+		})
+	}
+
+	t.Setenv("GROUPIE_PROVIDER", "")
+	t.Setenv("GROUPIE_API_URL", "http://localhost:8080/api")
+	if p, _ := ProviderFromEnv(); p.(HTTPProvider).BaseURL != "http://localhost:8080/api/" {
+		t.Errorf("BaseURL = %q, want a trailing slash", p.(HTTPProvider).BaseURL)
+	}
+}
+
+// useProvider swaps the data source for the test.
+func useProvider(t *testing.T, p Provider) {
+	t.Helper()
+	previous := provider
+	provider = p
+	t.Cleanup(func() { provider = previous })
+}
+
+func TestArtistHandlerUsesProvider(t *testing.T) {
+	useProvider(t, NewMemoryProvider(fixtureArtists, fixtureRelations))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/artist/2?format=json", http.StatusOK, `"name": "SOJA"`},
+		{"/artist/99?format=json", http.StatusNotFound, "Can't find"},
+		{"/compare?ids=1,3&format=json", http.StatusOK, "Pink Floyd"},
+		{"/compare?ids=1,99", http.StatusNotFound, "Can't find"},
+		{"/artist/2/timeline?format=json", http.StatusOK, `"kind": "concert"`},
+		{"/artist/99/timeline", http.StatusNotFound, "Can't find"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Routes().ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s = %d %s", tt.path, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// downProvider fails every call, like an unreachable upstream API.
+type downProvider struct{}
+
+var errUpstreamDown = errors.New("upstream down")
+
+func (downProvider) ListArtists() ([]Artist, error)     { return nil, errUpstreamDown }
+func (downProvider) GetArtist(int) (Artist, error)      { return Artist{}, errUpstreamDown }
+func (downProvider) ListRelations() ([]Relation, error) { return nil, errUpstreamDown }
+func (downProvider) GetConcerts(int) ([]Concert, error) { return nil, errUpstreamDown }
+
+func TestProviderErrorsAreBadGateway(t *testing.T) {
+	useProvider(t, downProvider{})
+	useTempDataDir(t)
+	previous := currentDataset()
+	setDataset(nil)
+	t.Cleanup(func() { setDataset(previous) })
+
+	for _, path := range []string{"/artists", "/artist/1", "/artist/1/timeline", "/compare?ids=1,2", "/img/1"} {
+		w := httptest.NewRecorder()
+		Routes().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, http.StatusBadGateway)
+		}
+	}
+}
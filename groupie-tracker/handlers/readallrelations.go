@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+/*
+ReadAllRelations fetches the relation of every artist from the API.
+It takes the url of the relation index and returns a slice of Relation structs.
+The API wraps the list in an "index" field, which is unwrapped here.
+If successful, it returns the Relations. Otherwise, it returns an error.
+*/
+func ReadAllRelations(url string) ([]Relation, error) {
+	response, err := upstreamGet(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var data struct {
+		Index []Relation `json:"index"`
+	}
+	if response.StatusCode == http.StatusOK {
+		err = json.NewDecoder(response.Body).Decode(&data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("API returned status code: %d", response.StatusCode)
+	}
+	return data.Index, nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadAllRelations(t *testing.T) {
+	// Create a mock server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/relation" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"index":[{"id":1,"datesLocations":{"london-uk":["20-11-2019"]}},{"id":2,"datesLocations":{"paris-france":["01-01-2020","02-01-2020"]}}]}`))
+	}))
+	defer server.Close()
+
+	relations, err := ReadAllRelations(server.URL + "/relation")
+	if err != nil {
+		t.Fatalf("ReadAllRelations() returned an error: %v", err)
+	}
+	if len(relations) != 2 {
+		t.Fatalf("Expected 2 relations, got %d", len(relations))
+	}
+	if relations[1].ID != 2 || len(relations[1].Locations["paris-france"]) != 2 {
+		t.Errorf("Second relation does not match expected values: %+v", relations[1])
+	}
+
+	if _, err := ReadAllRelations(server.URL + "/missing"); err == nil {
+		t.Error("ReadAllRelations() expected an error for a missing index")
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import "time"
+
+// ArtistDetail is everything the artist page shows: the artist itself,
+// its concerts in date order, the history built from them and the
+// travel analytics of the tour.
+type ArtistDetail struct {
+	Artist   Artist         `json:"artist"`
+	Concerts []Concert      `json:"concerts"`
+	History  ConcertHistory `json:"history"`
+	Tour     TourStats      `json:"tour"`
+}
+
+/*
+readArtistDetail builds the detail of an artist from d, so that it matches
+the validator of d. It returns ErrNotFound for an artist d does not have.
+*/
+func readArtistDetail(d *Dataset, id int) (ArtistDetail, error) {
+	artist, ok := d.Artist(id)
+	if !ok {
+		return ArtistDetail{}, ErrNotFound
+	}
+	concerts := d.Concerts(id)
+	return ArtistDetail{
+		Artist:   artist,
+		Concerts: concerts,
+		History:  historyOf(concerts, time.Now()),
+		Tour:     ComputeTourStats(concerts),
+	}, nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadArtistDetail(t *testing.T) {
+	d := newTestDataset()
+
+	tests := []struct {
+		name      string
+		id        int
+		wantName  string
+		wantTotal int
+		wantErr   error
+	}{
+		{"Valid artist", 1, "Queen", 2, nil},
+		{"Missing artist", 999, "", 0, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readArtistDetail(d, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readArtistDetail() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Artist.Name != tt.wantName {
+				t.Errorf("readArtistDetail() got Name = %q, want %q", got.Artist.Name, tt.wantName)
+			}
+			if got.History.Total != tt.wantTotal {
+				t.Errorf("readArtistDetail() got %d concerts, want %d", got.History.Total, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -13,7 +13,7 @@ The function sends a GET request to the specified URL and decodes the JSON respo
 If successful, it returns the slice of Artists. Otherwise, it returns an error.
 */
 func ReadArtists(url string) ([]Artist, error) {
-	response, err := http.Get(url)
+	response, err := upstreamGet(url)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RefreshInterval is how often the background refresher fetches the dataset.
+const RefreshInterval = datasetTTL
+
+var datasetCache struct {
+	sync.Mutex
+	data *Dataset
+}
+
+// refreshMu makes sure only one refresh talks to the API at a time.
+var refreshMu sync.Mutex
+
+// currentDataset returns the dataset in memory, or nil before the first fetch.
+func currentDataset() *Dataset {
+	datasetCache.Lock()
+	defer datasetCache.Unlock()
+	return datasetCache.data
+}
+
+func setDataset(d *Dataset) {
+	datasetCache.Lock()
+	datasetCache.data = d
+	datasetCache.Unlock()
+}
+
+func fresh(d *Dataset) bool {
+	return d != nil && time.Since(d.FetchedAt) < datasetTTL
+}
+
+/*
+loadDataset returns the cached dataset, refreshing it from the API when the
+cache is empty or older than datasetTTL. If the refresh fails but an older
+dataset is available, the older one is served instead of an error.
+*/
+func loadDataset() (*Dataset, error) {
+	if d := currentDataset(); fresh(d) {
+		return d, nil
+	}
+
+	refreshMu.Lock()
+	if d := seedDatasetLocked(); fresh(d) {
+		// another request refreshed while we waited, or the stored
+		// dataset from before a restart is recent enough
+		refreshMu.Unlock()
+		return d, nil
+	}
+	d, _, err := refreshLocked()
+	refreshMu.Unlock()
+
+	if err != nil {
+		if stale := currentDataset(); stale != nil {
+			log.Printf("Serving stale dataset after refresh error: %v", err)
+			return stale, nil
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+/*
+RefreshDataset fetches the dataset from the provider, compares it with the
+previous snapshot (in memory, or on disk after a restart), records any
+changes in the change log, notifies webhook subscribers in the background
+and keeps the new dataset as the snapshot.
+*/
+func RefreshDataset() (*Dataset, ChangeSet, error) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	return refreshLocked()
+}
+
+/*
+seedDatasetLocked fills an empty cache with the latest dataset from the
+store, falling back to the snapshot file, and returns the cached dataset.
+The caller holds refreshMu.
+*/
+func seedDatasetLocked() *Dataset {
+	if d := currentDataset(); d != nil {
+		return d
+	}
+	d := storedDataset()
+	if d == nil {
+		d = readSnapshot()
+	}
+	if d != nil {
+		setDataset(d)
+	}
+	return d
+}
+
+// storedDataset returns the latest dataset in the store, or nil.
+func storedDataset() *Dataset {
+	s, err := datasetStore()
+	if err != nil {
+		log.Printf("Error opening the dataset store: %v", err)
+		return nil
+	}
+	return s.Latest()
+}
+
+func refreshLocked() (*Dataset, ChangeSet, error) {
+	previous := currentDataset()
+	if previous == nil {
+		if previous = storedDataset(); previous == nil {
+			previous = readSnapshot()
+		}
+	}
+
+	next, err := fetchDataset(provider)
+	recordRefresh(err)
+	if err != nil {
+		events.Publish(StreamRefreshFailed, RefreshError{At: time.Now(), Error: err.Error()})
+		return nil, ChangeSet{}, err
+	}
+
+	changes := DiffDatasets(previous, next)
+	setDataset(next)
+
+	if !changes.Empty() {
+		if err := appendChangeLog(changes); err != nil {
+			log.Printf("Error writing the change log: %v", err)
+		}
+		dispatchWebhooks(changes)
+		publishChanges(changes)
+	}
+	if s, err := datasetStore(); err != nil {
+		log.Printf("Error opening the dataset store: %v", err)
+	} else if _, err := s.Put(next); err != nil {
+		log.Printf("Error storing the dataset: %v", err)
+	}
+	if err := writeSnapshot(next); err != nil {
+		log.Printf("Error writing the dataset snapshot: %v", err)
+	}
+	return next, changes, nil
+}
+
+// maxRefreshErrors is how many failed refreshes RefreshStatus remembers.
+const maxRefreshErrors = 20
+
+// RefreshError is one failed refresh.
+type RefreshError struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
+
+// RefreshStatus summarizes the refreshes since the server started.
+type RefreshStatus struct {
+	LastAttempt time.Time      `json:"lastAttempt"`
+	LastSuccess time.Time      `json:"lastSuccess"`
+	Errors      []RefreshError `json:"errors"` // newest first
+}
+
+var refreshStatus struct {
+	sync.Mutex
+	status RefreshStatus
+}
+
+func recordRefresh(err error) {
+	refreshStatus.Lock()
+	defer refreshStatus.Unlock()
+	now := time.Now()
+	s := &refreshStatus.status
+	s.LastAttempt = now
+	if err == nil {
+		s.LastSuccess = now
+		return
+	}
+	s.Errors = append([]RefreshError{{At: now, Error: err.Error()}}, s.Errors...)
+	if len(s.Errors) > maxRefreshErrors {
+		s.Errors = s.Errors[:maxRefreshErrors]
+	}
+}
+
+// currentRefreshStatus returns a copy of the refresh status.
+func currentRefreshStatus() RefreshStatus {
+	refreshStatus.Lock()
+	defer refreshStatus.Unlock()
+	s := refreshStatus.status
+	s.Errors = append([]RefreshError(nil), s.Errors...)
+	return s
+}
+
+/*
+StartRefresher refreshes the dataset every interval in the background.
+The first refresh happens immediately, unless the dataset stored before a
+restart is younger than interval; then it waits until that one is due.
+Calling the returned function stops it.
+*/
+func StartRefresher(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		refreshMu.Lock()
+		d := seedDatasetLocked()
+		refreshMu.Unlock()
+
+		var wait time.Duration
+		if d != nil {
+			wait = time.Until(d.FetchedAt.Add(interval))
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+			case <-done:
+				return
+			}
+			if _, _, err := RefreshDataset(); err != nil {
+				log.Printf("Error refreshing the dataset: %v", err)
+			}
+			timer.Reset(interval)
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// snapshot is the on-disk form of a Dataset.
+type snapshot struct {
+	FetchedAt time.Time  `json:"fetchedAt"`
+	Artists   []Artist   `json:"artists"`
+	Relations []Relation `json:"relations"`
+}
+
+func snapshotPath() string {
+	return filepath.Join(dataDir, "snapshot.json")
+}
+
+// readSnapshot loads the last saved dataset, or nil if there is none.
+func readSnapshot() *Dataset {
+	var s snapshot
+	if err := readJSONFile(snapshotPath(), &s); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading the dataset snapshot: %v", err)
+		}
+		return nil
+	}
+	return NewDataset(s.Artists, s.Relations, s.FetchedAt)
+}
+
+func writeSnapshot(d *Dataset) error {
+	s := snapshot{FetchedAt: d.FetchedAt, Artists: d.Artists}
+	for _, a := range d.Artists {
+		if rel, ok := d.Relations[a.ID]; ok {
+			s.Relations = append(s.Relations, rel)
+		}
+	}
+	return writeJSONFile(snapshotPath(), s)
+}
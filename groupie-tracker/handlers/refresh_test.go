@@ -0,0 +1,162 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI is a mock upstream whose relation for artist 1 can be changed.
+type fakeAPI struct {
+	mu       sync.Mutex
+	relation string
+	fail     bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Path {
+	case "/artists":
+		w.Write([]byte(`[{"id":1,"name":"Queen","members":["Freddie Mercury"]}]`))
+	case "/relation":
+		w.Write([]byte(`{"index":[{"id":1,"datesLocations":` + f.relation + `}]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeAPI) set(relation string, fail bool) {
+	f.mu.Lock()
+	f.relation, f.fail = relation, fail
+	f.mu.Unlock()
+}
+
+// useFakeAPI points the application at a fake upstream with empty caches.
+func useFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+	useTempDataDir(t)
+	api := &fakeAPI{relation: `{"london-uk":["20-11-2019"]}`}
+	server := httptest.NewServer(api)
+
+	previousProvider, previousData := provider, currentDataset()
+	provider = HTTPProvider{BaseURL: server.URL + "/"}
+	setDataset(nil)
+	t.Cleanup(func() {
+		server.Close()
+		provider = previousProvider
+		setDataset(previousData)
+	})
+	return api
+}
+
+func TestRefreshDataset(t *testing.T) {
+	api := useFakeAPI(t)
+
+	_, changes, err := RefreshDataset()
+	if err != nil {
+		t.Fatalf("RefreshDataset() returned an error: %v", err)
+	}
+	if !changes.Empty() {
+		t.Errorf("expected no changes on the first refresh, got %+v", changes)
+	}
+
+	api.set(`{"london-uk":["20-11-2019"],"paris-france":["01-01-2020"]}`, false)
+	_, changes, err = RefreshDataset()
+	if err != nil {
+		t.Fatalf("RefreshDataset() returned an error: %v", err)
+	}
+	if len(changes.ConcertsAdded) != 1 {
+		t.Errorf("expected one added concert, got %+v", changes)
+	}
+
+	// After a restart the snapshot on disk is the previous dataset.
+	setDataset(nil)
+	api.set(`{"paris-france":["01-01-2020"]}`, false)
+	_, changes, err = RefreshDataset()
+	if err != nil {
+		t.Fatalf("RefreshDataset() returned an error: %v", err)
+	}
+	if len(changes.ConcertsCancelled) != 1 || changes.ConcertsCancelled[0].Concert.Location != "london-uk" {
+		t.Errorf("expected London to be cancelled, got %+v", changes)
+	}
+
+	entries, _ := readChangeLog()
+	if len(entries) != 2 {
+		t.Errorf("expected 2 change sets in the log, got %d", len(entries))
+	}
+}
+
+func TestLoadDatasetServesStaleOnError(t *testing.T) {
+	api := useFakeAPI(t)
+
+	d, err := loadDataset()
+	if err != nil || len(d.Artists) != 1 {
+		t.Fatalf("loadDataset() = %v, %v", d, err)
+	}
+
+	// Make the cached dataset stale and the API fail.
+	d.FetchedAt = d.FetchedAt.Add(-2 * datasetTTL)
+	api.set("", true)
+
+	stale, err := loadDataset()
+	if err != nil || stale != d {
+		t.Errorf("expected the stale dataset to be served, got %v, %v", stale, err)
+	}
+
+	// After a restart the stored dataset is served.
+	setDataset(nil)
+	stored, err := loadDataset()
+	if err != nil || len(stored.Artists) != 1 {
+		t.Errorf("expected the stored dataset to be served, got %v, %v", stored, err)
+	}
+
+	setDataset(nil)
+	useTempDataDir(t)
+	if _, err := loadDataset(); err == nil {
+		t.Error("expected an error without any dataset to fall back on")
+	}
+}
+
+// useUpstreamTimeout shortens the timeout of upstream requests.
+func useUpstreamTimeout(t *testing.T, timeout time.Duration) {
+	previous := upstreamClient
+	upstreamClient = &http.Client{Timeout: timeout}
+	t.Cleanup(func() { upstreamClient = previous })
+}
+
+// useHungServer starts a server that never answers until the test ends.
+func useHungServer(t *testing.T) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+func TestRefreshTimesOut(t *testing.T) {
+	useFakeAPI(t)
+	useUpstreamTimeout(t, 50*time.Millisecond)
+	provider = HTTPProvider{BaseURL: useHungServer(t).URL + "/"}
+
+	start := time.Now()
+	if _, _, err := RefreshDataset(); err == nil {
+		t.Error("expected an error from a hung upstream")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the refresh to give up after the timeout; took %v", elapsed)
+	}
+}
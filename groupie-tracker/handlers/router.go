@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Router is a small request router with typed path parameters.
+Routes are declared with a method and a pattern, for example:
+
+	GET /artist/{id:int}
+	GET /static/{path...}
+
+A segment in braces captures a path parameter. The ":int" suffix requires a
+positive integer and the "..." suffix captures the rest of the path.
+Unknown paths get a 404, known paths with the wrong method get a 405 with an
+Allow header, and malformed typed parameters get a 400 before the handler runs.
+*/
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	method   string
+	segments []segment
+	handler  http.Handler
+}
+
+type segment struct {
+	literal string
+	param   string
+	isInt   bool
+	rest    bool
+}
+
+type paramsKey struct{}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+/*
+Handle registers handler for pattern, which must be "METHOD /path".
+It panics on a malformed pattern, like http.ServeMux does.
+*/
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		panic("router: invalid pattern " + strconv.Quote(pattern))
+	}
+
+	var segments []segment
+	parts := splitPath(path)
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			segments = append(segments, segment{literal: part})
+			continue
+		}
+		name := part[1 : len(part)-1]
+		seg := segment{}
+		switch {
+		case strings.HasSuffix(name, "..."):
+			if i != len(parts)-1 {
+				panic("router: {name...} must be the last segment in " + strconv.Quote(pattern))
+			}
+			seg.rest = true
+			name = strings.TrimSuffix(name, "...")
+		case strings.HasSuffix(name, ":int"):
+			seg.isInt = true
+			name = strings.TrimSuffix(name, ":int")
+		}
+		if name == "" {
+			panic("router: empty parameter name in " + strconv.Quote(pattern))
+		}
+		seg.param = name
+		segments = append(segments, seg)
+	}
+
+	rt.routes = append(rt.routes, route{method: method, segments: segments, handler: handler})
+}
+
+// HandleFunc registers a handler function for pattern.
+func (rt *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// ServeHTTP dispatches the request to the first matching route.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(r.URL.Path)
+
+	var allowed []string
+	badParam := ""
+	for _, rte := range rt.routes {
+		params, ok, bad := rte.match(parts)
+		if !ok {
+			if bad != "" && badParam == "" {
+				badParam = bad
+			}
+			continue
+		}
+		if rte.method != r.Method && !(rte.method == http.MethodGet && r.Method == http.MethodHead) {
+			allowed = append(allowed, rte.method)
+			if rte.method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+			continue
+		}
+		if len(params) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+		}
+		rte.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(uniqueSorted(allowed), ", "))
+		renderError(w, r, http.StatusMethodNotAllowed, "Wrong method")
+		return
+	}
+	if badParam != "" {
+		renderError(w, r, http.StatusBadRequest, "Invalid "+badParam)
+		return
+	}
+	renderError(w, r, http.StatusNotFound, "Oops! We Can't find that page")
+}
+
+/*
+match reports whether the path parts fit the route and returns the captured
+parameters. When the shape fits but a typed parameter is malformed, it
+returns the name of that parameter as bad.
+*/
+func (rte route) match(parts []string) (params map[string]string, ok bool, bad string) {
+	for i, seg := range rte.segments {
+		if seg.rest {
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[seg.param] = strings.Join(parts[i:], "/")
+			return params, bad == "", bad
+		}
+		if i >= len(parts) {
+			return nil, false, ""
+		}
+		if seg.param == "" {
+			if seg.literal != parts[i] {
+				return nil, false, ""
+			}
+			continue
+		}
+		if seg.isInt && !isPositiveInt(parts[i]) {
+			if bad == "" {
+				bad = seg.param
+			}
+		}
+		if params == nil {
+			params = map[string]string{}
+		}
+		params[seg.param] = parts[i]
+	}
+	if len(parts) != len(rte.segments) {
+		return nil, false, ""
+	}
+	if bad != "" {
+		return nil, false, bad
+	}
+	return params, true, ""
+}
+
+// PathParam returns the value of the named path parameter, or "".
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+/*
+PathInt returns the named path parameter as an int.
+Routes declare such parameters as {name:int}, so the router has already
+rejected non-numeric values; 0 is returned if the parameter is missing.
+*/
+func PathInt(r *http.Request, name string) int {
+	n, _ := strconv.Atoi(PathParam(r, name))
+	return n
+}
+
+// splitPath turns "/a/b/" into ["a", "b"]; the root path has no parts.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func isPositiveInt(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && strconv.Itoa(n) == s
+}
+
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	out := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("home"))
+	})
+	rt.HandleFunc("GET /artist/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("artist " + PathParam(r, "id")))
+	})
+	rt.HandleFunc("DELETE /artist/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("deleted"))
+	})
+	rt.HandleFunc("GET /files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + PathParam(r, "path")))
+	})
+
+	tests := []struct {
+		method        string
+		url           string
+		expectedCode  int
+		expectedBody  string
+		expectedAllow string
+	}{
+		{"GET", "/", http.StatusOK, "home", ""},
+		{"GET", "/artist/3", http.StatusOK, "artist 3", ""},
+		{"GET", "/artist/3/", http.StatusOK, "artist 3", ""},
+		{"HEAD", "/artist/3", http.StatusOK, "", ""},
+		{"DELETE", "/artist/3", http.StatusOK, "deleted", ""},
+		{"POST", "/artist/3", http.StatusMethodNotAllowed, "Wrong method", "DELETE, GET, HEAD"},
+		{"POST", "/", http.StatusMethodNotAllowed, "Wrong method", "GET, HEAD"},
+		{"GET", "/artist/abc", http.StatusBadRequest, "Invalid id", ""},
+		{"GET", "/artist/0", http.StatusBadRequest, "Invalid id", ""},
+		{"GET", "/artist/007", http.StatusBadRequest, "Invalid id", ""},
+		{"GET", "/artist/", http.StatusNotFound, "Oops!", ""},
+		{"GET", "/artist/3/extra", http.StatusNotFound, "Oops!", ""},
+		{"GET", "/files/css/a.css", http.StatusOK, "file css/a.css", ""},
+		{"GET", "/wrongpath", http.StatusNotFound, "Oops!", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.url, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.url, nil)
+			w := httptest.NewRecorder()
+
+			rt.ServeHTTP(w, req)
+
+			if w.Code != test.expectedCode {
+				t.Errorf("expected status code %d, got %d", test.expectedCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), test.expectedBody) {
+				t.Errorf("expected body to contain %q, got %q", test.expectedBody, w.Body.String())
+			}
+			if got := w.Header().Get("Allow"); got != test.expectedAllow {
+				t.Errorf("expected Allow %q, got %q", test.expectedAllow, got)
+			}
+		})
+	}
+}
+
+func TestRouterInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"/no-method", "GET no-slash", "GET /{rest...}/x", "GET /{}"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Handle(%q) did not panic", pattern)
+				}
+			}()
+			NewRouter().HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+		}()
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import "net/http"
+
+/*
+Routes builds the application's router with every page and the static files.
+Legacy concert pages are kept as redirects to the artist page.
+Every request is tagged with a request ID.
+*/
+func Routes() http.Handler {
+	rt := NewRouter()
+	rt.HandleFunc("GET /", HomeHandler)
+	rt.HandleFunc("GET /artists", ArtistsHandler)
+	rt.HandleFunc("GET /artists/export", ExportHandler)
+	rt.HandleFunc("GET /artist/{id:int}", ArtistHandler)
+	rt.HandleFunc("GET /artist/{id:int}/timeline", TimelineHandler)
+	rt.HandleFunc("GET /compare", CompareHandler)
+	rt.HandleFunc("GET /insights/overlaps", OverlapsHandler)
+	rt.HandleFunc("GET /stats", StatsHandler)
+	rt.HandleFunc("GET /feeds/upcoming.rss", RSSHandler)
+	rt.HandleFunc("GET /feeds/upcoming.atom", AtomHandler)
+	rt.HandleFunc("GET /changes", ChangesHandler)
+	rt.HandleFunc("GET /events", EventsHandler)
+	rt.HandleFunc("GET /img/{id:int}", ImageHandler)
+	rt.HandleFunc("GET /graphql", GraphQLHandler)
+	rt.HandleFunc("POST /graphql", GraphQLHandler)
+	rt.HandleFunc("GET /graphql/schema", GraphQLSchemaHandler)
+	rt.HandleFunc("GET /favorites", FavoritesHandler)
+	rt.HandleFunc("POST /favorites/{id:int}", AddFavoriteHandler)
+	rt.HandleFunc("DELETE /favorites/{id:int}", RemoveFavoriteHandler)
+	rt.HandleFunc("POST /favorites/{id:int}/delete", RemoveFavoriteHandler)
+	rt.HandleFunc("GET /register", RegisterPageHandler)
+	rt.HandleFunc("POST /register", RegisterHandler)
+	rt.HandleFunc("GET /login", LoginPageHandler)
+	rt.HandleFunc("POST /login", LoginHandler)
+	rt.HandleFunc("POST /logout", LogoutHandler)
+	rt.HandleFunc("GET /account", AccountHandler)
+	rt.HandleFunc("POST /account/digest", DigestSettingsHandler)
+	rt.HandleFunc("POST /account/searches", SaveSearchHandler)
+	rt.HandleFunc("POST /account/searches/{id:int}/delete", DeleteSearchHandler)
+	rt.HandleFunc("GET /admin", requireAdmin(AdminHandler))
+	rt.HandleFunc("POST /admin/refresh", requireAdmin(AdminRefreshHandler))
+	rt.HandleFunc("GET /admin/requests", requireAdmin(AdminRequestsHandler))
+	rt.HandleFunc("GET /admin/overrides/{id:int}", requireAdmin(AdminOverrideHandler))
+	rt.HandleFunc("POST /admin/overrides/{id:int}", requireAdmin(AdminSaveOverrideHandler))
+	rt.HandleFunc("GET /locations/{id:int}", LocationHandler)
+	rt.HandleFunc("GET /dates/{id:int}", DateHandler)
+	rt.HandleFunc("GET /relation/{id:int}", RelationHandler)
+	rt.Handle("GET /static/{path...}", withStaticCaching(http.HandlerFunc(StaticHandler)))
+	return withRequestID(withCompression(withAsOf(rt)))
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie = "groupie_session"
+	sessionMaxAge = 365 * 24 * time.Hour
+	csrfField     = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+var (
+	sessionKeyMu sync.Mutex
+	sessionKey   []byte // loaded by sessionSecret; tests may set it directly
+)
+
+/*
+sessionSecret returns the key used to sign session cookies and CSRF tokens.
+It comes from the GROUPIE_SESSION_SECRET environment variable, or from
+data/session.key, which is generated on first use so cookies survive a
+restart.
+*/
+func sessionSecret() []byte {
+	sessionKeyMu.Lock()
+	defer sessionKeyMu.Unlock()
+	if sessionKey != nil {
+		return sessionKey
+	}
+	if env := os.Getenv("GROUPIE_SESSION_SECRET"); env != "" {
+		sessionKey = []byte(env)
+		return sessionKey
+	}
+
+	path := filepath.Join(dataDir, "session.key")
+	if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
+		sessionKey = b
+		return sessionKey
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	if err := os.MkdirAll(dataDir, 0o755); err == nil {
+		os.WriteFile(path, key, 0o600)
+	}
+	sessionKey = key
+	return sessionKey
+}
+
+func mac(parts ...string) []byte {
+	m := hmac.New(sha256.New, sessionSecret())
+	m.Write([]byte(strings.Join(parts, "\x00")))
+	return m.Sum(nil)
+}
+
+// signValue appends an HMAC of value so it can be checked by verifySigned.
+func signValue(value string) string {
+	return value + "." + base64.RawURLEncoding.EncodeToString(mac("cookie", value))
+}
+
+// verifySigned returns the value of a string made by signValue, or false
+// when the signature does not match.
+func verifySigned(signed string) (string, bool) {
+	value, sig, ok := strings.Cut(signed, ".")
+	if !ok || value == "" {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac("cookie", value)) {
+		return "", false
+	}
+	return value, true
+}
+
+// sessionID returns the ID in a valid session cookie, or "".
+func sessionID(r *http.Request) string {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	id, ok := verifySigned(c.Value)
+	if !ok || !validRequestID(id) {
+		return ""
+	}
+	return id
+}
+
+/*
+ensureSession returns the visitor's session ID, starting an anonymous
+session with a new signed cookie when there is none. Sessions are only
+written to disk once they hold something, such as a favorite.
+*/
+func ensureSession(w http.ResponseWriter, r *http.Request) string {
+	if id := sessionID(r); id != "" {
+		return id
+	}
+	return newSession(w, r)
+}
+
+// newSession starts a new session, replacing any cookie the visitor has.
+func newSession(w http.ResponseWriter, r *http.Request) string {
+	id := newRequestID() + newRequestID()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    signValue(id),
+		Path:     "/",
+		MaxAge:   int(sessionMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// csrfToken is the token forms of session id must send back.
+func csrfToken(id string) string {
+	if id == "" {
+		return ""
+	}
+	return hex.EncodeToString(mac("csrf", id))
+}
+
+/*
+checkCSRF reports whether a mutating request carries the CSRF token of its
+session, either in the X-CSRF-Token header or in the csrf_token form field.
+*/
+func checkCSRF(r *http.Request, id string) bool {
+	if id == "" {
+		return false
+	}
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	return token != "" && hmac.Equal([]byte(token), []byte(csrfToken(id)))
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// useTestSessionKey fixes the key that signs cookies and CSRF tokens.
+func useTestSessionKey(t *testing.T) {
+	t.Helper()
+	sessionKeyMu.Lock()
+	previous := sessionKey
+	sessionKey = []byte("test-session-key")
+	sessionKeyMu.Unlock()
+	t.Cleanup(func() {
+		sessionKeyMu.Lock()
+		sessionKey = previous
+		sessionKeyMu.Unlock()
+	})
+}
+
+func TestSignedValues(t *testing.T) {
+	useTestSessionKey(t)
+	signed := signValue("abc123")
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"valid", signed, "abc123", true},
+		{"tampered value", "abc124" + signed[len("abc123"):], "", false},
+		{"tampered signature", signed + "x", "", false},
+		{"unsigned", "abc123", "", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := verifySigned(tt.input)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("verifySigned(%q) = %q, %v; want %q, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEnsureSession(t *testing.T) {
+	useTestSessionKey(t)
+
+	w := httptest.NewRecorder()
+	id := ensureSession(w, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if id == "" || len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("ensureSession() = %q with cookies %v", id, cookies)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	if got := ensureSession(w, r); got != id {
+		t.Errorf("ensureSession() with a cookie = %q, want %q", got, id)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("expected no new cookie for an existing session")
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	useTestSessionKey(t)
+	token := csrfToken("session1")
+
+	header := httptest.NewRequest("POST", "/", nil)
+	header.Header.Set(csrfHeader, token)
+
+	form := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{csrfField: {token}}.Encode()))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	other := httptest.NewRequest("POST", "/", nil)
+	other.Header.Set(csrfHeader, csrfToken("session2"))
+
+	tests := []struct {
+		name string
+		r    *http.Request
+		id   string
+		want bool
+	}{
+		{"header token", header, "session1", true},
+		{"form token", form, "session1", true},
+		{"token of another session", other, "session1", false},
+		{"no token", httptest.NewRequest("POST", "/", nil), "session1", false},
+		{"no session", header, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkCSRF(tt.r, tt.id); got != tt.want {
+				t.Errorf("checkCSRF() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
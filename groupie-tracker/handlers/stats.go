@@ -0,0 +1,173 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// topN is how many entries the "busiest" and "most toured" rankings keep.
+const topN = 10
+
+// Stats are catalogue-wide figures computed from all artists and relations.
+type Stats struct {
+	Artists            int     `json:"artists"`
+	Concerts           int     `json:"concerts"`
+	AverageBandSize    float64 `json:"averageBandSize"`
+	ConcertsPerYear    []Count `json:"concertsPerYear"`
+	ConcertsPerCountry []Count `json:"concertsPerCountry"`
+	BusiestCities      []Count `json:"busiestCities"`
+	CreationYears      []Count `json:"creationYears"`
+	FirstAlbumYears    []Count `json:"firstAlbumYears"`
+	MostToured         []Count `json:"mostToured"`
+}
+
+// Chart is a titled SVG chart ready to be written into a page. SVG is
+// trusted markup: barChartSVG escapes the text it draws.
+type Chart struct {
+	Title string
+	SVG   template.HTML
+}
+
+// StatsPage is the data passed to the stats template.
+type StatsPage struct {
+	Stats  Stats
+	Charts []Chart
+}
+
+/*
+ComputeStats builds the statistics for the whole dataset.
+Years are bucketed per year; creation and first album years per decade.
+Rankings are sorted by count, ties broken alphabetically.
+*/
+func ComputeStats(d *Dataset) Stats {
+	perYear := map[string]int{}
+	perCountry := map[string]int{}
+	perCity := map[string]int{}
+	creation := map[string]int{}
+	firstAlbum := map[string]int{}
+	toured := map[string]int{}
+
+	members, concerts := 0, 0
+	for _, artist := range d.Artists {
+		members += len(artist.Members)
+		if artist.CreationDate > 0 {
+			creation[decade(artist.CreationDate)]++
+		}
+		if date, err := ParseConcertDate(artist.FirstAlbum); err == nil {
+			firstAlbum[decade(date.Year())]++
+		}
+
+		artistConcerts := d.Concerts(artist.ID)
+		concerts += len(artistConcerts)
+		toured[artist.Name] = len(artistConcerts)
+		for _, c := range artistConcerts {
+			perYear[strconv.Itoa(c.Date.Year())]++
+			perCountry[c.Country]++
+			perCity[c.City+", "+c.Country]++
+		}
+	}
+
+	stats := Stats{
+		Artists:            len(d.Artists),
+		Concerts:           concerts,
+		ConcertsPerYear:    countsByLabel(perYear),
+		ConcertsPerCountry: countsByValue(perCountry, 0),
+		BusiestCities:      countsByValue(perCity, topN),
+		CreationYears:      countsByLabel(creation),
+		FirstAlbumYears:    countsByLabel(firstAlbum),
+		MostToured:         countsByValue(toured, topN),
+	}
+	if len(d.Artists) > 0 {
+		stats.AverageBandSize = math.Round(float64(members)/float64(len(d.Artists))*100) / 100
+	}
+	return stats
+}
+
+// Charts returns the SVG charts shown on the stats page.
+func (s Stats) Charts() []Chart {
+	return []Chart{
+		newChart("Concerts per year", s.ConcertsPerYear),
+		newChart("Concerts per country", s.ConcertsPerCountry),
+		newChart("Busiest cities", s.BusiestCities),
+		newChart("Most toured artists", s.MostToured),
+		newChart("Bands formed per decade", s.CreationYears),
+		newChart("First albums per decade", s.FirstAlbumYears),
+	}
+}
+
+func newChart(title string, counts []Count) Chart {
+	return Chart{Title: title, SVG: template.HTML(barChartSVG(title, counts))}
+}
+
+func decade(year int) string {
+	return fmt.Sprintf("%ds", year/10*10)
+}
+
+// countsByLabel returns the counts sorted by label.
+func countsByLabel(m map[string]int) []Count {
+	counts := make([]Count, 0, len(m))
+	for label, value := range m {
+		counts = append(counts, Count{Label: label, Value: value})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Label < counts[j].Label })
+	return counts
+}
+
+// countsByValue returns the counts largest first, keeping at most limit
+// entries when limit is positive.
+func countsByValue(m map[string]int, limit int) []Count {
+	counts := make([]Count, 0, len(m))
+	for label, value := range m {
+		counts = append(counts, Count{Label: label, Value: value})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Value != counts[j].Value {
+			return counts[i].Value > counts[j].Value
+		}
+		return counts[i].Label < counts[j].Label
+	})
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+/*
+StatsHandler renders the statistics dashboard with server-side SVG charts,
+or the raw statistics as JSON for ?format=json or a JSON Accept header.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artists")
+		return
+	}
+	if dataNotModified(w, r, datasetValidator(data), "stats") {
+		return
+	}
+
+	stats := ComputeStats(data)
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, stats)
+		return
+	}
+
+	temp, err := parseTemplate("template/stats.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+
+	err = temp.Execute(w, StatsPage{Stats: stats, Charts: stats.Charts()})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	stats := ComputeStats(newTestDataset())
+
+	if stats.Artists != 3 || stats.Concerts != 6 {
+		t.Errorf("got %d artists and %d concerts, want 3 and 6", stats.Artists, stats.Concerts)
+	}
+	if stats.AverageBandSize != 2 {
+		t.Errorf("AverageBandSize = %v, want 2", stats.AverageBandSize)
+	}
+
+	wantYears := []Count{{"2018", 1}, {"2019", 2}, {"2020", 3}}
+	if !equalCounts(stats.ConcertsPerYear, wantYears) {
+		t.Errorf("ConcertsPerYear = %v, want %v", stats.ConcertsPerYear, wantYears)
+	}
+	wantCountries := []Count{{"UK", 3}, {"Japan", 2}, {"France", 1}}
+	if !equalCounts(stats.ConcertsPerCountry, wantCountries) {
+		t.Errorf("ConcertsPerCountry = %v, want %v", stats.ConcertsPerCountry, wantCountries)
+	}
+	wantCreation := []Count{{"1960s", 2}, {"1970s", 1}}
+	if !equalCounts(stats.CreationYears, wantCreation) {
+		t.Errorf("CreationYears = %v, want %v", stats.CreationYears, wantCreation)
+	}
+	wantAlbums := []Count{{"1960s", 1}, {"1970s", 2}}
+	if !equalCounts(stats.FirstAlbumYears, wantAlbums) {
+		t.Errorf("FirstAlbumYears = %v, want %v", stats.FirstAlbumYears, wantAlbums)
+	}
+	if len(stats.BusiestCities) == 0 || stats.BusiestCities[0] != (Count{"London, UK", 3}) {
+		t.Errorf("BusiestCities = %v, want London first", stats.BusiestCities)
+	}
+	if len(stats.Charts()) != 6 {
+		t.Errorf("expected 6 charts, got %d", len(stats.Charts()))
+	}
+}
+
+func TestCountsByValueLimit(t *testing.T) {
+	got := countsByValue(map[string]int{"a": 1, "b": 3, "c": 3, "d": 2}, 3)
+	want := []Count{{"b", 3}, {"c", 3}, {"d", 2}}
+	if !equalCounts(got, want) {
+		t.Errorf("countsByValue() = %v, want %v", got, want)
+	}
+}
+
+func equalCounts(a, b []Count) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
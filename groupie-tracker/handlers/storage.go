@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// dataDir holds the files the application persists between restarts.
+// Tests point it at a temporary directory.
+var dataDir = "data"
+
+// readJSONFile decodes the JSON file at path into v.
+func readJSONFile(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
+
+// writeJSONFile replaces the file at path with v encoded as JSON.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+/*
+writeFileAtomic replaces the file at path with data.
+It writes to a temporary file in the same directory and renames it over the
+old one, so readers never see a half-written file.
+*/
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
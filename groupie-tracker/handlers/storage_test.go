@@ -0,0 +1,45 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTempDataDir points dataDir at a fresh directory for the test.
+func useTempDataDir(t *testing.T) string {
+	t.Helper()
+	previous := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() { dataDir = previous })
+	return dataDir
+}
+
+func TestWriteJSONFile(t *testing.T) {
+	dir := useTempDataDir(t)
+	path := filepath.Join(dir, "nested", "value.json")
+
+	if err := writeJSONFile(path, map[string]int{"a": 1}); err != nil {
+		t.Fatalf("writeJSONFile() returned an error: %v", err)
+	}
+	if err := writeJSONFile(path, map[string]int{"b": 2}); err != nil {
+		t.Fatalf("writeJSONFile() returned an error on overwrite: %v", err)
+	}
+
+	var got map[string]int
+	if err := readJSONFile(path, &got); err != nil {
+		t.Fatalf("readJSONFile() returned an error: %v", err)
+	}
+	if len(got) != 1 || got["b"] != 2 {
+		t.Errorf("readJSONFile() = %v, want map[b:2]", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be cleaned up, found %d entries", len(entries))
+	}
+
+	if err := readJSONFile(filepath.Join(dir, "missing.json"), &got); !os.IsNotExist(err) {
+		t.Errorf("readJSONFile() on a missing file = %v, want a not-exist error", err)
+	}
+}
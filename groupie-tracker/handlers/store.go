@@ -0,0 +1,470 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+The store keeps every version of the dataset in an append-only log file.
+Each record is framed as a 4-byte big-endian payload length, a 4-byte
+CRC-32 of the payload and the JSON payload itself. Appends are fsynced, and
+a torn or corrupt record at the end of the log (a crash mid-write) is cut
+off the next time the store is opened. Compaction rewrites the log to a
+temporary file and renames it into place.
+*/
+
+const (
+	recordVersion = "version" // a new version of the dataset
+	recordChecked = "checked" // a refresh that found the latest version unchanged
+
+	// maxCheckedRecords is how many "checked" records may pile up in the
+	// log before opening the store compacts them away.
+	maxCheckedRecords = 1000
+
+	// maxRecordSize guards against reading a garbage length as a huge record.
+	maxRecordSize = 64 << 20
+)
+
+var errCorruptRecord = errors.New("corrupt store record")
+
+// storeRecord is one entry of the log.
+type storeRecord struct {
+	Type      string     `json:"type"`
+	Version   int        `json:"version"`
+	StoredAt  time.Time  `json:"storedAt"`
+	FetchedAt time.Time  `json:"fetchedAt"`
+	Checksum  string     `json:"checksum,omitempty"`
+	Artists   []Artist   `json:"artists,omitempty"`
+	Relations []Relation `json:"relations,omitempty"`
+}
+
+// StoredVersion describes one version of the dataset kept in the store.
+type StoredVersion struct {
+	Version int `json:"version"`
+	// StoredAt is when this version was first fetched, CheckedAt the last
+	// fetch that still returned it.
+	StoredAt  time.Time `json:"storedAt"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Artists   int       `json:"artists"`
+	Checksum  string    `json:"checksum"`
+
+	offset int64
+}
+
+// Store is the file-backed history of the dataset. The latest version is
+// kept in memory, with the concert indexes of its Dataset.
+type Store struct {
+	path string
+
+	mu       sync.Mutex
+	size     int64
+	versions []StoredVersion
+	checked  int // "checked" records in the log
+	latest   *Dataset
+	stored   map[string]time.Time // Dataset.Version() -> StoredAt
+}
+
+/*
+OpenStore opens the store log at path, creating it on the first Put.
+Records after a torn or corrupt one are discarded and the file is truncated
+to the last good record.
+*/
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, stored: map[string]time.Time{}}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last *storeRecord
+	var offset int64
+	for {
+		rec, n, err := readRecord(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// torn write or corruption: keep everything before it
+			if err := f.Truncate(offset); err != nil {
+				return nil, err
+			}
+			if err := f.Sync(); err != nil {
+				return nil, err
+			}
+			break
+		}
+		s.apply(rec, offset)
+		if rec.Type == recordVersion {
+			last = &rec
+		}
+		offset += n
+	}
+	s.size = offset
+
+	if last != nil {
+		v := s.versions[len(s.versions)-1]
+		s.latest = NewDataset(last.Artists, last.Relations, v.CheckedAt)
+	}
+	if s.checked > maxCheckedRecords {
+		if err := s.compactLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// apply updates the version list with a record read from offset.
+func (s *Store) apply(rec storeRecord, offset int64) {
+	switch rec.Type {
+	case recordVersion:
+		s.versions = append(s.versions, StoredVersion{
+			Version:   rec.Version,
+			StoredAt:  rec.StoredAt,
+			CheckedAt: rec.FetchedAt,
+			Artists:   len(rec.Artists),
+			Checksum:  rec.Checksum,
+			offset:    offset,
+		})
+		if len(rec.Checksum) >= datasetVersionLength {
+			s.stored[rec.Checksum[:datasetVersionLength]] = rec.StoredAt
+		}
+	case recordChecked:
+		if n := len(s.versions); n > 0 && s.versions[n-1].Version == rec.Version {
+			s.versions[n-1].CheckedAt = rec.FetchedAt
+		}
+		s.checked++
+	}
+}
+
+// datasetChecksum identifies the content of a dataset, ignoring FetchedAt.
+func datasetChecksum(artists []Artist, relations []Relation) (string, error) {
+	b, err := json.Marshal(struct {
+		Artists   []Artist
+		Relations []Relation
+	}{artists, relations})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// datasetRelations returns the relations of d in artist order.
+func datasetRelations(d *Dataset) []Relation {
+	var relations []Relation
+	for _, a := range d.Artists {
+		if rel, ok := d.Relations[a.ID]; ok {
+			relations = append(relations, rel)
+		}
+	}
+	return relations
+}
+
+/*
+Put records d in the store. If its content matches the latest version only
+the fetch time is recorded; otherwise d becomes a new version.
+*/
+func (s *Store) Put(d *Dataset) (StoredVersion, error) {
+	relations := datasetRelations(d)
+	sum, err := datasetChecksum(d.Artists, relations)
+	if err != nil {
+		return StoredVersion{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := storeRecord{Type: recordVersion, Version: 1, StoredAt: d.FetchedAt, FetchedAt: d.FetchedAt, Checksum: sum}
+	if n := len(s.versions); n > 0 {
+		latest := s.versions[n-1]
+		if latest.Checksum == sum {
+			rec = storeRecord{Type: recordChecked, Version: latest.Version, FetchedAt: d.FetchedAt}
+		} else {
+			rec.Version = latest.Version + 1
+		}
+	}
+	if rec.Type == recordVersion {
+		rec.Artists, rec.Relations = d.Artists, relations
+	}
+
+	offset := s.size
+	n, err := s.append(rec)
+	if err != nil {
+		return StoredVersion{}, err
+	}
+	s.size += n
+	s.apply(rec, offset)
+
+	v := s.versions[len(s.versions)-1]
+	if rec.Type == recordVersion {
+		s.latest = NewDataset(d.Artists, relations, d.FetchedAt)
+	} else {
+		latest := *s.latest
+		latest.FetchedAt = d.FetchedAt
+		s.latest = &latest
+	}
+	return v, nil
+}
+
+// append writes one record to the end of the log and fsyncs it.
+func (s *Store) append(rec storeRecord) (int64, error) {
+	frame, err := encodeRecord(rec)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return 0, err
+	}
+	created := s.size == 0
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.Write(frame)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		// drop a partial record so later appends are not lost behind it
+		f.Truncate(s.size)
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	if created {
+		syncDir(filepath.Dir(s.path))
+	}
+	return int64(len(frame)), nil
+}
+
+func encodeRecord(rec storeRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	return append(frame, payload...), nil
+}
+
+// readRecord reads the next record and returns its size in the log.
+// It returns io.EOF at a clean end of the log.
+func readRecord(r io.Reader) (storeRecord, int64, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return storeRecord{}, 0, io.EOF
+		}
+		return storeRecord{}, 0, errCorruptRecord
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return storeRecord{}, 0, errCorruptRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return storeRecord{}, 0, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return storeRecord{}, 0, errCorruptRecord
+	}
+	var rec storeRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return storeRecord{}, 0, errCorruptRecord
+	}
+	return rec, int64(8 + size), nil
+}
+
+// syncDir fsyncs a directory so a newly created or renamed file survives a
+// crash. Not every platform supports it, so errors are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+/*
+Compact rewrites the log without the "checked" records, folding their fetch
+times into the versions. The new log is written to a temporary file, synced
+and renamed over the old one.
+*/
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked()
+}
+
+func (s *Store) compactLocked() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	versions := make([]StoredVersion, 0, len(s.versions))
+	var offset int64
+	for _, v := range s.versions {
+		rec, err := s.readAt(v.offset)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		rec.FetchedAt = v.CheckedAt
+		frame, err := encodeRecord(rec)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := tmp.Write(frame); err != nil {
+			tmp.Close()
+			return err
+		}
+		v.offset = offset
+		versions = append(versions, v)
+		offset += int64(len(frame))
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(s.path))
+
+	s.versions, s.size, s.checked = versions, offset, 0
+	return nil
+}
+
+// readAt reads the record at offset in the log.
+func (s *Store) readAt(offset int64) (storeRecord, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return storeRecord{}, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return storeRecord{}, err
+	}
+	rec, _, err := readRecord(f)
+	if err == io.EOF {
+		err = errCorruptRecord
+	}
+	return rec, err
+}
+
+// Latest returns the newest stored dataset, or nil if the store is empty.
+func (s *Store) Latest() *Dataset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
+
+/*
+StoredAt returns when the content with the given Dataset.Version was
+stored, the last time it became the latest version. ok is false for content
+that was never stored.
+*/
+func (s *Store) StoredAt(version string) (t time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok = s.stored[version]
+	return t, ok
+}
+
+// Versions lists the stored versions, oldest first.
+func (s *Store) Versions() []StoredVersion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StoredVersion(nil), s.versions...)
+}
+
+// Version loads a stored version of the dataset.
+func (s *Store) Version(version int) (*Dataset, error) {
+	s.mu.Lock()
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i].Version >= version })
+	if i == len(s.versions) || s.versions[i].Version != version {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("version %d: %w", version, ErrNotFound)
+	}
+	v := s.versions[i]
+	if i == len(s.versions)-1 {
+		d := s.latest
+		s.mu.Unlock()
+		return d, nil
+	}
+	rec, err := s.readAt(v.offset)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return NewDataset(rec.Artists, rec.Relations, v.CheckedAt), nil
+}
+
+/*
+AsOf returns the version that was current at t: the newest one stored
+before t, or the oldest one if t predates them all. ok is false when the
+store is empty.
+*/
+func (s *Store) AsOf(t time.Time) (v StoredVersion, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.versions) == 0 {
+		return StoredVersion{}, false
+	}
+	i := sort.Search(len(s.versions), func(i int) bool { return !s.versions[i].StoredAt.Before(t) })
+	if i == 0 {
+		return s.versions[0], true
+	}
+	return s.versions[i-1], true
+}
+
+// storeFileName is the store log inside dataDir.
+const storeFileName = "dataset.log"
+
+var stores struct {
+	sync.Mutex
+	open map[string]*Store
+}
+
+// datasetStore returns the store in dataDir, opening it on first use.
+func datasetStore() (*Store, error) {
+	path := filepath.Join(dataDir, storeFileName)
+	stores.Lock()
+	defer stores.Unlock()
+	if s, ok := stores.open[path]; ok {
+		return s, nil
+	}
+	s, err := OpenStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if stores.open == nil {
+		stores.open = map[string]*Store{}
+	}
+	stores.open[path] = s
+	return s, nil
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.log")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore() returned an error: %v", err)
+	}
+	if s.Latest() != nil || len(s.Versions()) != 0 {
+		t.Fatal("expected a new store to be empty")
+	}
+
+	d := newTestDataset()
+	v, err := s.Put(d)
+	if err != nil || v.Version != 1 {
+		t.Fatalf("Put() = %+v, %v", v, err)
+	}
+
+	if latest := s.Latest(); latest == nil || latest.Version() != d.Version() {
+		t.Errorf("Latest() = %+v, want the dataset just stored", latest)
+	}
+}
+
+func TestStoreStoredAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.log")
+	s, _ := OpenStore(path)
+
+	d := newTestDataset()
+	s.Put(d)
+	s.Put(NewDataset(d.Artists, datasetRelations(d), d.FetchedAt.Add(time.Hour)))
+	if at, ok := s.StoredAt(d.Version()); !ok || !at.Equal(d.FetchedAt) {
+		t.Errorf("StoredAt() = %v, %v; want the first fetch time %v", at, ok, d.FetchedAt)
+	}
+	if _, ok := s.StoredAt("0000000000000000"); ok {
+		t.Error("expected unknown content to have no StoredAt")
+	}
+
+	reopened, _ := OpenStore(path)
+	if at, _ := reopened.StoredAt(d.Version()); !at.Equal(d.FetchedAt) {
+		t.Errorf("StoredAt() after reopening = %v, want %v", at, d.FetchedAt)
+	}
+}
+
+func TestStoreVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.log")
+	s, _ := OpenStore(path)
+
+	first := newTestDataset()
+	s.Put(first)
+
+	// The same content again only records the fetch time.
+	refetched := NewDataset(first.Artists, datasetRelations(first), first.FetchedAt.Add(time.Hour))
+	if v, _ := s.Put(refetched); v.Version != 1 || !v.CheckedAt.Equal(refetched.FetchedAt) {
+		t.Errorf("Put() of unchanged content = %+v, want version 1 checked at %v", v, refetched.FetchedAt)
+	}
+
+	artists := append([]Artist(nil), first.Artists...)
+	artists[0].Name = "Queen + Adam Lambert"
+	changed := NewDataset(artists, datasetRelations(first), first.FetchedAt.Add(2*time.Hour))
+	if v, _ := s.Put(changed); v.Version != 2 {
+		t.Errorf("Put() of changed content = %+v, want version 2", v)
+	}
+
+	// Everything survives reopening, and old versions can be read back.
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore() returned an error: %v", err)
+	}
+	versions := s.Versions()
+	if len(versions) != 2 || !versions[0].CheckedAt.Equal(refetched.FetchedAt) {
+		t.Fatalf("Versions() after reopening = %+v", versions)
+	}
+	if a, _ := s.Latest().Artist(1); a.Name != "Queen + Adam Lambert" {
+		t.Errorf("latest artist 1 = %q", a.Name)
+	}
+	old, err := s.Version(1)
+	if err != nil {
+		t.Fatalf("Version(1) returned an error: %v", err)
+	}
+	if a, _ := old.Artist(1); a.Name != "Queen" || len(old.Concerts(1)) != 2 {
+		t.Errorf("Version(1) artist 1 = %+v with %d concerts", a, len(old.Concerts(1)))
+	}
+	if _, err := s.Version(3); err == nil {
+		t.Error("Version(3) of a missing version returned no error")
+	}
+
+	// Compaction drops the checked record but keeps its fetch time.
+	before, _ := os.Stat(path)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() returned an error: %v", err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("expected compaction to shrink the log, %d >= %d bytes", after.Size(), before.Size())
+	}
+	s, _ = OpenStore(path)
+	if versions := s.Versions(); len(versions) != 2 || !versions[0].CheckedAt.Equal(refetched.FetchedAt) {
+		t.Errorf("Versions() after compaction = %+v", versions)
+	}
+	if old, err := s.Version(1); err != nil || old.Artists[0].Name != "Queen" {
+		t.Errorf("Version(1) after compaction = %v, %v", old, err)
+	}
+}
+
+func TestStoreRecoversFromTornWrites(t *testing.T) {
+	// corrupt damages the second record; good is the log before it.
+	tests := []struct {
+		name    string
+		corrupt func(good, data []byte) []byte
+	}{
+		{"truncated record", func(good, data []byte) []byte { return data[:len(data)-5] }},
+		{"truncated header", func(good, data []byte) []byte { return data[:len(good)+3] }},
+		{"bad checksum", func(good, data []byte) []byte {
+			data[len(data)-2] ^= 0xff
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dataset.log")
+			s, _ := OpenStore(path)
+			first := newTestDataset()
+			s.Put(first)
+			good, _ := os.ReadFile(path)
+
+			artists := append([]Artist(nil), first.Artists...)
+			artists[0].Name = "Queen II"
+			s.Put(NewDataset(artists, datasetRelations(first), first.FetchedAt.Add(time.Hour)))
+			data, _ := os.ReadFile(path)
+			os.WriteFile(path, tt.corrupt(good, data), 0o644)
+
+			s, err := OpenStore(path)
+			if err != nil {
+				t.Fatalf("OpenStore() returned an error: %v", err)
+			}
+			if len(s.Versions()) != 1 {
+				t.Errorf("expected only the first version to survive, got %+v", s.Versions())
+			}
+			if repaired, _ := os.ReadFile(path); len(repaired) != len(good) {
+				t.Errorf("expected the log to be truncated to %d bytes, got %d", len(good), len(repaired))
+			}
+
+			// Appending after the repair works and survives reopening.
+			s.Put(NewDataset(artists, datasetRelations(first), first.FetchedAt.Add(2*time.Hour)))
+			if s, _ := OpenStore(path); len(s.Versions()) != 2 {
+				t.Errorf("expected 2 versions after appending, got %+v", s.Versions())
+			}
+		})
+	}
+}
+
+func TestRefreshUsesStore(t *testing.T) {
+	api := useFakeAPI(t)
+
+	if _, _, err := RefreshDataset(); err != nil {
+		t.Fatalf("RefreshDataset() returned an error: %v", err)
+	}
+
+	// After a restart the stored dataset is used without calling the API.
+	setDataset(nil)
+	api.set("", true)
+	attempted := currentRefreshStatus().LastAttempt
+	d, err := loadDataset()
+	if err != nil || len(d.Artists) != 1 || !fresh(d) {
+		t.Fatalf("loadDataset() after a restart = %v, %v", d, err)
+	}
+	if !currentRefreshStatus().LastAttempt.Equal(attempted) {
+		t.Error("expected no refresh attempt for a fresh stored dataset")
+	}
+
+	if len(d.ConcertsAt("london-uk")) != 1 {
+		t.Errorf("expected the stored dataset to be indexed, got %+v", d.ConcertsAt("london-uk"))
+	}
+}
@@ -18,8 +18,19 @@ type Artist struct {
 	Locations    string   `json:"locations"`
 	ConcertDates string   `json:"concertDates"`
 	Relations    string   `json:"relations"`
+	// Corrected lists the fields changed by local overrides.
+	Corrected []string `json:"corrected,omitempty"`
 }
+
+// IsCorrected reports whether a local override changed the named field.
+func (a Artist) IsCorrected(field string) bool {
+	return containsString(a.Corrected, field)
+}
+
 type Relation struct {
 	ID        int64               `json:"id"`
 	Locations map[string][]string `json:"datesLocations"`
+	// Corrected lists, by location, the dates that local overrides renamed
+	// or added.
+	Corrected map[string][]string `json:"corrected,omitempty"`
 }
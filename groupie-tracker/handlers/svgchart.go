@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Count is one labelled value of a statistic, e.g. a country and its concerts.
+type Count struct {
+	Label string `json:"label"`
+	Value int    `json:"value"`
+}
+
+// Layout of the server-side bar charts, in SVG user units.
+const (
+	chartWidth      = 640
+	chartLabelWidth = 170
+	chartValueWidth = 50
+	chartBarHeight  = 22
+	chartBarGap     = 8
+)
+
+/*
+barChartSVG renders counts as a horizontal bar chart in inline SVG so pages
+need no JavaScript to show charts. Bars are scaled to the largest value and
+labels are HTML-escaped.
+*/
+func barChartSVG(title string, counts []Count) string {
+	max := 0
+	for _, c := range counts {
+		if c.Value > max {
+			max = c.Value
+		}
+	}
+
+	height := len(counts)*(chartBarHeight+chartBarGap) + chartBarGap
+	barSpace := chartWidth - chartLabelWidth - chartValueWidth
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" class="chart" viewBox="0 0 %d %d" role="img" aria-label="%s">`,
+		chartWidth, height, html.EscapeString(title))
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(title))
+	for i, c := range counts {
+		y := chartBarGap + i*(chartBarHeight+chartBarGap)
+		width := 0
+		if max > 0 {
+			width = c.Value * barSpace / max
+		}
+		textY := y + chartBarHeight/2 + 5
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" class="chart-label">%s</text>`,
+			chartLabelWidth-10, textY, html.EscapeString(c.Label))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" class="chart-bar"/>`,
+			chartLabelWidth, y, width, chartBarHeight)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" class="chart-value">%d</text>`,
+			chartLabelWidth+width+6, textY, c.Value)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
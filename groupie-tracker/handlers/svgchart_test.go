@@ -0,0 +1,30 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBarChartSVG(t *testing.T) {
+	svg := barChartSVG("Concerts <per> country", []Count{
+		{Label: "USA", Value: 10},
+		{Label: "R&B Land", Value: 5},
+	})
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"`,
+		`<title>Concerts &lt;per&gt; country</title>`,
+		`R&amp;B Land`,
+		`width="420"`, // the largest value spans the whole bar area
+		`width="210"`,
+		`>10</text>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected chart to contain %q; got %s", want, svg)
+		}
+	}
+
+	if empty := barChartSVG("Empty", nil); !strings.HasSuffix(empty, "</svg>") {
+		t.Errorf("expected an empty chart to be valid SVG; got %s", empty)
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of events on an artist timeline.
+const (
+	eventFounded = "founded"
+	eventDebut   = "debut"
+	eventConcert = "concert"
+)
+
+// minTimelineGap is the shortest break between two events worth pointing out.
+const minTimelineGap = 365 * 24 * time.Hour
+
+// Layout of the timeline SVG, in SVG user units.
+const (
+	timelineWidth   = 900
+	timelineHeight  = 140
+	timelinePadding = 40
+	timelineAxisY   = 90
+)
+
+// TimelineEvent is one dated point on an artist's timeline.
+type TimelineEvent struct {
+	Date  time.Time `json:"date"`
+	Kind  string    `json:"kind"`
+	Label string    `json:"label"`
+}
+
+// TimelineGap is a long break between two consecutive events.
+type TimelineGap struct {
+	From  TimelineEvent `json:"from"`
+	To    TimelineEvent `json:"to"`
+	Years float64       `json:"years"`
+}
+
+// Timeline is the data behind /artist/{id}/timeline.
+type Timeline struct {
+	Artist Artist          `json:"artist"`
+	Events []TimelineEvent `json:"events"`
+	Gaps   []TimelineGap   `json:"gaps"`
+	SVG    template.HTML   `json:"-"` // trusted markup: timelineSVG escapes the text it draws
+}
+
+/*
+BuildTimeline puts the founding year, the first album and every concert of
+an artist in chronological order and finds the breaks longer than a year
+between them. The founding date is the first of January of CreationDate,
+as the API only gives a year.
+*/
+func BuildTimeline(detail ArtistDetail) Timeline {
+	var events []TimelineEvent
+	if detail.Artist.CreationDate > 0 {
+		events = append(events, TimelineEvent{
+			Date:  time.Date(detail.Artist.CreationDate, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Kind:  eventFounded,
+			Label: fmt.Sprintf("Founded (%d)", detail.Artist.CreationDate),
+		})
+	}
+	if date, err := ParseConcertDate(detail.Artist.FirstAlbum); err == nil {
+		events = append(events, TimelineEvent{Date: date, Kind: eventDebut, Label: "First album"})
+	}
+	for _, c := range detail.Concerts {
+		events = append(events, TimelineEvent{Date: c.Date, Kind: eventConcert, Label: c.City + ", " + c.Country})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+
+	var gaps []TimelineGap
+	for i := 1; i < len(events); i++ {
+		d := events[i].Date.Sub(events[i-1].Date)
+		if d < minTimelineGap {
+			continue
+		}
+		gaps = append(gaps, TimelineGap{
+			From:  events[i-1],
+			To:    events[i],
+			Years: float64(int(d.Hours()/24/365.25*10)) / 10,
+		})
+	}
+
+	return Timeline{
+		Artist: detail.Artist,
+		Events: events,
+		Gaps:   gaps,
+		SVG:    template.HTML(timelineSVG(detail.Artist.Name, events)),
+	}
+}
+
+// DateString formats the event date the way the pages display it.
+func (e TimelineEvent) DateString() string {
+	if e.Kind == eventFounded {
+		return e.Date.Format("2006")
+	}
+	return e.Date.Format("02 Jan 2006")
+}
+
+/*
+timelineSVG draws the events along a horizontal axis with a marker for
+each year (or every few years on long careers). Founding and debut are
+labelled; concerts are small dots with their location as a tooltip.
+*/
+func timelineSVG(name string, events []TimelineEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" class="timeline" viewBox="0 0 %d %d" role="img" aria-label="%s timeline">`,
+		timelineWidth, timelineHeight, html.EscapeString(name))
+	if len(events) == 0 {
+		b.WriteString(`</svg>`)
+		return b.String()
+	}
+
+	firstYear := events[0].Date.Year()
+	lastYear := events[len(events)-1].Date.Year() + 1
+	start := time.Date(firstYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(lastYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	span := end.Sub(start).Seconds()
+	x := func(t time.Time) float64 {
+		return timelinePadding + t.Sub(start).Seconds()/span*(timelineWidth-2*timelinePadding)
+	}
+
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" class="timeline-axis"/>`,
+		timelinePadding, timelineAxisY, timelineWidth-timelinePadding, timelineAxisY)
+
+	step := 1
+	for (lastYear-firstYear)/step > 15 {
+		step++
+	}
+	for year := firstYear; year <= lastYear; year += step {
+		px := x(time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC))
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" class="timeline-tick"/>`, px, timelineAxisY-5, px, timelineAxisY+5)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" class="timeline-year">%d</text>`, px, timelineAxisY+22, year)
+	}
+
+	for _, e := range events {
+		px := x(e.Date)
+		label := html.EscapeString(e.Label + " - " + e.DateString())
+		switch e.Kind {
+		case eventConcert:
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%d" r="4" class="timeline-concert"><title>%s</title></circle>`, px, timelineAxisY, label)
+		default:
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" class="timeline-flag"/>`, px, timelineAxisY, px, timelineAxisY-45)
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%d" r="7" class="timeline-%s"><title>%s</title></circle>`, px, timelineAxisY, e.Kind, label)
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" class="timeline-label">%s</text>`, px, timelineAxisY-50, html.EscapeString(e.Label))
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+/*
+TimelineHandler renders an artist's chronological timeline: founding,
+first album and every concert, with the long breaks between them. With
+?format=json or an Accept header asking for JSON it returns the events and
+breaks as JSON.
+
+Parameters:
+  - w: http.ResponseWriter to write the response
+  - r: *http.Request containing the request details
+*/
+func TimelineHandler(w http.ResponseWriter, r *http.Request) {
+	id := PathInt(r, "id")
+	data, err := requestDataset(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Error fetching artist")
+		return
+	}
+	detail, err := readArtistDetail(data, id)
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, "Oops! We Can't find that page")
+		return
+	}
+	if dataNotModified(w, r, datasetValidator(data), fmt.Sprintf("timeline%d", id)) {
+		return
+	}
+
+	timeline := BuildTimeline(detail)
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, timeline)
+		return
+	}
+
+	temp, err := parseTemplate("template/timeline.html")
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error loading template")
+		return
+	}
+
+	err = temp.Execute(w, timeline)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Error executing template")
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	detail := ArtistDetail{
+		Artist: Artist{ID: 1, Name: "Queen", CreationDate: 1970, FirstAlbum: "14-12-1973"},
+		Concerts: BuildConcerts(Relation{ID: 1, Locations: map[string][]string{
+			"london-uk":    {"20-11-2019"},
+			"new_york-usa": {"05-10-2019"},
+		}}),
+	}
+
+	got := BuildTimeline(detail)
+
+	wantKinds := []string{eventFounded, eventDebut, eventConcert, eventConcert}
+	if len(got.Events) != len(wantKinds) {
+		t.Fatalf("got %d events, want %d", len(got.Events), len(wantKinds))
+	}
+	for i, kind := range wantKinds {
+		if got.Events[i].Kind != kind {
+			t.Errorf("event %d kind = %q, want %q", i, got.Events[i].Kind, kind)
+		}
+	}
+	if got.Events[2].Label != "New York, USA" {
+		t.Errorf("first concert = %q, want New York, USA", got.Events[2].Label)
+	}
+
+	if len(got.Gaps) != 2 {
+		t.Fatalf("got %d gaps, want 2 (founding to debut, debut to touring): %+v", len(got.Gaps), got.Gaps)
+	}
+	if got.Gaps[0].Years != 3.9 || got.Gaps[1].To.Kind != eventConcert {
+		t.Errorf("unexpected gaps: %+v", got.Gaps)
+	}
+
+	for _, want := range []string{`class="timeline"`, `>1970</text>`, `>1974</text>`, `First album`, `New York, USA - 05 Oct 2019`} {
+		if !strings.Contains(string(got.SVG), want) {
+			t.Errorf("expected timeline SVG to contain %q", want)
+		}
+	}
+
+	// The page embeds the SVG as markup, not as escaped text.
+	temp, err := parseTemplate("../template/timeline.html")
+	if err != nil {
+		t.Fatalf("parseTemplate() returned an error: %v", err)
+	}
+	var page strings.Builder
+	if err := temp.Execute(&page, got); err != nil {
+		t.Fatalf("Execute() returned an error: %v", err)
+	}
+	if !strings.Contains(page.String(), `<svg xmlns=`) {
+		t.Errorf("expected the page to embed the SVG; got %s", page.String())
+	}
+}
+
+func TestTimelineSVGEmpty(t *testing.T) {
+	svg := timelineSVG("Nobody", nil)
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Errorf("expected an empty timeline to be valid SVG; got %s", svg)
+	}
+}
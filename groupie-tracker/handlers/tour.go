@@ -0,0 +1,123 @@
+package api
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	earthRadiusKm = 6371.0
+
+	// backToBackKm is the distance above which shows on consecutive days
+	// are flagged as a hard back-to-back.
+	backToBackKm = 1000
+)
+
+// TourLeg is the trip between two consecutive concerts.
+type TourLeg struct {
+	From Concert `json:"from"`
+	To   Concert `json:"to"`
+	Km   int     `json:"km"`
+	Days int     `json:"days"`
+}
+
+// YearCountries lists the countries an artist played in during one year.
+type YearCountries struct {
+	Year      int      `json:"year"`
+	Countries []string `json:"countries"`
+}
+
+// TourStats are the travel analytics of an artist's concerts.
+type TourStats struct {
+	Legs             []TourLeg       `json:"legs"`
+	TotalKm          int             `json:"totalKm"`
+	LongestLeg       *TourLeg        `json:"longestLeg,omitempty"`
+	CountriesPerYear []YearCountries `json:"countriesPerYear"`
+	BackToBack       []TourLeg       `json:"backToBack"`
+	UnknownLocations []string        `json:"unknownLocations"`
+}
+
+/*
+ComputeTourStats walks the concerts in date order and measures each leg
+between different locations by great-circle distance. Concerts whose
+location has no known coordinates are left out of the legs and reported in
+UnknownLocations. Back-to-back legs are at most a day apart and at least
+backToBackKm long. Distances are rounded to whole kilometres.
+*/
+func ComputeTourStats(concerts []Concert) TourStats {
+	stats := TourStats{
+		Legs:             []TourLeg{},
+		CountriesPerYear: []YearCountries{},
+		BackToBack:       []TourLeg{},
+		UnknownLocations: []string{},
+	}
+
+	sorted := append([]Concert(nil), concerts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	unknown := map[string]bool{}
+	perYear := map[int]map[string]bool{}
+	var prev *Concert
+	for i := range sorted {
+		c := sorted[i]
+
+		year := c.Date.Year()
+		if perYear[year] == nil {
+			perYear[year] = map[string]bool{}
+		}
+		perYear[year][c.Country] = true
+
+		if _, ok := locationCoordinates[c.Location]; !ok {
+			if !unknown[c.Location] {
+				unknown[c.Location] = true
+				stats.UnknownLocations = append(stats.UnknownLocations, c.Location)
+			}
+			continue
+		}
+		if prev != nil && prev.Location != c.Location {
+			leg := TourLeg{
+				From: *prev,
+				To:   c,
+				Km:   int(math.Round(distanceKm(locationCoordinates[prev.Location], locationCoordinates[c.Location]))),
+				Days: int(c.Date.Sub(prev.Date).Hours() / 24),
+			}
+			stats.Legs = append(stats.Legs, leg)
+			stats.TotalKm += leg.Km
+			if leg.Days <= 1 && leg.Km >= backToBackKm {
+				stats.BackToBack = append(stats.BackToBack, leg)
+			}
+		}
+		prev = &sorted[i]
+	}
+
+	for i := range stats.Legs {
+		if stats.LongestLeg == nil || stats.Legs[i].Km > stats.LongestLeg.Km {
+			stats.LongestLeg = &stats.Legs[i]
+		}
+	}
+
+	for year, countries := range perYear {
+		yc := YearCountries{Year: year}
+		for country := range countries {
+			yc.Countries = append(yc.Countries, country)
+		}
+		sort.Strings(yc.Countries)
+		stats.CountriesPerYear = append(stats.CountriesPerYear, yc)
+	}
+	sort.Slice(stats.CountriesPerYear, func(i, j int) bool {
+		return stats.CountriesPerYear[i].Year < stats.CountriesPerYear[j].Year
+	})
+	sort.Strings(stats.UnknownLocations)
+
+	return stats
+}
+
+// distanceKm is the haversine great-circle distance between two points.
+func distanceKm(a, b Coordinates) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
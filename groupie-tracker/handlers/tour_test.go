@@ -0,0 +1,64 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	london := locationCoordinates["london-uk"]
+	paris := locationCoordinates["paris-france"]
+
+	if got := distanceKm(london, paris); math.Abs(got-344) > 5 {
+		t.Errorf("distanceKm(London, Paris) = %.0f, want about 344", got)
+	}
+	if got := distanceKm(london, london); got != 0 {
+		t.Errorf("distanceKm(London, London) = %v, want 0", got)
+	}
+}
+
+func TestComputeTourStats(t *testing.T) {
+	concerts := BuildConcerts(Relation{ID: 1, Locations: map[string][]string{
+		"london-uk":          {"01-06-2019", "02-06-2019"},
+		"paris-france":       {"05-06-2019"},
+		"new_york-usa":       {"06-06-2019"},
+		"atlantis-somewhere": {"10-06-2019"},
+		"osaka-japan":        {"01-01-2020"},
+	}})
+
+	stats := ComputeTourStats(concerts)
+
+	// London twice counts once; Atlantis has no coordinates.
+	if len(stats.Legs) != 3 {
+		t.Fatalf("got %d legs, want 3: %+v", len(stats.Legs), stats.Legs)
+	}
+	if stats.Legs[0].From.Location != "london-uk" || stats.Legs[0].Days != 3 {
+		t.Errorf("first leg = %+v, want London on the 2nd to Paris 3 days later", stats.Legs[0])
+	}
+	if stats.LongestLeg == nil || stats.LongestLeg.To.Location != "osaka-japan" {
+		t.Errorf("LongestLeg = %+v, want the leg to Osaka", stats.LongestLeg)
+	}
+	if len(stats.BackToBack) != 1 || stats.BackToBack[0].To.Location != "new_york-usa" {
+		t.Errorf("BackToBack = %+v, want Paris to New York", stats.BackToBack)
+	}
+	total := 0
+	for _, leg := range stats.Legs {
+		total += leg.Km
+	}
+	if stats.TotalKm != total {
+		t.Errorf("TotalKm = %v, want the sum of the legs %v", stats.TotalKm, total)
+	}
+	if len(stats.UnknownLocations) != 1 || stats.UnknownLocations[0] != "atlantis-somewhere" {
+		t.Errorf("UnknownLocations = %v, want [atlantis-somewhere]", stats.UnknownLocations)
+	}
+	if len(stats.CountriesPerYear) != 2 || len(stats.CountriesPerYear[0].Countries) != 4 || stats.CountriesPerYear[1].Year != 2020 {
+		t.Errorf("CountriesPerYear = %+v", stats.CountriesPerYear)
+	}
+}
+
+func TestComputeTourStatsEmpty(t *testing.T) {
+	stats := ComputeTourStats(nil)
+	if stats.LongestLeg != nil || stats.TotalKm != 0 || len(stats.Legs) != 0 {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxUpstreamLog is how many upstream requests the request log keeps.
+const maxUpstreamLog = 200
+
+// upstreamClient makes the upstream requests. Its timeout keeps a hung
+// upstream from holding a refresh, and every request waiting on it, forever.
+var upstreamClient = &http.Client{Timeout: 10 * time.Second}
+
+// UpstreamRequest records one request made to the upstream API.
+type UpstreamRequest struct {
+	At       time.Time     `json:"at"`
+	URL      string        `json:"url"`
+	Status   int           `json:"status,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+var (
+	upstreamLogMu sync.Mutex
+	upstreamLog   []UpstreamRequest
+)
+
+/*
+upstreamGet is http.Get for the upstream API, made with upstreamClient.
+Every request is recorded in an in-memory log shown in the admin area.
+*/
+func upstreamGet(url string) (*http.Response, error) {
+	return upstreamGetContext(context.Background(), url)
+}
+
+// upstreamGetContext is upstreamGet for a request that ctx may cancel
+// before the client's timeout.
+func upstreamGetContext(ctx context.Context, url string) (*http.Response, error) {
+	start := time.Now()
+	var res *http.Response
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err == nil {
+		res, err = upstreamClient.Do(req)
+	}
+
+	entry := UpstreamRequest{At: start, URL: url, Duration: time.Since(start)}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Status = res.StatusCode
+	}
+	upstreamLogMu.Lock()
+	upstreamLog = append(upstreamLog, entry)
+	if len(upstreamLog) > maxUpstreamLog {
+		upstreamLog = upstreamLog[len(upstreamLog)-maxUpstreamLog:]
+	}
+	upstreamLogMu.Unlock()
+
+	return res, err
+}
+
+// upstreamRequests returns the logged upstream requests, newest first.
+func upstreamRequests() []UpstreamRequest {
+	upstreamLogMu.Lock()
+	defer upstreamLogMu.Unlock()
+	out := make([]UpstreamRequest, len(upstreamLog))
+	for i, e := range upstreamLog {
+		out[len(upstreamLog)-1-i] = e
+	}
+	return out
+}
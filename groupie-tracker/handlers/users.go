@@ -0,0 +1,410 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minPasswordLength = 8
+	loginTTL          = 7 * 24 * time.Hour
+	maxLoginFailures  = 5
+	loginWindow       = 15 * time.Minute
+)
+
+// scrypt parameters for new password hashes. Tests lower scryptN to keep
+// hashing fast; stored hashes carry their own parameters.
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	errInvalidUsername = errors.New("usernames are 3 to 32 letters, digits, '-' or '_'")
+	errWeakPassword    = fmt.Errorf("passwords need at least %d characters", minPasswordLength)
+	errUserExists      = errors.New("that username is taken")
+)
+
+// SavedSearch is an artists filter a user kept for later.
+type SavedSearch struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Query   string    `json:"query"` // encoded ArtistFilter values
+	Created time.Time `json:"created"`
+}
+
+// URL links to the artists page with the saved filter applied.
+func (s SavedSearch) URL() string {
+	if s.Query == "" {
+		return "/artists"
+	}
+	return "/artists?" + s.Query
+}
+
+// User is a registered account.
+type User struct {
+	Username      string        `json:"username"`
+	PasswordHash  string        `json:"passwordHash"`
+	Created       time.Time     `json:"created"`
+	SavedSearches []SavedSearch `json:"savedSearches"`
+	Email         string        `json:"email,omitempty"`
+	Digest        string        `json:"digest,omitempty"` // DigestDaily, DigestWeekly or "" for none
+	LastDigest    time.Time     `json:"lastDigest,omitempty"`
+}
+
+/*
+hashPassword hashes a password with scrypt and a random salt. The result
+records the parameters: "scrypt$N$r$p$salt$key" with base64 salt and key.
+*/
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := scryptKey([]byte(password), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("scrypt$%d$%d$%d$%s$%s", scryptN, scryptR, scryptP, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// verifyPassword reports whether password matches a hash from hashPassword.
+func verifyPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "scrypt" {
+		return false
+	}
+	var params [3]int
+	for i := range params {
+		n, err := strconv.Atoi(parts[i+1])
+		if err != nil {
+			return false
+		}
+		params[i] = n
+	}
+	enc := base64.RawStdEncoding
+	salt, err1 := enc.DecodeString(parts[4])
+	want, err2 := enc.DecodeString(parts[5])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	got, err := scryptKey([]byte(password), salt, params[0], params[1], params[2], len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func validUsername(name string) bool {
+	return len(name) >= 3 && len(name) <= 32 && validRequestID(name)
+}
+
+var usersMu sync.Mutex
+
+func usersPath() string {
+	return filepath.Join(dataDir, "users.json")
+}
+
+// readUsersLocked returns the user store keyed by lower-cased username.
+func readUsersLocked() (map[string]User, error) {
+	users := map[string]User{}
+	err := readJSONFile(usersPath(), &users)
+	if os.IsNotExist(err) {
+		return map[string]User{}, nil
+	}
+	return users, err
+}
+
+// createUser registers a new account. Usernames are case-insensitive.
+func createUser(username, password string) (User, error) {
+	if !validUsername(username) {
+		return User{}, errInvalidUsername
+	}
+	if len(password) < minPasswordLength {
+		return User{}, errWeakPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	users, err := readUsersLocked()
+	if err != nil {
+		return User{}, err
+	}
+	key := strings.ToLower(username)
+	if _, ok := users[key]; ok {
+		return User{}, errUserExists
+	}
+	u := User{Username: username, PasswordHash: hash, Created: time.Now().UTC()}
+	users[key] = u
+	return u, writeJSONFile(usersPath(), users)
+}
+
+// getUser looks an account up by username.
+func getUser(username string) (User, bool) {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	users, err := readUsersLocked()
+	if err != nil {
+		return User{}, false
+	}
+	u, ok := users[strings.ToLower(username)]
+	return u, ok
+}
+
+// authenticate returns the account if the password matches.
+func authenticate(username, password string) (User, bool) {
+	u, ok := getUser(username)
+	if !ok {
+		// Hash anyway so unknown usernames take as long as wrong passwords.
+		dummy := fmt.Sprintf("scrypt$%d$%d$%d$%s$%s", scryptN, scryptR, scryptP, strings.Repeat("A", 22), strings.Repeat("A", 43))
+		verifyPassword(dummy, password)
+		return User{}, false
+	}
+	return u, verifyPassword(u.PasswordHash, password)
+}
+
+// updateUser applies change to a stored account and saves it.
+func updateUser(username string, change func(*User)) error {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	users, err := readUsersLocked()
+	if err != nil {
+		return err
+	}
+	key := strings.ToLower(username)
+	u, ok := users[key]
+	if !ok {
+		return os.ErrNotExist
+	}
+	change(&u)
+	users[key] = u
+	return writeJSONFile(usersPath(), users)
+}
+
+// login ties a session to an account until it expires.
+type login struct {
+	Username string    `json:"username"`
+	Expires  time.Time `json:"expires"`
+}
+
+// loginsMu guards logins.json and loginsCache, the copy of it in memory
+// that spares currentUser a read of the file on every call.
+var (
+	loginsMu    sync.Mutex
+	loginsCache struct {
+		path   string
+		logins map[string]login
+	}
+)
+
+func loginsPath() string {
+	return filepath.Join(dataDir, "logins.json")
+}
+
+/*
+readLoginsLocked returns the logins, from memory when logins.json was read
+before. Callers that change the map must save it with writeLoginsLocked.
+*/
+func readLoginsLocked() (map[string]login, error) {
+	path := loginsPath()
+	if loginsCache.logins != nil && loginsCache.path == path {
+		return loginsCache.logins, nil
+	}
+	logins := map[string]login{}
+	err := readJSONFile(path, &logins)
+	if os.IsNotExist(err) {
+		logins, err = map[string]login{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loginsCache.path, loginsCache.logins = path, logins
+	return logins, nil
+}
+
+// writeLoginsLocked saves logins. If that fails the copy in memory is
+// dropped, so the next read sees what is on disk.
+func writeLoginsLocked(logins map[string]login) error {
+	path := loginsPath()
+	if err := writeJSONFile(path, logins); err != nil {
+		loginsCache.logins = nil
+		return err
+	}
+	loginsCache.path, loginsCache.logins = path, logins
+	return nil
+}
+
+/*
+startLogin signs username in. It starts a new session, so a session ID
+planted before login is worthless afterwards, and moves the favorites of
+the anonymous session over to the account.
+*/
+func startLogin(w http.ResponseWriter, r *http.Request, username string) error {
+	previous := sessionID(r)
+	id := newSession(w, r)
+
+	loginsMu.Lock()
+	logins, err := readLoginsLocked()
+	if err == nil {
+		now := time.Now()
+		for sid, l := range logins {
+			if now.After(l.Expires) {
+				delete(logins, sid)
+			}
+		}
+		logins[id] = login{Username: username, Expires: now.Add(loginTTL).UTC()}
+		err = writeLoginsLocked(logins)
+	}
+	loginsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if previous != "" {
+		return mergeFavorites(previous, userOwner(username))
+	}
+	return nil
+}
+
+// endLogin signs the session out and starts a fresh anonymous one.
+func endLogin(w http.ResponseWriter, r *http.Request) error {
+	id := sessionID(r)
+	newSession(w, r)
+	if id == "" {
+		return nil
+	}
+
+	loginsMu.Lock()
+	defer loginsMu.Unlock()
+	logins, err := readLoginsLocked()
+	if err != nil {
+		return err
+	}
+	if _, ok := logins[id]; !ok {
+		return nil
+	}
+	delete(logins, id)
+	return writeLoginsLocked(logins)
+}
+
+// currentUser returns the username signed in on the request's session, or
+// "" for anonymous visitors and expired logins.
+func currentUser(r *http.Request) string {
+	id := sessionID(r)
+	if id == "" {
+		return ""
+	}
+	loginsMu.Lock()
+	defer loginsMu.Unlock()
+	logins, err := readLoginsLocked()
+	if err != nil {
+		return ""
+	}
+	l, ok := logins[id]
+	if !ok || time.Now().After(l.Expires) {
+		return ""
+	}
+	return l.Username
+}
+
+// userOwner is the favorites owner key of an account.
+func userOwner(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+/*
+loginLimiter counts failed logins per key and refuses further attempts
+once a key has maxLoginFailures failures within loginWindow. Keys whose
+failures have all expired are swept once per window, so failed logins with
+ever new usernames do not grow the map without bound.
+*/
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	swept    time.Time
+	now      func() time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: map[string][]time.Time{}, now: time.Now}
+}
+
+var loginAttempts = newLoginLimiter()
+
+// recent drops failures older than the window and returns the rest.
+func (l *loginLimiter) recent(key string) []time.Time {
+	cutoff := l.now().Add(-loginWindow)
+	kept := l.failures[key][:0]
+	for _, t := range l.failures[key] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = kept
+	return kept
+}
+
+// allow reports whether all keys may try again, and if not, how long to wait.
+func (l *loginLimiter) allow(keys ...string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		failures := l.recent(key)
+		if len(failures) >= maxLoginFailures {
+			if d := failures[0].Add(loginWindow).Sub(l.now()); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait == 0, wait
+}
+
+func (l *loginLimiter) fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); now.Sub(l.swept) >= loginWindow {
+		for key := range l.failures {
+			l.recent(key)
+		}
+		l.swept = now
+	}
+	for _, key := range keys {
+		l.failures[key] = append(l.recent(key), l.now())
+	}
+}
+
+func (l *loginLimiter) reset(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// loginKeys rate-limits by account and by client address.
+func loginKeys(r *http.Request, username string) []string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return []string{"user:" + strings.ToLower(username), "ip:" + ip}
+}
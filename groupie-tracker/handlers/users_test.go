@@ -0,0 +1,165 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFastScrypt lowers the scrypt cost so tests can hash passwords quickly.
+func useFastScrypt(t *testing.T) {
+	t.Helper()
+	previous := scryptN
+	scryptN = 16
+	t.Cleanup(func() { scryptN = previous })
+}
+
+func TestHashPassword(t *testing.T) {
+	useFastScrypt(t)
+
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword() returned an error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "scrypt$16$8$1$") {
+		t.Errorf("hashPassword() = %q, want the scrypt parameters recorded", hash)
+	}
+	other, _ := hashPassword("correct horse")
+	if other == hash {
+		t.Error("expected different salts for two hashes of the same password")
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"matching password", hash, "correct horse", true},
+		{"wrong password", hash, "correct horse!", false},
+		{"malformed hash", "plain", "correct horse", false},
+		{"bad parameters", "scrypt$15$8$1$AAAA$AAAA", "correct horse", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyPassword(tt.hash, tt.password); got != tt.want {
+				t.Errorf("verifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	useTempDataDir(t)
+	useFastScrypt(t)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{"valid", "Freddie", "bohemian1", nil},
+		{"taken, case-insensitive", "freddie", "bohemian1", errUserExists},
+		{"short username", "fm", "bohemian1", errInvalidUsername},
+		{"bad characters", "fred die", "bohemian1", errInvalidUsername},
+		{"short password", "brian", "short", errWeakPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createUser(tt.username, tt.password)
+			if err != tt.wantErr {
+				t.Errorf("createUser(%q) error = %v, want %v", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, ok := authenticate("FREDDIE", "bohemian1"); !ok {
+		t.Error("authenticate() failed for the right password")
+	}
+	if _, ok := authenticate("freddie", "wrong-password"); ok {
+		t.Error("authenticate() succeeded for a wrong password")
+	}
+	if _, ok := authenticate("nobody", "bohemian1"); ok {
+		t.Error("authenticate() succeeded for an unknown user")
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < maxLoginFailures; i++ {
+		if ok, _ := l.allow("user:a", "ip:1"); !ok {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+		l.fail("user:a", "ip:1")
+		now = now.Add(time.Minute)
+	}
+
+	ok, wait := l.allow("user:a")
+	if ok || wait != loginWindow-maxLoginFailures*time.Minute {
+		t.Errorf("allow() after %d failures = %v, %v", maxLoginFailures, ok, wait)
+	}
+	if ok, _ := l.allow("user:b", "ip:1"); ok {
+		t.Error("expected the address to be limited for other accounts too")
+	}
+	if ok, _ := l.allow("user:b", "ip:2"); !ok {
+		t.Error("expected other accounts from other addresses to be allowed")
+	}
+
+	now = now.Add(loginWindow)
+	if ok, _ := l.allow("user:a", "ip:1"); !ok {
+		t.Error("expected attempts to be allowed again after the window")
+	}
+}
+
+func TestLoginLimiterSweepsExpiredKeys(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	// Every attempt guesses a new username, from a few addresses.
+	for i := 0; i < 1000; i++ {
+		l.fail(fmt.Sprintf("user:guess%d", i), fmt.Sprintf("ip:%d", i%4))
+		now = now.Add(10 * time.Second)
+	}
+
+	// A window holds 90 attempts: 90 usernames and 4 addresses at most.
+	if n := len(l.failures); n > 2*int(loginWindow/(10*time.Second))+4 {
+		t.Errorf("expected expired keys to be swept; %d keys left", n)
+	}
+	if ok, _ := l.allow("ip:1"); ok {
+		t.Error("expected the sweep to keep recent failures")
+	}
+}
+
+func TestCurrentUserReadsLoginsOnce(t *testing.T) {
+	useAccountTest(t)
+	b := newTestBrowser(t)
+	b.do("GET", "/favorites", nil)
+	b.do("POST", "/register", url.Values{"username": {"brian"}, "password": {"red-special"}, "confirm": {"red-special"}})
+
+	// Logins are kept in memory once read, so the file is not read again.
+	if err := os.WriteFile(loginsPath(), []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, code := b.account(); code != http.StatusOK || got.Username != "brian" {
+		t.Fatalf("GET /account = %d %+v", code, got)
+	}
+
+	// Signing out writes the logins back and is seen straight away.
+	b.do("POST", "/logout", url.Values{})
+	if _, code := b.account(); code != http.StatusUnauthorized {
+		t.Errorf("GET /account after logout = %d, want 401", code)
+	}
+	var logins map[string]login
+	if err := readJSONFile(loginsPath(), &logins); err != nil || len(logins) != 0 {
+		t.Errorf("logins.json after logout = %v, %v", logins, err)
+	}
+}
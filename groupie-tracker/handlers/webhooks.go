@@ -0,0 +1,311 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Webhook event types.
+const (
+	EventConcertAdded     = "concert.added"
+	EventConcertCancelled = "concert.cancelled"
+)
+
+// maxDeliveryLog is how many deliveries the delivery log keeps.
+const maxDeliveryLog = 1000
+
+/*
+WebhookSubscription is one entry of data/webhooks.json:
+
+	[{"id": "ops", "url": "https://example.com/hook", "secret": "s3cret",
+	  "events": ["concert.added"], "artists": [1, 5]}]
+
+An empty events list subscribes to every event and an empty artists list
+to every artist.
+*/
+type WebhookSubscription struct {
+	ID      string   `json:"id"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Artists []int    `json:"artists"`
+}
+
+func (s WebhookSubscription) wantsEvent(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (s WebhookSubscription) wantsArtist(id int) bool {
+	if len(s.Artists) == 0 {
+		return true
+	}
+	for _, a := range s.Artists {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the JSON body POSTed to subscribers.
+type WebhookPayload struct {
+	Event      string          `json:"event"`
+	DeliveryID string          `json:"deliveryId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Concerts   []ArtistConcert `json:"concerts"`
+}
+
+// WebhookDelivery records the outcome of delivering one payload.
+type WebhookDelivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscriptionId"`
+	Event          string    `json:"event"`
+	URL            string    `json:"url"`
+	Attempts       int       `json:"attempts"`
+	StatusCode     int       `json:"statusCode,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Success        bool      `json:"success"`
+	DeliveredAt    time.Time `json:"deliveredAt"`
+}
+
+/*
+WebhookDispatcher sends change events to the subscriptions in
+data/webhooks.json. Each request body is signed with HMAC-SHA256 using the
+subscription secret and sent in the X-Groupie-Signature header as
+"sha256=<hex>". Failed deliveries (network errors, 429 and 5xx responses)
+are retried up to MaxAttempts times, waiting Backoff before the first retry
+and doubling the wait each time.
+*/
+type WebhookDispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// webhooks is the dispatcher used by dataset refreshes.
+var webhooks = &WebhookDispatcher{
+	Client:      &http.Client{Timeout: 10 * time.Second},
+	MaxAttempts: 5,
+	Backoff:     2 * time.Second,
+}
+
+func webhooksPath() string {
+	return filepath.Join(dataDir, "webhooks.json")
+}
+
+func deliveryLogPath() string {
+	return filepath.Join(dataDir, "webhook-deliveries.json")
+}
+
+// readWebhookSubscriptions loads the subscriptions; a missing file means none.
+func readWebhookSubscriptions() ([]WebhookSubscription, error) {
+	var subs []WebhookSubscription
+	err := readJSONFile(webhooksPath(), &subs)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return subs, err
+}
+
+/*
+Dispatch delivers the added and cancelled concerts of a change set to every
+matching subscription, one request per subscription and event type, and
+records each delivery in the delivery log. It blocks until all deliveries
+are done and returns them.
+*/
+func (d *WebhookDispatcher) Dispatch(changes ChangeSet) []WebhookDelivery {
+	subs, err := readWebhookSubscriptions()
+	if err != nil {
+		log.Printf("Error reading webhook subscriptions: %v", err)
+		return nil
+	}
+	return d.send(subs, changes, deliveryLogPath())
+}
+
+/*
+dispatchWebhooks reads the subscriptions and, if there are any, delivers
+the change set in the background so a refresh never waits on subscribers.
+*/
+func dispatchWebhooks(changes ChangeSet) {
+	subs, err := readWebhookSubscriptions()
+	if err != nil {
+		log.Printf("Error reading webhook subscriptions: %v", err)
+		return
+	}
+	if len(subs) > 0 {
+		go webhooks.send(subs, changes, deliveryLogPath())
+	}
+}
+
+func (d *WebhookDispatcher) send(subs []WebhookSubscription, changes ChangeSet, logPath string) []WebhookDelivery {
+	events := []struct {
+		name     string
+		concerts []ArtistConcert
+	}{
+		{EventConcertAdded, changes.ConcertsAdded},
+		{EventConcertCancelled, changes.ConcertsCancelled},
+	}
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		deliveries []WebhookDelivery
+	)
+	for _, sub := range subs {
+		for _, ev := range events {
+			if !sub.wantsEvent(ev.name) {
+				continue
+			}
+			var concerts []ArtistConcert
+			for _, c := range ev.concerts {
+				if sub.wantsArtist(c.ArtistID) {
+					concerts = append(concerts, c)
+				}
+			}
+			if len(concerts) == 0 {
+				continue
+			}
+
+			payload := WebhookPayload{Event: ev.name, DeliveryID: newRequestID(), OccurredAt: changes.At, Concerts: concerts}
+			wg.Add(1)
+			go func(sub WebhookSubscription, payload WebhookPayload) {
+				defer wg.Done()
+				delivery := d.deliver(sub, payload)
+				mu.Lock()
+				deliveries = append(deliveries, delivery)
+				mu.Unlock()
+			}(sub, payload)
+		}
+	}
+	wg.Wait()
+
+	if len(deliveries) > 0 {
+		if err := appendDeliveryLog(logPath, deliveries); err != nil {
+			log.Printf("Error writing the webhook delivery log: %v", err)
+		}
+	}
+	return deliveries
+}
+
+// deliver POSTs one payload, retrying with exponential backoff.
+func (d *WebhookDispatcher) deliver(sub WebhookSubscription, payload WebhookPayload) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:             payload.DeliveryID,
+		SubscriptionID: sub.ID,
+		Event:          payload.Event,
+		URL:            sub.URL,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Error = err.Error()
+		delivery.DeliveredAt = time.Now()
+		return delivery
+	}
+
+	wait := d.Backoff
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		delivery.Attempts = attempt
+		status, err := d.post(sub, payload, body)
+		delivery.StatusCode = status
+		delivery.Error = ""
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if err == nil {
+			delivery.Success = true
+			break
+		}
+		if status != 0 && status != http.StatusTooManyRequests && status < 500 {
+			break // the subscriber rejected the payload; retrying will not help
+		}
+		if attempt < d.MaxAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	delivery.DeliveredAt = time.Now()
+	return delivery
+}
+
+func (d *WebhookDispatcher) post(sub WebhookSubscription, payload WebhookPayload, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "groupie-tracker-webhooks")
+	req.Header.Set("X-Groupie-Event", payload.Event)
+	req.Header.Set("X-Groupie-Delivery", payload.DeliveryID)
+	req.Header.Set("X-Groupie-Signature", "sha256="+signPayload(sub.Secret, body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("subscriber returned status code: %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// signPayload returns the hex HMAC-SHA256 of body keyed with secret.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var deliveryLogMu sync.Mutex
+
+// readDeliveryLog returns the recorded deliveries, oldest first.
+func readDeliveryLog() ([]WebhookDelivery, error) {
+	deliveryLogMu.Lock()
+	defer deliveryLogMu.Unlock()
+	return readDeliveryLogLocked(deliveryLogPath())
+}
+
+func readDeliveryLogLocked(path string) ([]WebhookDelivery, error) {
+	var entries []WebhookDelivery
+	err := readJSONFile(path, &entries)
+	if os.IsNotExist(err) {
+		return []WebhookDelivery{}, nil
+	}
+	return entries, err
+}
+
+func appendDeliveryLog(path string, deliveries []WebhookDelivery) error {
+	deliveryLogMu.Lock()
+	defer deliveryLogMu.Unlock()
+
+	entries, err := readDeliveryLogLocked(path)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, deliveries...)
+	if len(entries) > maxDeliveryLog {
+		entries = entries[len(entries)-maxDeliveryLog:]
+	}
+	return writeJSONFile(path, entries)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the requests it gets and fails the first
+// failures of them with a 503.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	payloads []WebhookPayload
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.calls++
+	if rc.calls <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var p WebhookPayload
+	json.Unmarshal(body, &p)
+	rc.payloads = append(rc.payloads, p)
+	rc.headers = append(rc.headers, r.Header.Clone())
+	rc.bodies = append(rc.bodies, body)
+}
+
+func testDispatcher() *WebhookDispatcher {
+	return &WebhookDispatcher{Client: http.DefaultClient, MaxAttempts: 3, Backoff: time.Millisecond}
+}
+
+func testChangeSet() ChangeSet {
+	return ChangeSet{
+		At: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		ConcertsAdded: []ArtistConcert{
+			{ArtistID: 1, ArtistName: "Queen", Concert: Concert{Location: "tokyo-japan"}},
+			{ArtistID: 2, ArtistName: "Pink Floyd", Concert: Concert{Location: "berlin-germany"}},
+		},
+		ConcertsCancelled: []ArtistConcert{
+			{ArtistID: 1, ArtistName: "Queen", Concert: Concert{Location: "osaka-japan"}},
+		},
+	}
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	useTempDataDir(t)
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	subs := []WebhookSubscription{
+		{ID: "queen-added", URL: server.URL, Secret: "s3cret", Events: []string{EventConcertAdded}, Artists: []int{1}},
+	}
+	if err := writeJSONFile(webhooksPath(), subs); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries := testDispatcher().Dispatch(testChangeSet())
+
+	if len(deliveries) != 1 || !deliveries[0].Success || deliveries[0].Attempts != 1 {
+		t.Fatalf("Dispatch() = %+v, want one successful delivery", deliveries)
+	}
+	if len(receiver.payloads) != 1 {
+		t.Fatalf("receiver got %d payloads, want 1", len(receiver.payloads))
+	}
+	p := receiver.payloads[0]
+	if p.Event != EventConcertAdded || len(p.Concerts) != 1 || p.Concerts[0].Concert.Location != "tokyo-japan" {
+		t.Errorf("unexpected payload %+v", p)
+	}
+
+	h := receiver.headers[0]
+	if got, want := h.Get("X-Groupie-Signature"), "sha256="+signPayload("s3cret", receiver.bodies[0]); got != want {
+		t.Errorf("X-Groupie-Signature = %q, want %q", got, want)
+	}
+	if h.Get("X-Groupie-Event") != EventConcertAdded || h.Get("X-Groupie-Delivery") != p.DeliveryID {
+		t.Errorf("unexpected event headers %v", h)
+	}
+
+	entries, err := readDeliveryLog()
+	if err != nil || len(entries) != 1 || entries[0].SubscriptionID != "queen-added" {
+		t.Errorf("readDeliveryLog() = %+v, %v", entries, err)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	useTempDataDir(t)
+
+	tests := []struct {
+		name         string
+		failures     int
+		wantAttempts int
+		wantSuccess  bool
+	}{
+		{"recovers after retries", 2, 3, true},
+		{"gives up after max attempts", 5, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{failures: tt.failures}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			sub := WebhookSubscription{ID: "all", URL: server.URL, Secret: "k"}
+			got := testDispatcher().deliver(sub, WebhookPayload{Event: EventConcertAdded, DeliveryID: "d1"})
+
+			if got.Attempts != tt.wantAttempts || got.Success != tt.wantSuccess {
+				t.Errorf("deliver() = %+v, want %d attempts, success %v", got, tt.wantAttempts, tt.wantSuccess)
+			}
+			if !tt.wantSuccess && got.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("StatusCode = %d, want 503", got.StatusCode)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectedIsNotRetried(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sub := WebhookSubscription{ID: "bad", URL: server.URL}
+	got := testDispatcher().deliver(sub, WebhookPayload{Event: EventConcertAdded})
+	if got.Success || calls != 1 || got.Attempts != 1 {
+		t.Errorf("deliver() = %+v after %d calls, want one failed attempt", got, calls)
+	}
+}
+
+func TestWebhookSubscriptionFilters(t *testing.T) {
+	useTempDataDir(t)
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	subs := []WebhookSubscription{
+		{ID: "everything", URL: server.URL},
+		{ID: "floyd-cancelled", URL: server.URL, Events: []string{EventConcertCancelled}, Artists: []int{2}},
+	}
+	if err := writeJSONFile(webhooksPath(), subs); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries := testDispatcher().Dispatch(testChangeSet())
+
+	// "everything" gets both events; Pink Floyd had no cancellations.
+	if len(deliveries) != 2 {
+		t.Fatalf("Dispatch() made %d deliveries, want 2: %+v", len(deliveries), deliveries)
+	}
+	for _, d := range deliveries {
+		if d.SubscriptionID != "everything" {
+			t.Errorf("unexpected delivery to %q", d.SubscriptionID)
+		}
+	}
+}
+
+func TestWebhookDispatchWithoutSubscriptions(t *testing.T) {
+	useTempDataDir(t)
+	if got := testDispatcher().Dispatch(testChangeSet()); len(got) != 0 {
+		t.Errorf("Dispatch() without a subscriptions file = %+v, want none", got)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"log"
 	"net/http"
 	"os"
+	"time"
 
 	api "groupie/handlers"
 )
@@ -11,12 +13,18 @@ func main() {
 	if len(os.Args) != 1 {
 		return
 	}
-	http.HandleFunc("/", api.HomeHandler)
-	http.HandleFunc("/locations/", api.LocationHandler)
-	http.HandleFunc("/artists/", api.ArtistsHandler)
-	http.HandleFunc("/artist/", api.ArtistHandler)
-	http.HandleFunc("/relation/", api.RelationHandler)
-	http.HandleFunc("/dates/", api.DateHandler)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	http.ListenAndServe(":3000", nil)
+	provider, err := api.ProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	api.SetProvider(api.WithOverrides(provider))
+	if err := api.FingerprintAssets("static"); err != nil {
+		log.Printf("Error fingerprinting static files: %v", err)
+	}
+
+	stop := api.StartRefresher(api.RefreshInterval)
+	defer stop()
+	stopDigests := api.StartDigests(api.NotifierFromEnv(), time.Hour)
+	defer stopDigests()
+	http.ListenAndServe(":3000", api.Routes())
 }
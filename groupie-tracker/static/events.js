@@ -0,0 +1,47 @@
+// Live updates: listens to /events and offers to reload the page when the
+// data it shows has changed. The artist page passes its artist in
+// data-artist so it only reacts to that artist.
+(function () {
+    if (!window.EventSource || new URLSearchParams(location.search).has('asof')) {
+        return; // historical pages never change
+    }
+    const artist = document.currentScript.dataset.artist;
+    let notice;
+
+    function show(text) {
+        if (!notice) {
+            notice = document.createElement('div');
+            notice.className = 'live-notice';
+            notice.setAttribute('role', 'status');
+            document.body.prepend(notice);
+        }
+        notice.innerHTML = '';
+        notice.append(text + ' ');
+        const reload = document.createElement('a');
+        reload.href = location.href;
+        reload.textContent = 'Reload';
+        notice.append(reload);
+    }
+
+    function concerns(data) {
+        if (!artist) {
+            return true;
+        }
+        const id = data.artist ? data.artist.id : data.artistId;
+        return String(id) === artist;
+    }
+
+    const source = new EventSource('/events');
+    source.addEventListener('artist-updated', function (e) {
+        const data = JSON.parse(e.data);
+        if (concerns(data)) {
+            show(artist ? 'This artist was updated.' : data.artist.name + ' was updated.');
+        }
+    });
+    source.addEventListener('concert-added', function (e) {
+        const data = JSON.parse(e.data);
+        if (concerns(data)) {
+            show('New concert: ' + data.artistName + ' in ' + data.concert.city + ' on ' + data.concert.date.slice(0, 10) + '.');
+        }
+    });
+})();